@@ -17,10 +17,24 @@ limitations under the License.
 package v1alpha1
 
 import (
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// PodSecurity holds the Linux security modules settings for the pods of an NSM component
+type PodSecurity struct {
+	// Seccomp profile for the pods
+	SeccompProfile *corev1.SeccompProfile `json:"seccompProfile,omitempty"`
+	// AppArmor profile for the containers: "runtime/default",
+	// "localhost/<profile>" or "unconfined"
+	// +kubebuilder:validation:Pattern=`^(runtime/default|unconfined|localhost/.+)$`
+	AppArmorProfile string `json:"appArmorProfile,omitempty"`
+	// SELinux options for the pods
+	SELinuxOptions *corev1.SELinuxOptions `json:"seLinuxOptions,omitempty"`
+}
+
 type Forwarder struct {
 	// Forwarder type
 	// +kubebuilder:validation:Enum=vpp;ovs;sriov
@@ -33,6 +47,11 @@ type Forwarder struct {
 	Image string `json:"image,omitempty"`
 	// EnvVars for Forwarder configuration
 	EnvVars []corev1.EnvVar `json:"envVars,omitempty"`
+	// SecurityContext for the forwarder container
+	// (if empty then only the capabilities needed by its type are granted)
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+	// Seccomp, AppArmor and SELinux settings for the forwarder pods
+	PodSecurity PodSecurity `json:"podSecurity,omitempty"`
 }
 
 // ForwarderType is the type of the forwarder
@@ -55,6 +74,21 @@ type Registry struct {
 	Image string `json:"image,omitempty"`
 	// EnvVars for Registry configuration
 	EnvVars []corev1.EnvVar `json:"envVars,omitempty"`
+	// Seccomp, AppArmor and SELinux settings for the registry pods
+	// (seccomp defaults to RuntimeDefault)
+	PodSecurity PodSecurity `json:"podSecurity,omitempty"`
+	// Removal of the expired registrations (k8s registry only)
+	GarbageCollection RegistryGarbageCollection `json:"garbageCollection,omitempty"`
+}
+
+// RegistryGarbageCollection removes the NetworkServiceEndpoints left
+// behind by crashed endpoints and forwarders
+type RegistryGarbageCollection struct {
+	// Delete the NetworkServiceEndpoints whose registration expired
+	Enabled bool `json:"enabled,omitempty"`
+	// Time an expired registration is kept before it's deleted
+	// (if empty then 1m)
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
 }
 
 // Webhook
@@ -62,8 +96,46 @@ type Webhook struct {
 	// admission-webhook-k8s image string
 	// (must be a complete image path with tag)
 	Image string `json:"image,omitempty"`
-	// EnvVars for Webhook configuration
+	// Number of replicas for the webhook, defaults to 2 so
+	// pods can be mutated while a node is drained
+	// +kubebuilder:validation:Minimum=1
+	ReplicaCount int32 `json:"replicaCount,omitempty"`
+	// Affinity of the webhook pods
+	// (if empty then they are spread across nodes when possible)
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// EnvVars for Webhook configuration, they take precedence over
+	// the ones rendered from the client injection settings below
 	EnvVars []corev1.EnvVar `json:"envVars,omitempty"`
+	// Pod annotation requesting the injection of an NSM client
+	// (if empty then "networkservicemesh.io")
+	Annotation string `json:"annotation,omitempty"`
+	// cmd-nsc image injected as a sidecar
+	// (if empty then the one of the NSM version)
+	NSCImage string `json:"nscImage,omitempty"`
+	// cmd-nsc-init image injected as an init container
+	// (if empty then the one of the NSM version)
+	NSCInitImage string `json:"nscInitImage,omitempty"`
+	// Labels added to the pods NSM clients are injected into, on top
+	// of the spiffe.io/spiffe-id one selecting them for registration
+	ClientLabels map[string]string `json:"clientLabels,omitempty"`
+	// Environment variables of the injected containers
+	// (NSM_LOG_LEVEL defaults to the log level of the NSM instance)
+	ClientEnv map[string]string `json:"clientEnv,omitempty"`
+	// CPU and memory requests and limits of the injected containers
+	// (supported from NSM v1.7.0, if empty then the webhook defaults)
+	ClientResources *corev1.ResourceRequirements `json:"clientResources,omitempty"`
+	// Seccomp, AppArmor and SELinux settings for the webhook pods
+	// (seccomp defaults to RuntimeDefault)
+	PodSecurity PodSecurity `json:"podSecurity,omitempty"`
+	// What to do with the pods when the webhook can't be called, defaults
+	// to "Ignore" so pods can be created while the webhook is down
+	// +kubebuilder:validation:Enum=Ignore;Fail
+	FailurePolicy *admissionregistrationv1.FailurePolicyType `json:"failurePolicy,omitempty"`
+	// Namespaces whose pods are sent to the webhook
+	// (if empty then all namespaces but kube-system and the NSM one)
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// Pods sent to the webhook (if empty then all of them)
+	ObjectSelector *metav1.LabelSelector `json:"objectSelector,omitempty"`
 }
 
 type Nsmgr struct {
@@ -72,6 +144,12 @@ type Nsmgr struct {
 	Image string `json:"image,omitempty"`
 	// EnvVars for Nsmgr configuration
 	EnvVars []corev1.EnvVar `json:"envVars,omitempty"`
+	// SecurityContext for the nsmgr container
+	// (if empty then only the capabilities needed by nsmgr are granted)
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+	// Seccomp, AppArmor and SELinux settings for the nsmgr pods, shared
+	// with exclude-prefixes (seccomp defaults to RuntimeDefault)
+	PodSecurity PodSecurity `json:"podSecurity,omitempty"`
 }
 
 type ExclPref struct {
@@ -80,6 +158,135 @@ type ExclPref struct {
 	Image string `json:"exclPrefImage,omitempty"`
 	// EnvVars for ExclPrefImage configuration
 	EnvVars []corev1.EnvVar `json:"envVars,omitempty"`
+	// SecurityContext for the exclude-prefixes container
+	// (if empty then it runs unprivileged with a read-only root filesystem)
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+// Spiffe holds the SPIFFE identity settings for NSM workloads
+type Spiffe struct {
+	// Trust domain of the NSM workloads
+	// (if empty then the one of spire-controller-manager is used,
+	// or "example.org" for the SPIRE server deployed by the operator)
+	// +kubebuilder:validation:Pattern=`^[a-z0-9._-]+$`
+	TrustDomain string `json:"trustDomain,omitempty"`
+	// Trust domains the NSM workloads federate with, for interdomain NSM
+	Federation []FederatedDomain `json:"federation,omitempty"`
+	// Lifetime of the X509-SVIDs of the NSM workloads
+	// (if empty then the default of the SPIRE server is used)
+	SVIDTTL *metav1.Duration `json:"svidTTL,omitempty"`
+	// Template for the SPIFFE IDs registered through ClusterSPIFFEID resources,
+	// defaults to "spiffe://{{ .TrustDomain }}/ns/{{ .PodMeta.Namespace }}/sa/{{ .PodSpec.ServiceAccountName }}"
+	IDTemplate string `json:"idTemplate,omitempty"`
+	// Mount the SPIFFE Workload API into NSM components and injected
+	// clients through the csi.spiffe.io CSI driver instead of a hostPath
+	CSIDriver bool `json:"csiDriver,omitempty"`
+}
+
+// FederatedDomain is a foreign trust domain whose SPIFFE bundle is trusted by NSM workloads
+type FederatedDomain struct {
+	// Trust domain to federate with
+	// +kubebuilder:validation:Pattern=`^[a-z0-9._-]+$`
+	TrustDomain string `json:"trustDomain"`
+	// URL of the SPIFFE bundle endpoint of the trust domain
+	// +kubebuilder:validation:Pattern=`^https://`
+	BundleEndpointURL string `json:"bundleEndpointURL"`
+	// Authentication of the bundle endpoint, defaults to "https_spiffe"
+	// +kubebuilder:validation:Enum=https_spiffe;https_web
+	BundleEndpointProfile string `json:"bundleEndpointProfile,omitempty"`
+	// SPIFFE ID of the bundle endpoint server, required by the https_spiffe profile
+	// +kubebuilder:validation:Pattern=`^spiffe://`
+	EndpointSPIFFEID string `json:"endpointSPIFFEID,omitempty"`
+}
+
+// Spire holds the settings of the SPIRE server and agent deployed by the operator
+type Spire struct {
+	// Deploy SPIRE together with NSM, the agent socket is
+	// created at the spireAgentSocket of the NSM CR
+	Enabled bool `json:"enabled,omitempty"`
+	// spire-server image string
+	// (must be a complete image path with tag)
+	ServerImage string `json:"serverImage,omitempty"`
+	// spire-agent image string
+	// (must be a complete image path with tag)
+	AgentImage string `json:"agentImage,omitempty"`
+	// k8s-workload-registrar image string, it registers the pods
+	// by service account (must be a complete image path with tag)
+	RegistrarImage string `json:"registrarImage,omitempty"`
+	// Cluster name used for node attestation, defaults to "nsm-cluster"
+	ClusterName string `json:"clusterName,omitempty"`
+	// Log level of the SPIRE components, defaults to "INFO"
+	// +kubebuilder:validation:Enum=DEBUG;INFO;WARN;ERROR
+	LogLevel string `json:"logLevel,omitempty"`
+	// Storage class of the volume keeping the datastore and the keys of
+	// spire-server across restarts, the default storage class when empty
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// Size of that volume, defaults to "1Gi"
+	StorageSize *resource.Quantity `json:"storageSize,omitempty"`
+}
+
+// Platform is the kubernetes distribution NSM runs on
+type Platform string
+
+// Supported platforms
+const (
+	PlatformKubernetes Platform = "kubernetes"
+	PlatformOpenShift  Platform = "openshift"
+	PlatformK3s        Platform = "k3s"
+	PlatformKind       Platform = "kind"
+	PlatformMicroK8s   Platform = "microk8s"
+)
+
+// HostPaths holds the node directories mounted into the NSM components.
+// Empty values fall back to the usual locations of a vanilla kubernetes node.
+type HostPaths struct {
+	// Kubelet root directory on the node, defaults to "/var/lib/kubelet"
+	// +kubebuilder:validation:Pattern=`^/`
+	Kubelet string `json:"kubelet,omitempty"`
+	// Directory holding the NSM unix sockets on the node,
+	// defaults to "/var/lib/networkservicemesh"
+	// +kubebuilder:validation:Pattern=`^/`
+	NsmSocket string `json:"nsmSocket,omitempty"`
+	// Directory holding the SPIRE agent socket on the node
+	// (if empty then the directory of spireAgentSocket is used)
+	// +kubebuilder:validation:Pattern=`^/`
+	SpireAgentSocket string `json:"spireAgentSocket,omitempty"`
+	// cgroup filesystem on the node, defaults to "/sys/fs/cgroup"
+	// +kubebuilder:validation:Pattern=`^/`
+	Cgroup string `json:"cgroup,omitempty"`
+	// VFIO devices directory on the node, defaults to "/dev/vfio"
+	// +kubebuilder:validation:Pattern=`^/`
+	Vfio string `json:"vfio,omitempty"`
+}
+
+// NetworkService registered with registry-k8s
+type NetworkService struct {
+	// Name of the network service
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// Payload of the connections to the service (if empty then "IP")
+	// +kubebuilder:validation:Enum=ETHERNET;IP
+	Payload string `json:"payload,omitempty"`
+	// Rules selecting the endpoints of the service for each client,
+	// evaluated in order
+	Matches []NetworkServiceMatch `json:"matches,omitempty"`
+}
+
+// NetworkServiceMatch routes the clients matching its source selector
+type NetworkServiceMatch struct {
+	// Labels of the clients the match applies to (if empty then all of them)
+	SourceSelector map[string]string `json:"sourceSelector,omitempty"`
+	// Endpoints the matching clients are routed to
+	// +kubebuilder:validation:MinItems=1
+	Routes []NetworkServiceRoute `json:"routes"`
+	// Evaluate the next matches when no endpoint is found
+	Fallthrough bool `json:"fallthrough,omitempty"`
+}
+
+// NetworkServiceRoute selects the endpoints of a match
+type NetworkServiceRoute struct {
+	// Labels of the endpoints (if empty then all of them)
+	DestinationSelector map[string]string `json:"destinationSelector,omitempty"`
 }
 
 // NSMSpec defines the desired state of NSM
@@ -90,9 +297,26 @@ type NSMSpec struct {
 	NsmPullPolicy corev1.PullPolicy `json:"nsmPullPolicy,omitempty"`
 	// Log level of the NSM components, defaults to "INFO"
 	NsmLogLevel string `json:"nsmLogLevel,omitempty"`
+	// Platform profile to apply, overrides the one detected by the operator
+	// +kubebuilder:validation:Enum=kubernetes;openshift;k3s;kind;microk8s
+	Platform Platform `json:"platform,omitempty"`
 	// SPIRE agent socket for NSM components, must be set
 	// according to the socket_path parameter of spire-agent
 	SpireAgentSocket string `json:"spireAgentSocket,omitempty"`
+	// SPIRE agent daemonset the NSM components wait for, as "namespace/name",
+	// when SPIRE isn't deployed by the operator (if empty then the daemonsets
+	// running a spire-agent image are looked up)
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?/[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`
+	SpireAgentDaemonSet string `json:"spireAgentDaemonSet,omitempty"`
+	// SPIFFE identities of NSM workloads
+	Spiffe Spiffe `json:"spiffe,omitempty"`
+	// SPIRE server and agent deployed by the operator
+	Spire Spire `json:"spire,omitempty"`
+	// Run nsmgr and forwarder containers in privileged mode
+	// instead of granting them explicit capabilities
+	Privileged bool `json:"privileged,omitempty"`
+	// Host directories mounted into the NSM components
+	HostPaths HostPaths `json:"hostPaths,omitempty"`
 	// Webhook for NSM
 	Webhook Webhook `json:"webhook,omitempty"`
 	// Registry for NSM
@@ -103,6 +327,12 @@ type NSMSpec struct {
 	ExclPref ExclPref `json:"exclPref,omitempty"`
 	// List of forwarders to be used with NSM
 	Forwarders []Forwarder `json:"forwarders"`
+	// Network services registered with registry-k8s
+	NetworkServices []NetworkService `json:"networkServices,omitempty"`
+	// Interdomain components connecting the NSM instance with other clusters
+	Interdomain Interdomain `json:"interdomain,omitempty"`
+	// Registry shared with other domains hosted next to the NSM instance
+	FloatingRegistry FloatingRegistry `json:"floatingRegistry,omitempty"`
 }
 
 // NSMPhase is the type for the operator phases
@@ -117,10 +347,139 @@ const (
 	NSMPhaseTerminating NSMPhase = "Terminating"
 )
 
+// Condition types of the NSM status
+const (
+	// SPIRE serves the workload API on the nodes
+	NSMConditionSpireReady string = "SpireReady"
+	// The SPIFFE settings of the CR are consistent
+	NSMConditionSpiffeValid string = "SpiffeValid"
+	// The network services of the CR are consistent
+	NSMConditionNetworkServicesValid string = "NetworkServicesValid"
+	// Every forwarder pod is registered as an endpoint with registry-k8s
+	NSMConditionForwardersRegistered string = "ForwardersRegistered"
+	// The floating registry can be deployed with the settings of the CR
+	NSMConditionFloatingRegistryValid string = "FloatingRegistryValid"
+)
+
+// Condition reasons of the NSM status
+const (
+	NSMReasonSpireReady              string = "SpireReady"
+	NSMReasonSpireNotReady           string = "SpireNotReady"
+	NSMReasonSpiffeValid             string = "SpiffeValid"
+	NSMReasonSpiffeInvalid           string = "SpiffeInvalid"
+	NSMReasonNetworkServicesValid    string = "NetworkServicesValid"
+	NSMReasonNetworkServicesInvalid  string = "NetworkServicesInvalid"
+	NSMReasonForwardersRegistered    string = "ForwardersRegistered"
+	NSMReasonForwardersUnregistered  string = "ForwardersUnregistered"
+	NSMReasonFloatingRegistryValid   string = "FloatingRegistryValid"
+	NSMReasonFloatingRegistryInvalid string = "FloatingRegistryInvalid"
+)
+
+// Interdomain deploys nsmgr-proxy and registry-proxy-dns, the registry
+// and nsmgr reach the network services of other domains through them
+type Interdomain struct {
+	// Deploy the interdomain components
+	Enabled bool `json:"enabled,omitempty"`
+	// Domain the NSM instance is published under, the services exposing
+	// nsmgr-proxy and the registry get external-dns hostnames in it
+	Domain string `json:"domain,omitempty"`
+	// Type of the service exposing nsmgr-proxy to the other clusters
+	// (if empty then LoadBalancer)
+	// +kubebuilder:validation:Enum=LoadBalancer;NodePort
+	ServiceType corev1.ServiceType `json:"serviceType,omitempty"`
+	// Address requested for the nsmgr-proxy load balancer
+	LoadBalancerIP string `json:"loadBalancerIP,omitempty"`
+	// nsmgr-proxy handling the connections from and to the other domains
+	NsmgrProxy InterdomainComponent `json:"nsmgrProxy,omitempty"`
+	// registry-proxy-dns resolving the registries of the other domains
+	RegistryProxyDNS InterdomainComponent `json:"registryProxyDNS,omitempty"`
+	// cmd-map-ip-k8s image string, run next to nsmgr-proxy
+	// (if empty then the one of the NSM version)
+	MapIPImage string `json:"mapIPImage,omitempty"`
+	// DNS settings of registry-proxy-dns, for domains the cluster DNS can't resolve
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+}
+
+// FloatingRegistry hosts a registry the network services of several domains
+// are registered with, they are named <service>@<hostname>
+type FloatingRegistry struct {
+	// Deploy the floating registry
+	Enabled bool `json:"enabled,omitempty"`
+	// Stable DNS name the other domains reach the registry under,
+	// published through external-dns
+	Hostname string `json:"hostname,omitempty"`
+	// Address requested for the load balancer of the registry
+	LoadBalancerIP string `json:"loadBalancerIP,omitempty"`
+	// cmd-registry-memory image string
+	// (if empty then the one of the NSM version)
+	Image string `json:"image,omitempty"`
+	// EnvVars for the floating registry, they take precedence over
+	// the ones rendered from the NSM instance
+	EnvVars []corev1.EnvVar `json:"envVars,omitempty"`
+}
+
+// InterdomainComponent defines an interdomain component
+type InterdomainComponent struct {
+	// Image with tag (if empty then the one of the NSM version)
+	Image string `json:"image,omitempty"`
+	// EnvVars for the component, they take precedence over
+	// the ones rendered from the NSM instance
+	EnvVars []corev1.EnvVar `json:"envVars,omitempty"`
+}
+
+// NetworkServiceStatus reports the endpoints registered for a network service
+type NetworkServiceStatus struct {
+	// Name of the network service
+	Name string `json:"name"`
+	// NetworkServiceEndpoints registered for the service
+	Endpoints []string `json:"endpoints,omitempty"`
+}
+
+// RegisteredServiceStatus counts the endpoints registered for a network service
+type RegisteredServiceStatus struct {
+	// Name of the network service
+	Name string `json:"name"`
+	// Number of endpoints registered for the service
+	Endpoints int32 `json:"endpoints"`
+	// Number of those endpoints whose registration expired,
+	// refreshed every minute
+	ExpiredEndpoints int32 `json:"expiredEndpoints,omitempty"`
+}
+
+// RegistryStatus summarises the registrations stored by registry-k8s
+type RegistryStatus struct {
+	// Number of registered network services
+	NetworkServices int32 `json:"networkServices"`
+	// Number of registered endpoints
+	Endpoints int32 `json:"endpoints"`
+	// Number of endpoints whose registration expired. Registrations
+	// expire without any event, they are counted again every minute.
+	ExpiredEndpoints int32 `json:"expiredEndpoints,omitempty"`
+	// Endpoints registered for each network service
+	Services []RegisteredServiceStatus `json:"services,omitempty"`
+	// Forwarder pods not registered as endpoints, or whose registration
+	// expired. Like expiredEndpoints it's refreshed every minute.
+	UnregisteredForwarders []string `json:"unregisteredForwarders,omitempty"`
+}
+
 // NSMStatus defines the observed state of NSM
 type NSMStatus struct {
 	// Operator phases during deployment
 	Phase NSMPhase `json:"phase"`
+	// Platform profile applied to the NSM components
+	Platform Platform `json:"platform,omitempty"`
+	// Namespaces with workloads requesting NSM client injection whose
+	// service accounts can't use the SCC needed by cmd-nsc (OpenShift only)
+	ClientNamespacesWithoutSCC []string `json:"clientNamespacesWithoutSCC,omitempty"`
+	// Network services of the CR and their registered endpoints
+	NetworkServices []NetworkServiceStatus `json:"networkServices,omitempty"`
+	// Registrations stored by registry-k8s (k8s registry only)
+	Registry *RegistryStatus `json:"registry,omitempty"`
+	// Conditions of the configuration and dependencies of the NSM components
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -0,0 +1,105 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NSMEndpointSpec defines the desired state of NSMEndpoint
+type NSMEndpointSpec struct {
+	// Name of the NSM instance the endpoint connects to, it has to be in
+	// the namespace of the endpoint (if empty then the only one of the namespace)
+	NSM string `json:"nsm,omitempty"`
+	// NSE image string
+	// (must be a complete image path with tag)
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+	// Network services served by the endpoint
+	// +kubebuilder:validation:MinItems=1
+	NetworkServices []string `json:"networkServices"`
+	// Prefix the endpoint assigns the connection addresses from
+	CIDRPrefix string `json:"cidrPrefix,omitempty"`
+	// Number of replicas for the endpoint (if empty then 1)
+	// +kubebuilder:validation:Minimum=1
+	ReplicaCount int32 `json:"replicaCount,omitempty"`
+	// EnvVars for the endpoint, they take precedence over
+	// the ones rendered from the NSM instance
+	EnvVars []corev1.EnvVar `json:"envVars,omitempty"`
+	// Resources of the endpoint container
+	// (if empty then the ones of the NSM sample endpoints)
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// SecurityContext for the endpoint container
+	// (if empty then it follows the privileged setting of the NSM instance)
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+// Condition types of the NSMEndpoint status
+const (
+	// The replicas of the endpoint are available
+	NSMEndpointConditionReady string = "Ready"
+)
+
+// Condition reasons of the NSMEndpoint status
+const (
+	NSMEndpointReasonNSMNotFound        string = "NSMNotFound"
+	NSMEndpointReasonDeploymentReady    string = "DeploymentReady"
+	NSMEndpointReasonDeploymentNotReady string = "DeploymentNotReady"
+	// A deployment of the same name belongs to another workload
+	NSMEndpointReasonDeploymentConflict string = "DeploymentConflict"
+)
+
+// NSMEndpointStatus defines the observed state of NSMEndpoint
+type NSMEndpointStatus struct {
+	// Number of ready replicas of the endpoint
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+	// Conditions of the endpoint deployment
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=nsmendpoints
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.spec.image`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+
+// NSMEndpoint is the Schema for the nsmendpoints API. The operator only
+// watches the nsm namespace, NSMEndpoints created elsewhere are ignored.
+type NSMEndpoint struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NSMEndpointSpec   `json:"spec,omitempty"`
+	Status NSMEndpointStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NSMEndpointList contains a list of NSMEndpoint
+type NSMEndpointList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NSMEndpoint `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NSMEndpoint{}, &NSMEndpointList{})
+}
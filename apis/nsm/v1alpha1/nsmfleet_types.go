@@ -0,0 +1,131 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// KubeconfigSecretReference points to the kubeconfig of a member cluster
+type KubeconfigSecretReference struct {
+	// Name of the secret in the namespace of the fleet
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Key of the kubeconfig in the secret (if empty then "kubeconfig")
+	Key string `json:"key,omitempty"`
+}
+
+// NSMFleetMember defines a member cluster of the fleet
+type NSMFleetMember struct {
+	// Name of the member cluster, unique in the fleet
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Secret holding the kubeconfig of the member cluster
+	KubeconfigSecret KubeconfigSecretReference `json:"kubeconfigSecret"`
+	// JSON merge patch applied to the NSM spec template for the member
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	Overrides *apiextensionsv1.JSON `json:"overrides,omitempty"`
+}
+
+// NSMFleetSpec defines the desired state of NSMFleet
+type NSMFleetSpec struct {
+	// NSM spec deployed in every member cluster
+	Template NSMSpec `json:"template"`
+	// Member clusters of the fleet
+	// +kubebuilder:validation:MinItems=1
+	Members []NSMFleetMember `json:"members"`
+}
+
+// Condition types of the NSMFleet status
+const (
+	// NSM is running in every member cluster
+	NSMFleetConditionReady string = "Ready"
+)
+
+// Condition reasons of the NSMFleet status
+const (
+	NSMFleetReasonMembersReady    string = "MembersReady"
+	NSMFleetReasonMembersNotReady string = "MembersNotReady"
+)
+
+// NSMFleetMemberStatus reports the NSM instance of a member cluster
+type NSMFleetMemberStatus struct {
+	// Name of the member cluster
+	Name string `json:"name"`
+	// Secret the member was connected with, its NSM instance
+	// is removed with it once the member leaves the fleet
+	KubeconfigSecret *KubeconfigSecretReference `json:"kubeconfigSecret,omitempty"`
+	// Phase of the NSM instance of the member
+	Phase NSMPhase `json:"phase,omitempty"`
+	// Platform profile applied in the member
+	Platform Platform `json:"platform,omitempty"`
+	// The member runs its own nsm-operator, which reconciles
+	// the NSM instance instead of the hub
+	LocalOperator bool `json:"localOperator,omitempty"`
+	// Error reaching or reconciling the member
+	Error string `json:"error,omitempty"`
+	// Conditions of the NSM instance of the member
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// NSMFleetStatus defines the observed state of NSMFleet
+type NSMFleetStatus struct {
+	// Number of members running NSM
+	ReadyMembers int32 `json:"readyMembers,omitempty"`
+	// NSM instances of the member clusters
+	Members []NSMFleetMemberStatus `json:"members,omitempty"`
+	// Members removed while their kubeconfig secret was gone, their
+	// NSM instance couldn't be reached and was left in place
+	// +optional
+	OrphanedMembers []string `json:"orphanedMembers,omitempty"`
+	// Conditions of the fleet
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=nsmfleets
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.template.version`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyMembers`
+
+// NSMFleet is the Schema for the nsmfleets API
+type NSMFleet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NSMFleetSpec   `json:"spec,omitempty"`
+	Status NSMFleetStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NSMFleetList contains a list of NSMFleet
+type NSMFleetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NSMFleet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NSMFleet{}, &NSMFleetList{})
+}
@@ -0,0 +1,105 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Injection modes of a namespace
+const (
+	InjectionAllow string = "Allow"
+	InjectionDeny  string = "Deny"
+)
+
+// NSMInjectionPolicySpec defines how NSM clients are injected into the pods of its namespace
+type NSMInjectionPolicySpec struct {
+	// Whether NSM clients are injected into the pods of the namespace
+	// (if empty then "Allow")
+	// +kubebuilder:validation:Enum=Allow;Deny
+	Injection string `json:"injection,omitempty"`
+	// Network services the pods of the namespace may request
+	// (if empty then any of them)
+	NetworkServices []string `json:"networkServices,omitempty"`
+	// NSM version of the injected NSC images
+	// (if empty then the one of the webhook)
+	// +kubebuilder:validation:Pattern=`^v[0-9]+\.[0-9]+\.[0-9]+`
+	NSCVersion string `json:"nscVersion,omitempty"`
+	// Environment variables of the injected containers, such as NSM_LOG_LEVEL
+	Env map[string]string `json:"env,omitempty"`
+}
+
+// Condition types of the NSMInjectionPolicy status
+const (
+	// The annotated pods of the namespace follow the policy
+	InjectionPolicyConditionCompliant string = "Compliant"
+)
+
+// Condition reasons of the NSMInjectionPolicy status
+const (
+	InjectionPolicyReasonPodsCompliant string = "PodsCompliant"
+	InjectionPolicyReasonPodsViolating string = "PodsViolating"
+)
+
+// PolicyViolation is an annotated pod not following the policy of its namespace
+type PolicyViolation struct {
+	// Name of the pod
+	Pod string `json:"pod"`
+	// What the pod does against the policy
+	Reason string `json:"reason"`
+}
+
+// NSMInjectionPolicyStatus defines the observed state of NSMInjectionPolicy
+type NSMInjectionPolicyStatus struct {
+	// Generation of the policy the status was computed for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Annotated pods of the namespace not following the policy
+	Violations []PolicyViolation `json:"violations,omitempty"`
+	// Conditions of the enforcement of the policy
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=nsminjectionpolicies
+// +kubebuilder:printcolumn:name="Injection",type=string,JSONPath=`.spec.injection`
+// +kubebuilder:printcolumn:name="Compliant",type=string,JSONPath=`.status.conditions[?(@.type=="Compliant")].status`
+
+// NSMInjectionPolicy is the Schema for the nsminjectionpolicies API
+type NSMInjectionPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NSMInjectionPolicySpec   `json:"spec,omitempty"`
+	Status NSMInjectionPolicyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NSMInjectionPolicyList contains a list of NSMInjectionPolicy
+type NSMInjectionPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NSMInjectionPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NSMInjectionPolicy{}, &NSMInjectionPolicyList{})
+}
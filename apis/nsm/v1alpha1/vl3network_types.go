@@ -0,0 +1,111 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VL3NetworkSpec defines the desired state of VL3Network
+type VL3NetworkSpec struct {
+	// Name of the NSM instance the network is deployed with, it has to be in
+	// the namespace of the network (if empty then the only one of the namespace)
+	NSM string `json:"nsm,omitempty"`
+	// Prefix of the network, split between its endpoints
+	// +kubebuilder:validation:MinLength=1
+	Prefix string `json:"prefix"`
+	// Length of the prefix each endpoint assigns the client addresses from
+	// (if empty then 24)
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=128
+	EndpointPrefixLength int32 `json:"endpointPrefixLength,omitempty"`
+	// Number of vL3 endpoints (if empty then 1)
+	// +kubebuilder:validation:Minimum=1
+	EndpointCount int32 `json:"endpointCount,omitempty"`
+	// DNS domain the clients of the network are named under
+	// (if empty then the name of the network)
+	DNSDomain string `json:"dnsDomain,omitempty"`
+	// cmd-nse-vl3-vpp image string
+	// (if empty then the one of the NSM version)
+	NSEImage string `json:"nseImage,omitempty"`
+	// cmd-ipam-vl3 image string
+	// (if empty then the one of the NSM version)
+	IPAMImage string `json:"ipamImage,omitempty"`
+}
+
+// Condition types of the VL3Network status
+const (
+	// The IPAM server and the endpoints of the network are available
+	VL3NetworkConditionReady string = "Ready"
+)
+
+// Condition reasons of the VL3Network status
+const (
+	VL3NetworkReasonNSMNotFound   string = "NSMNotFound"
+	VL3NetworkReasonInvalidPrefix string = "InvalidPrefix"
+	VL3NetworkReasonReady         string = "NetworkReady"
+	VL3NetworkReasonNotReady      string = "NetworkNotReady"
+	// A deployment of the network is named like the one of another workload
+	VL3NetworkReasonDeploymentConflict string = "DeploymentConflict"
+)
+
+// VL3NetworkStatus defines the observed state of VL3Network
+type VL3NetworkStatus struct {
+	// Static plan of the prefixes the IPAM server is expected to hand out
+	// to the endpoints, in allocation order. It's computed from the spec,
+	// not read back from the IPAM server: the leases it actually holds,
+	// after endpoints restarted for instance, may differ.
+	PlannedPrefixes []string `json:"plannedPrefixes,omitempty"`
+	// Annotation requesting a connection to the network for a client pod
+	ClientAnnotation string `json:"clientAnnotation,omitempty"`
+	// Number of ready endpoints of the network
+	ReadyEndpoints int32 `json:"readyEndpoints,omitempty"`
+	// Conditions of the network deployment
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=vl3networks
+// +kubebuilder:printcolumn:name="Prefix",type=string,JSONPath=`.spec.prefix`
+// +kubebuilder:printcolumn:name="Endpoints",type=integer,JSONPath=`.status.readyEndpoints`
+
+// VL3Network is the Schema for the vl3networks API. The operator only
+// watches the nsm namespace, VL3Networks created elsewhere are ignored.
+type VL3Network struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VL3NetworkSpec   `json:"spec,omitempty"`
+	Status VL3NetworkStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// VL3NetworkList contains a list of VL3Network
+type VL3NetworkList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VL3Network `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VL3Network{}, &VL3NetworkList{})
+}
@@ -22,7 +22,10 @@ limitations under the License.
 package v1alpha1
 
 import (
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	"k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -36,6 +39,11 @@ func (in *ExclPref) DeepCopyInto(out *ExclPref) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExclPref.
@@ -48,6 +56,43 @@ func (in *ExclPref) DeepCopy() *ExclPref {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FederatedDomain) DeepCopyInto(out *FederatedDomain) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FederatedDomain.
+func (in *FederatedDomain) DeepCopy() *FederatedDomain {
+	if in == nil {
+		return nil
+	}
+	out := new(FederatedDomain)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FloatingRegistry) DeepCopyInto(out *FloatingRegistry) {
+	*out = *in
+	if in.EnvVars != nil {
+		in, out := &in.EnvVars, &out.EnvVars
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FloatingRegistry.
+func (in *FloatingRegistry) DeepCopy() *FloatingRegistry {
+	if in == nil {
+		return nil
+	}
+	out := new(FloatingRegistry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Forwarder) DeepCopyInto(out *Forwarder) {
 	*out = *in
@@ -58,6 +103,12 @@ func (in *Forwarder) DeepCopyInto(out *Forwarder) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	in.PodSecurity.DeepCopyInto(&out.PodSecurity)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Forwarder.
@@ -70,13 +121,87 @@ func (in *Forwarder) DeepCopy() *Forwarder {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPaths) DeepCopyInto(out *HostPaths) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPaths.
+func (in *HostPaths) DeepCopy() *HostPaths {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPaths)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Interdomain) DeepCopyInto(out *Interdomain) {
+	*out = *in
+	in.NsmgrProxy.DeepCopyInto(&out.NsmgrProxy)
+	in.RegistryProxyDNS.DeepCopyInto(&out.RegistryProxyDNS)
+	if in.DNSConfig != nil {
+		in, out := &in.DNSConfig, &out.DNSConfig
+		*out = new(v1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Interdomain.
+func (in *Interdomain) DeepCopy() *Interdomain {
+	if in == nil {
+		return nil
+	}
+	out := new(Interdomain)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InterdomainComponent) DeepCopyInto(out *InterdomainComponent) {
+	*out = *in
+	if in.EnvVars != nil {
+		in, out := &in.EnvVars, &out.EnvVars
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterdomainComponent.
+func (in *InterdomainComponent) DeepCopy() *InterdomainComponent {
+	if in == nil {
+		return nil
+	}
+	out := new(InterdomainComponent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubeconfigSecretReference) DeepCopyInto(out *KubeconfigSecretReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeconfigSecretReference.
+func (in *KubeconfigSecretReference) DeepCopy() *KubeconfigSecretReference {
+	if in == nil {
+		return nil
+	}
+	out := new(KubeconfigSecretReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NSM) DeepCopyInto(out *NSM) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSM.
@@ -98,31 +223,58 @@ func (in *NSM) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NSMList) DeepCopyInto(out *NSMList) {
+func (in *NSMEndpoint) DeepCopyInto(out *NSMEndpoint) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMEndpoint.
+func (in *NSMEndpoint) DeepCopy() *NSMEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(NSMEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NSMEndpoint) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NSMEndpointList) DeepCopyInto(out *NSMEndpointList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]NSM, len(*in))
+		*out = make([]NSMEndpoint, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMList.
-func (in *NSMList) DeepCopy() *NSMList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMEndpointList.
+func (in *NSMEndpointList) DeepCopy() *NSMEndpointList {
 	if in == nil {
 		return nil
 	}
-	out := new(NSMList)
+	out := new(NSMEndpointList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *NSMList) DeepCopyObject() runtime.Object {
+func (in *NSMEndpointList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -130,102 +282,898 @@ func (in *NSMList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NSMSpec) DeepCopyInto(out *NSMSpec) {
+func (in *NSMEndpointSpec) DeepCopyInto(out *NSMEndpointSpec) {
 	*out = *in
-	in.Webhook.DeepCopyInto(&out.Webhook)
-	in.Registry.DeepCopyInto(&out.Registry)
-	in.Nsmgr.DeepCopyInto(&out.Nsmgr)
-	in.ExclPref.DeepCopyInto(&out.ExclPref)
-	if in.Forwarders != nil {
-		in, out := &in.Forwarders, &out.Forwarders
-		*out = make([]Forwarder, len(*in))
+	if in.NetworkServices != nil {
+		in, out := &in.NetworkServices, &out.NetworkServices
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnvVars != nil {
+		in, out := &in.EnvVars, &out.EnvVars
+		*out = make([]v1.EnvVar, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMSpec.
-func (in *NSMSpec) DeepCopy() *NSMSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMEndpointSpec.
+func (in *NSMEndpointSpec) DeepCopy() *NSMEndpointSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(NSMSpec)
+	out := new(NSMEndpointSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *NSMStatus) DeepCopyInto(out *NSMStatus) {
+func (in *NSMEndpointStatus) DeepCopyInto(out *NSMEndpointStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMStatus.
-func (in *NSMStatus) DeepCopy() *NSMStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMEndpointStatus.
+func (in *NSMEndpointStatus) DeepCopy() *NSMEndpointStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(NSMStatus)
+	out := new(NSMEndpointStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Nsmgr) DeepCopyInto(out *Nsmgr) {
+func (in *NSMFleet) DeepCopyInto(out *NSMFleet) {
 	*out = *in
-	if in.EnvVars != nil {
-		in, out := &in.EnvVars, &out.EnvVars
-		*out = make([]v1.EnvVar, len(*in))
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMFleet.
+func (in *NSMFleet) DeepCopy() *NSMFleet {
+	if in == nil {
+		return nil
+	}
+	out := new(NSMFleet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NSMFleet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NSMFleetList) DeepCopyInto(out *NSMFleetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NSMFleet, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Nsmgr.
-func (in *Nsmgr) DeepCopy() *Nsmgr {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMFleetList.
+func (in *NSMFleetList) DeepCopy() *NSMFleetList {
 	if in == nil {
 		return nil
 	}
-	out := new(Nsmgr)
+	out := new(NSMFleetList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NSMFleetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Registry) DeepCopyInto(out *Registry) {
+func (in *NSMFleetMember) DeepCopyInto(out *NSMFleetMember) {
 	*out = *in
-	if in.EnvVars != nil {
-		in, out := &in.EnvVars, &out.EnvVars
-		*out = make([]v1.EnvVar, len(*in))
+	out.KubeconfigSecret = in.KubeconfigSecret
+	if in.Overrides != nil {
+		in, out := &in.Overrides, &out.Overrides
+		*out = new(apiextensionsv1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMFleetMember.
+func (in *NSMFleetMember) DeepCopy() *NSMFleetMember {
+	if in == nil {
+		return nil
+	}
+	out := new(NSMFleetMember)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NSMFleetMemberStatus) DeepCopyInto(out *NSMFleetMemberStatus) {
+	*out = *in
+	if in.KubeconfigSecret != nil {
+		in, out := &in.KubeconfigSecret, &out.KubeconfigSecret
+		*out = new(KubeconfigSecretReference)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Registry.
-func (in *Registry) DeepCopy() *Registry {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMFleetMemberStatus.
+func (in *NSMFleetMemberStatus) DeepCopy() *NSMFleetMemberStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(Registry)
+	out := new(NSMFleetMemberStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Webhook) DeepCopyInto(out *Webhook) {
+func (in *NSMFleetSpec) DeepCopyInto(out *NSMFleetSpec) {
 	*out = *in
-	if in.EnvVars != nil {
-		in, out := &in.EnvVars, &out.EnvVars
-		*out = make([]v1.EnvVar, len(*in))
+	in.Template.DeepCopyInto(&out.Template)
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]NSMFleetMember, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMFleetSpec.
+func (in *NSMFleetSpec) DeepCopy() *NSMFleetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NSMFleetSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NSMFleetStatus) DeepCopyInto(out *NSMFleetStatus) {
+	*out = *in
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]NSMFleetMemberStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OrphanedMembers != nil {
+		in, out := &in.OrphanedMembers, &out.OrphanedMembers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMFleetStatus.
+func (in *NSMFleetStatus) DeepCopy() *NSMFleetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NSMFleetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NSMInjectionPolicy) DeepCopyInto(out *NSMInjectionPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMInjectionPolicy.
+func (in *NSMInjectionPolicy) DeepCopy() *NSMInjectionPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(NSMInjectionPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NSMInjectionPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NSMInjectionPolicyList) DeepCopyInto(out *NSMInjectionPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NSMInjectionPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMInjectionPolicyList.
+func (in *NSMInjectionPolicyList) DeepCopy() *NSMInjectionPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(NSMInjectionPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NSMInjectionPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NSMInjectionPolicySpec) DeepCopyInto(out *NSMInjectionPolicySpec) {
+	*out = *in
+	if in.NetworkServices != nil {
+		in, out := &in.NetworkServices, &out.NetworkServices
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMInjectionPolicySpec.
+func (in *NSMInjectionPolicySpec) DeepCopy() *NSMInjectionPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NSMInjectionPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NSMInjectionPolicyStatus) DeepCopyInto(out *NSMInjectionPolicyStatus) {
+	*out = *in
+	if in.Violations != nil {
+		in, out := &in.Violations, &out.Violations
+		*out = make([]PolicyViolation, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMInjectionPolicyStatus.
+func (in *NSMInjectionPolicyStatus) DeepCopy() *NSMInjectionPolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NSMInjectionPolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NSMList) DeepCopyInto(out *NSMList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NSM, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMList.
+func (in *NSMList) DeepCopy() *NSMList {
+	if in == nil {
+		return nil
+	}
+	out := new(NSMList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NSMList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NSMSpec) DeepCopyInto(out *NSMSpec) {
+	*out = *in
+	in.Spiffe.DeepCopyInto(&out.Spiffe)
+	in.Spire.DeepCopyInto(&out.Spire)
+	out.HostPaths = in.HostPaths
+	in.Webhook.DeepCopyInto(&out.Webhook)
+	in.Registry.DeepCopyInto(&out.Registry)
+	in.Nsmgr.DeepCopyInto(&out.Nsmgr)
+	in.ExclPref.DeepCopyInto(&out.ExclPref)
+	if in.Forwarders != nil {
+		in, out := &in.Forwarders, &out.Forwarders
+		*out = make([]Forwarder, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NetworkServices != nil {
+		in, out := &in.NetworkServices, &out.NetworkServices
+		*out = make([]NetworkService, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Interdomain.DeepCopyInto(&out.Interdomain)
+	in.FloatingRegistry.DeepCopyInto(&out.FloatingRegistry)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMSpec.
+func (in *NSMSpec) DeepCopy() *NSMSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NSMSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NSMStatus) DeepCopyInto(out *NSMStatus) {
+	*out = *in
+	if in.ClientNamespacesWithoutSCC != nil {
+		in, out := &in.ClientNamespacesWithoutSCC, &out.ClientNamespacesWithoutSCC
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NetworkServices != nil {
+		in, out := &in.NetworkServices, &out.NetworkServices
+		*out = make([]NetworkServiceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Registry != nil {
+		in, out := &in.Registry, &out.Registry
+		*out = new(RegistryStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NSMStatus.
+func (in *NSMStatus) DeepCopy() *NSMStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NSMStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkService) DeepCopyInto(out *NetworkService) {
+	*out = *in
+	if in.Matches != nil {
+		in, out := &in.Matches, &out.Matches
+		*out = make([]NetworkServiceMatch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkService.
+func (in *NetworkService) DeepCopy() *NetworkService {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkService)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkServiceMatch) DeepCopyInto(out *NetworkServiceMatch) {
+	*out = *in
+	if in.SourceSelector != nil {
+		in, out := &in.SourceSelector, &out.SourceSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]NetworkServiceRoute, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkServiceMatch.
+func (in *NetworkServiceMatch) DeepCopy() *NetworkServiceMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkServiceMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkServiceRoute) DeepCopyInto(out *NetworkServiceRoute) {
+	*out = *in
+	if in.DestinationSelector != nil {
+		in, out := &in.DestinationSelector, &out.DestinationSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkServiceRoute.
+func (in *NetworkServiceRoute) DeepCopy() *NetworkServiceRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkServiceRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkServiceStatus) DeepCopyInto(out *NetworkServiceStatus) {
+	*out = *in
+	if in.Endpoints != nil {
+		in, out := &in.Endpoints, &out.Endpoints
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkServiceStatus.
+func (in *NetworkServiceStatus) DeepCopy() *NetworkServiceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkServiceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Nsmgr) DeepCopyInto(out *Nsmgr) {
+	*out = *in
+	if in.EnvVars != nil {
+		in, out := &in.EnvVars, &out.EnvVars
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	in.PodSecurity.DeepCopyInto(&out.PodSecurity)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Nsmgr.
+func (in *Nsmgr) DeepCopy() *Nsmgr {
+	if in == nil {
+		return nil
+	}
+	out := new(Nsmgr)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PodSecurity) DeepCopyInto(out *PodSecurity) {
+	*out = *in
+	if in.SeccompProfile != nil {
+		in, out := &in.SeccompProfile, &out.SeccompProfile
+		*out = new(v1.SeccompProfile)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SELinuxOptions != nil {
+		in, out := &in.SELinuxOptions, &out.SELinuxOptions
+		*out = new(v1.SELinuxOptions)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodSecurity.
+func (in *PodSecurity) DeepCopy() *PodSecurity {
+	if in == nil {
+		return nil
+	}
+	out := new(PodSecurity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyViolation) DeepCopyInto(out *PolicyViolation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyViolation.
+func (in *PolicyViolation) DeepCopy() *PolicyViolation {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyViolation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegisteredServiceStatus) DeepCopyInto(out *RegisteredServiceStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegisteredServiceStatus.
+func (in *RegisteredServiceStatus) DeepCopy() *RegisteredServiceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RegisteredServiceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Registry) DeepCopyInto(out *Registry) {
+	*out = *in
+	if in.EnvVars != nil {
+		in, out := &in.EnvVars, &out.EnvVars
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.PodSecurity.DeepCopyInto(&out.PodSecurity)
+	in.GarbageCollection.DeepCopyInto(&out.GarbageCollection)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Registry.
+func (in *Registry) DeepCopy() *Registry {
+	if in == nil {
+		return nil
+	}
+	out := new(Registry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryGarbageCollection) DeepCopyInto(out *RegistryGarbageCollection) {
+	*out = *in
+	if in.GracePeriod != nil {
+		in, out := &in.GracePeriod, &out.GracePeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryGarbageCollection.
+func (in *RegistryGarbageCollection) DeepCopy() *RegistryGarbageCollection {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryGarbageCollection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RegistryStatus) DeepCopyInto(out *RegistryStatus) {
+	*out = *in
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make([]RegisteredServiceStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.UnregisteredForwarders != nil {
+		in, out := &in.UnregisteredForwarders, &out.UnregisteredForwarders
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RegistryStatus.
+func (in *RegistryStatus) DeepCopy() *RegistryStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RegistryStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Spiffe) DeepCopyInto(out *Spiffe) {
+	*out = *in
+	if in.Federation != nil {
+		in, out := &in.Federation, &out.Federation
+		*out = make([]FederatedDomain, len(*in))
+		copy(*out, *in)
+	}
+	if in.SVIDTTL != nil {
+		in, out := &in.SVIDTTL, &out.SVIDTTL
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Spiffe.
+func (in *Spiffe) DeepCopy() *Spiffe {
+	if in == nil {
+		return nil
+	}
+	out := new(Spiffe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Spire) DeepCopyInto(out *Spire) {
+	*out = *in
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.StorageSize != nil {
+		in, out := &in.StorageSize, &out.StorageSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Spire.
+func (in *Spire) DeepCopy() *Spire {
+	if in == nil {
+		return nil
+	}
+	out := new(Spire)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VL3Network) DeepCopyInto(out *VL3Network) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VL3Network.
+func (in *VL3Network) DeepCopy() *VL3Network {
+	if in == nil {
+		return nil
+	}
+	out := new(VL3Network)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VL3Network) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VL3NetworkList) DeepCopyInto(out *VL3NetworkList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]VL3Network, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VL3NetworkList.
+func (in *VL3NetworkList) DeepCopy() *VL3NetworkList {
+	if in == nil {
+		return nil
+	}
+	out := new(VL3NetworkList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *VL3NetworkList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VL3NetworkSpec) DeepCopyInto(out *VL3NetworkSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VL3NetworkSpec.
+func (in *VL3NetworkSpec) DeepCopy() *VL3NetworkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VL3NetworkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VL3NetworkStatus) DeepCopyInto(out *VL3NetworkStatus) {
+	*out = *in
+	if in.PlannedPrefixes != nil {
+		in, out := &in.PlannedPrefixes, &out.PlannedPrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VL3NetworkStatus.
+func (in *VL3NetworkStatus) DeepCopy() *VL3NetworkStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VL3NetworkStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Webhook) DeepCopyInto(out *Webhook) {
+	*out = *in
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EnvVars != nil {
+		in, out := &in.EnvVars, &out.EnvVars
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ClientLabels != nil {
+		in, out := &in.ClientLabels, &out.ClientLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ClientEnv != nil {
+		in, out := &in.ClientEnv, &out.ClientEnv
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ClientResources != nil {
+		in, out := &in.ClientResources, &out.ClientResources
+		*out = new(v1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	in.PodSecurity.DeepCopyInto(&out.PodSecurity)
+	if in.FailurePolicy != nil {
+		in, out := &in.FailurePolicy, &out.FailurePolicy
+		*out = new(admissionregistrationv1.FailurePolicyType)
+		**out = **in
+	}
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ObjectSelector != nil {
+		in, out := &in.ObjectSelector, &out.ObjectSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Webhook.
 func (in *Webhook) DeepCopy() *Webhook {
 	if in == nil {
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// PodDisruptionBudget of the admission-webhook-k8s pods
+const webhookPDBName string = "admission-webhook-k8s"
+
+// WebhookPDBReconciler keeps admission-webhook-k8s running through node
+// drains, the budget is removed together with the webhook
+type WebhookPDBReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func NewWebhookPDBReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme) *WebhookPDBReconciler {
+	return &WebhookPDBReconciler{
+		Client: client,
+		Log:    log,
+		Scheme: scheme,
+	}
+}
+
+func (r *WebhookPDBReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	// A budget for a single replica would only hold node drains back
+	if nsm.Spec.Webhook.Image == "" || *getWebhookReplicas(nsm) <= 1 {
+		return deleteComponentObjects(ctx, r.Client, r.Log, nsm,
+			&policyv1.PodDisruptionBudget{ObjectMeta: newObjectMeta(webhookPDBName, nsm.ObjectMeta.Namespace, nil)})
+	}
+
+	desired := r.podDisruptionBudgetForWebhook(nsm)
+	pdb := &policyv1.PodDisruptionBudget{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: webhookPDBName, Namespace: nsm.ObjectMeta.Namespace}, pdb)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		err = r.Client.Create(ctx, desired)
+		if err != nil {
+			r.Log.Error(err, "failed to create pod disruption budget for admission-webhook-k8s")
+			return err
+		}
+		r.Log.Info("admission-webhook-k8s pod disruption budget created")
+		return nil
+	}
+	if !isControlledAs(pdb, desired) {
+		return &notControlledError{kind: "pod disruption budget", name: webhookPDBName}
+	}
+	if !equality.Semantic.DeepEqual(pdb.Spec, desired.Spec) {
+		pdb.Spec = desired.Spec
+		err = r.Client.Update(ctx, pdb)
+		if err != nil {
+			r.Log.Error(err, "failed to update pod disruption budget of admission-webhook-k8s")
+			return err
+		}
+		r.Log.Info("admission-webhook-k8s pod disruption budget updated")
+	}
+	return nil
+}
+
+// Evictions take down one webhook pod at a time
+func (r *WebhookPDBReconciler) podDisruptionBudgetForWebhook(nsm *nsmv1alpha1.NSM) *policyv1.PodDisruptionBudget {
+
+	objectMeta := newObjectMeta(webhookPDBName, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"})
+	maxUnavailable := intstr.FromInt(1)
+
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: objectMeta,
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "admission-webhook-k8s"},
+			},
+		},
+	}
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, pdb, r.Scheme)
+	return pdb
+}
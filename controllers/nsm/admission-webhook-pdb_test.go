@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWebhookPDB(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	r := NewWebhookPDBReconciler(c, logr.Discard(), scheme)
+	name := types.NamespacedName{Name: webhookPDBName, Namespace: "nsm"}
+
+	tests := []struct {
+		name     string
+		image    string
+		replicas int32
+		// Change made to the budget ahead of the reconcile
+		edit    func(pdb *policyv1.PodDisruptionBudget)
+		wantPDB bool
+	}{
+		{name: "default replicas", image: "webhook", wantPDB: true},
+		{
+			name:  "edited budget restored",
+			image: "webhook",
+			edit: func(pdb *policyv1.PodDisruptionBudget) {
+				minAvailable := intstr.FromInt(2)
+				pdb.Spec.MaxUnavailable = nil
+				pdb.Spec.MinAvailable = &minAvailable
+			},
+			wantPDB: true,
+		},
+		{name: "single replica", image: "webhook", replicas: 1},
+		{name: "scaled out", image: "webhook", replicas: 3, wantPDB: true},
+		{name: "webhook disabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.edit != nil {
+				pdb := &policyv1.PodDisruptionBudget{}
+				if err := c.Get(context.TODO(), name, pdb); err != nil {
+					t.Fatal(err)
+				}
+				tt.edit(pdb)
+				if err := c.Update(context.TODO(), pdb); err != nil {
+					t.Fatal(err)
+				}
+			}
+			nsm.Spec.Webhook.Image = tt.image
+			nsm.Spec.Webhook.ReplicaCount = tt.replicas
+			if err := r.Reconcile(context.TODO(), nsm); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			pdb := &policyv1.PodDisruptionBudget{}
+			err := c.Get(context.TODO(), name, pdb)
+			if !tt.wantPDB {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("pod disruption budget kept: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pdb.Spec.MinAvailable != nil || pdb.Spec.MaxUnavailable == nil || pdb.Spec.MaxUnavailable.IntValue() != 1 {
+				t.Errorf("budget = %+v, want one unavailable pod at most", pdb.Spec)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"strings"
 
 	"github.com/go-logr/logr"
 	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
@@ -32,67 +33,49 @@ func NewWebhookReconciler(client client.Client, log logr.Logger, scheme *runtime
 
 func (r *WebhookReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
 
-	deploy := &appsv1.Deployment{}
-	err := r.Client.Get(ctx, types.NamespacedName{Name: "admission-webhook-k8s", Namespace: nsm.ObjectMeta.Namespace}, deploy)
+	servingCert, err := r.getServingCertificate(ctx, nsm)
 	if err != nil {
-		if apierrors.IsNotFound(err) {
-			deploy = r.DeploymentForWebhook(nsm)
-			err = r.Client.Create(context.TODO(), deploy)
-			if err != nil {
-				r.Log.Error(err, "failed to create deployment for admission-webhook-k8s")
-				return err
-			}
-			r.Log.Info("admission-webhook-k8s deployment created")
-			return nil
-		}
 		return err
 	}
-	r.Log.Info("admission-webhook-k8s deployment already exists, skipping creation")
-	return nil
+	_, err = reconcileComponentDeployment(ctx, r.Client, r.Log, r.DeploymentForWebhook(nsm, servingCert))
+	return err
 }
 
-func (r *WebhookReconciler) DeploymentForWebhook(nsm *nsmv1alpha1.NSM) *appsv1.Deployment {
+func (r *WebhookReconciler) DeploymentForWebhook(nsm *nsmv1alpha1.NSM, servingCert []byte) *appsv1.Deployment {
 
 	objectMeta := newObjectMeta("admission-webhook-k8s", "nsm", map[string]string{"app": "nsm"})
 	webhookLabel := map[string]string{"app": "admission-webhook-k8s"}
 
-	envVars := nsm.Spec.Webhook.EnvVars
-	if envVars == nil {
-		envVars = []corev1.EnvVar{
-			{Name: "NSM_SERVICE_NAME", Value: "admission-webhook-svc"},
-			{Name: "NSM_NAME", ValueFrom: &corev1.EnvVarSource{
-				FieldRef: &corev1.ObjectFieldSelector{
-					FieldPath: "metadata.name",
-				}}},
-			{Name: "NSM_NAMESPACE", ValueFrom: &corev1.EnvVarSource{
-				FieldRef: &corev1.ObjectFieldSelector{
-					FieldPath: "metadata.namespace",
-				}}},
-			{Name: "NSM_ANNOTATION", Value: "networkservicemesh.io"},
-			{Name: "NSM_CONTAINER_IMAGES", Value: "ghcr.io/networkservicemesh/cmd-nsc:" + nsm.Spec.Version},
-			{Name: "NSM_INIT_CONTAINER_IMAGES", Value: "ghcr.io/networkservicemesh/cmd-nsc-init:" + nsm.Spec.Version},
-			{Name: "NSM_LABELS", Value: "spiffe.io/spiffe-id:true"},
-			{Name: "NSM_ENVS", Value: "NSM_LOG_LEVEL=" + getNsmLogLevel(nsm)},
-		}
-	}
-
 	deploy := &appsv1.Deployment{
 		ObjectMeta: objectMeta,
 		Spec: appsv1.DeploymentSpec{
 			Selector: &metav1.LabelSelector{
 				MatchLabels: webhookLabel,
 			},
+			Replicas: getWebhookReplicas(nsm),
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: webhookLabel,
+					Labels:      webhookLabel,
+					Annotations: getAppArmorAnnotations(nsm.Spec.Webhook.PodSecurity, "admission-webhook-k8s"),
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName: serviceAccountName,
+					ServiceAccountName: webhookServiceAccountName,
+					SecurityContext:    getPodSecurityContext(nsm.Spec.Webhook.PodSecurity, true),
+					Affinity:           getWebhookAffinity(nsm, webhookLabel),
 					Containers: []corev1.Container{{
 						Name:            "admission-webhook-k8s",
 						Image:           nsm.Spec.Webhook.Image,
 						ImagePullPolicy: nsm.Spec.NsmPullPolicy,
-						Env:             insertSpireAgentSocketEnv(envVars, getSpireAgentSocket(nsm)),
+						Env:             getWebhookEnvVars(nsm),
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "spire-agent-socket",
+								MountPath: getSpireAgentSocketDir(nsm),
+								ReadOnly:  true,
+							},
+							{Name: "webhook-certs",
+								MountPath: webhookCertsDir,
+								ReadOnly:  true,
+							}},
 						ReadinessProbe: &corev1.Probe{
 							ProbeHandler: corev1.ProbeHandler{
 								HTTPGet: &corev1.HTTPGetAction{
@@ -103,11 +86,180 @@ func (r *WebhookReconciler) DeploymentForWebhook(nsm *nsmv1alpha1.NSM) *appsv1.D
 							},
 						},
 					}},
+					Volumes: []corev1.Volume{
+						getSpireAgentSocketVolume(nsm),
+						{
+							Name: "webhook-certs",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: webhookCertsSecretName,
+								}}},
+					},
 				},
 			},
 		},
 	}
+	// Roll the webhook pods to serve the rotated certificate
+	setConfigHash(&deploy.Spec.Template, string(servingCert))
+
 	// Set NSM instance as the owner and controller
 	controllerutil.SetControllerReference(nsm, deploy, r.Scheme)
 	return deploy
 }
+
+func getWebhookReplicas(nsm *nsmv1alpha1.NSM) *int32 {
+	replicas := int32(2)
+	if nsm.Spec.Webhook.ReplicaCount > 0 {
+		return &nsm.Spec.Webhook.ReplicaCount
+	}
+	return &replicas
+}
+
+// Get the affinity of the webhook pods, by default they prefer different
+// nodes so a node drain doesn't take all of them down at once
+func getWebhookAffinity(nsm *nsmv1alpha1.NSM, webhookLabel map[string]string) *corev1.Affinity {
+	if nsm.Spec.Webhook.Affinity != nil {
+		return nsm.Spec.Webhook.Affinity
+	}
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+				Weight: 100,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: webhookLabel},
+					TopologyKey:   corev1.LabelHostname,
+				},
+			}},
+		},
+	}
+}
+
+// Get the serving certificate of the webhook, nil until it's issued
+func (r *WebhookReconciler) getServingCertificate(ctx context.Context, nsm *nsmv1alpha1.NSM) ([]byte, error) {
+	secret := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: webhookCertsSecretName, Namespace: nsm.ObjectMeta.Namespace}, secret)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return secret.Data[corev1.TLSCertKey], nil
+}
+
+// admission-webhook-k8s serves the certificates generated by the operator
+// instead of registering its own MutatingWebhookConfiguration
+func insertWebhookCertsEnv(envVars []corev1.EnvVar) []corev1.EnvVar {
+	certsEnv := []corev1.EnvVar{
+		{Name: "NSM_WEBHOOK_MODE", Value: "secret"},
+		{Name: "NSM_CERT_FILE_PATH", Value: webhookCertsDir + "/" + corev1.TLSCertKey},
+		{Name: "NSM_KEY_FILE_PATH", Value: webhookCertsDir + "/" + corev1.TLSPrivateKeyKey},
+		{Name: "NSM_CA_BUNDLE_FILE_PATH", Value: webhookCertsDir + "/" + corev1.ServiceAccountRootCAKey},
+	}
+	result := append([]corev1.EnvVar{}, certsEnv...)
+	for _, envVar := range envVars {
+		overridden := false
+		for _, certEnv := range certsEnv {
+			if envVar.Name == certEnv.Name {
+				overridden = true
+			}
+		}
+		if !overridden {
+			result = append(result, envVar)
+		}
+	}
+	return result
+}
+
+// Render the client injection settings of the CR into the environment of
+// admission-webhook-k8s, the EnvVars of the CR override the rendered ones
+func getWebhookEnvVars(nsm *nsmv1alpha1.NSM) []corev1.EnvVar {
+
+	envVars := []corev1.EnvVar{
+		{Name: "NSM_SERVICE_NAME", Value: webhookServiceName},
+		{Name: "NSM_NAME", ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "metadata.name",
+			}}},
+		{Name: "NSM_NAMESPACE", ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "metadata.namespace",
+			}}},
+		{Name: "NSM_ANNOTATION", Value: getWebhookAnnotation(nsm)},
+		{Name: "NSM_CONTAINER_IMAGES", Value: nsm.Spec.Webhook.NSCImage},
+		{Name: "NSM_INIT_CONTAINER_IMAGES", Value: nsm.Spec.Webhook.NSCInitImage},
+		{Name: "NSM_LABELS", Value: getClientLabels(nsm)},
+		{Name: "NSM_ENVS", Value: getClientEnvs(nsm)},
+	}
+	// Injected clients get the Workload API through the same CSI driver
+	if nsm.Spec.Spiffe.CSIDriver {
+		envVars = append(envVars, corev1.EnvVar{Name: "NSM_SPIFFE_CSI_DRIVER", Value: spiffeCSIDriver})
+	}
+	envVars = append(envVars, getClientResourcesEnv(nsm)...)
+
+	envVars = mergeEnvVars(envVars, nsm.Spec.Webhook.EnvVars)
+	return insertSpireAgentSocketEnv(insertWebhookCertsEnv(envVars), getSpireAgentSocket(nsm))
+}
+
+// Get the pod annotation that triggers NSM client injection (default: "networkservicemesh.io")
+func getWebhookAnnotation(nsm *nsmv1alpha1.NSM) string {
+	if nsm.Spec.Webhook.Annotation != "" {
+		return nsm.Spec.Webhook.Annotation
+	}
+	for _, envVar := range nsm.Spec.Webhook.EnvVars {
+		if envVar.Name == "NSM_ANNOTATION" && envVar.Value != "" {
+			return envVar.Value
+		}
+	}
+	return "networkservicemesh.io"
+}
+
+// Get the labels added to the clients as "key:value" pairs, they always
+// carry the label selecting them for SPIFFE ID registration
+func getClientLabels(nsm *nsmv1alpha1.NSM) string {
+	labels := map[string]string{"spiffe.io/spiffe-id": "true"}
+	for key, value := range nsm.Spec.Webhook.ClientLabels {
+		labels[key] = value
+	}
+	return joinSorted(labels, ":")
+}
+
+// Get the environment of the injected containers as "NAME=value" pairs
+func getClientEnvs(nsm *nsmv1alpha1.NSM) string {
+	envs := map[string]string{"NSM_LOG_LEVEL": getNsmLogLevel(nsm)}
+	for name, value := range nsm.Spec.Webhook.ClientEnv {
+		envs[name] = value
+	}
+	return joinSorted(envs, "=")
+}
+
+// Get the resources of the injected containers, from version 1.7.0 the
+// webhook reads them from the NSM_SIDECAR_ environment variables
+func getClientResourcesEnv(nsm *nsmv1alpha1.NSM) []corev1.EnvVar {
+	resources := nsm.Spec.Webhook.ClientResources
+	if resources == nil || compareVersions(nsm.Spec.Version, "v1.7.0") < 0 {
+		return nil
+	}
+	envVars := []corev1.EnvVar{}
+	for _, setting := range []struct {
+		name  string
+		value corev1.ResourceList
+	}{{"NSM_SIDECAR_LIMITS", resources.Limits}, {"NSM_SIDECAR_REQUESTS", resources.Requests}} {
+		if quantity, ok := setting.value[corev1.ResourceCPU]; ok {
+			envVars = append(envVars, corev1.EnvVar{Name: setting.name + "_CPU", Value: quantity.String()})
+		}
+		if quantity, ok := setting.value[corev1.ResourceMemory]; ok {
+			envVars = append(envVars, corev1.EnvVar{Name: setting.name + "_MEMORY", Value: quantity.String()})
+		}
+	}
+	return envVars
+}
+
+// Join the pairs of a map sorted by key, so the rendered value is stable
+func joinSorted(pairs map[string]string, separator string) string {
+	joined := []string{}
+	for _, key := range getSortedKeys(pairs) {
+		joined = append(joined, key+separator+pairs[key])
+	}
+	return strings.Join(joined, ",")
+}
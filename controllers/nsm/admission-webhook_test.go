@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetClientResourcesEnv(t *testing.T) {
+	resources := &corev1.ResourceRequirements{
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m")},
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("40Mi")},
+	}
+	tests := []struct {
+		version   string
+		resources *corev1.ResourceRequirements
+		envVars   int
+	}{
+		{"v1.6.1", resources, 0},
+		{"v1.7.0", resources, 2},
+		{"v1.8.0", resources, 2},
+		{"v1.10.0", resources, 2},
+		{"v2.0.0", resources, 2},
+		{"v1.10.0", nil, 0},
+	}
+	for _, test := range tests {
+		nsm := &nsmv1alpha1.NSM{}
+		nsm.Spec.Version = test.version
+		nsm.Spec.Webhook.ClientResources = test.resources
+		if envVars := getClientResourcesEnv(nsm); len(envVars) != test.envVars {
+			t.Errorf("version %s: got %v, want %d environment variables", test.version, envVars, test.envVars)
+		}
+	}
+}
+
+func TestWebhookRolling(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	nsm.Spec.Webhook.Image = "cmd-admission-webhook-k8s:v1.6.1"
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: webhookCertsSecretName, Namespace: "nsm"},
+		Data:       map[string][]byte{corev1.TLSCertKey: []byte("cert")},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+
+	reconcile := func() *appsv1.Deployment {
+		if err := NewWebhookReconciler(c, logr.Discard(), scheme).Reconcile(context.TODO(), nsm); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		deploy := &appsv1.Deployment{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: "admission-webhook-k8s", Namespace: "nsm"}, deploy); err != nil {
+			t.Fatal(err)
+		}
+		return deploy
+	}
+	hash := reconcile().Spec.Template.Annotations[configHashAnnotation]
+
+	tests := []struct {
+		name   string
+		update func()
+		rolled bool
+	}{
+		{"unchanged", func() {}, false},
+		{"certificate rotated", func() {
+			secret.Data[corev1.TLSCertKey] = []byte("rotated")
+			if err := c.Update(context.TODO(), secret); err != nil {
+				t.Fatal(err)
+			}
+		}, true},
+		{"pod security", func() { nsm.Spec.Webhook.PodSecurity.AppArmorProfile = "runtime/default" }, true},
+		{"affinity", func() { nsm.Spec.Webhook.Affinity = &corev1.Affinity{} }, true},
+		{"image", func() { nsm.Spec.Webhook.Image = "cmd-admission-webhook-k8s:v1.7.0" }, true},
+	}
+	for _, tt := range tests {
+		tt.update()
+		deploy := reconcile()
+		got := deploy.Spec.Template.Annotations[configHashAnnotation]
+		if rolled := got != hash; rolled != tt.rolled {
+			t.Errorf("%s: rolled = %v, want %v", tt.name, rolled, tt.rolled)
+		}
+		hash = got
+	}
+	if image := reconcile().Spec.Template.Spec.Containers[0].Image; image != nsm.Spec.Webhook.Image {
+		t.Errorf("image = %s, want %s", image, nsm.Spec.Webhook.Image)
+	}
+}
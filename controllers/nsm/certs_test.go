@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCertificateNeedsRenewal(t *testing.T) {
+	caCert, caKey, err := newCA("nsm-webhook-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCACert, otherCAKey, err := newCA("other-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _, err := newServingCertificate(caCert, caKey, "nsm-admission-webhook", []string{"nsm-admission-webhook.nsm.svc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCert, _, err := newServingCertificate(otherCACert, otherCAKey, "nsm-admission-webhook", []string{"nsm-admission-webhook.nsm.svc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		certPEM     []byte
+		caCertPEM   []byte
+		renewBefore time.Duration
+		want        bool
+	}{
+		{name: "valid", certPEM: cert, caCertPEM: caCert, renewBefore: webhookCertRenewBefore},
+		{name: "valid without CA check", certPEM: cert, renewBefore: webhookCertRenewBefore},
+		{name: "valid CA", certPEM: caCert, renewBefore: webhookCARenewBefore},
+		{name: "missing", caCertPEM: caCert, renewBefore: webhookCertRenewBefore, want: true},
+		{name: "unreadable", certPEM: []byte("not a certificate"), caCertPEM: caCert, renewBefore: webhookCertRenewBefore, want: true},
+		{name: "about to expire", certPEM: cert, caCertPEM: caCert, renewBefore: webhookCertValidity, want: true},
+		{name: "issued by another CA", certPEM: otherCert, caCertPEM: caCert, renewBefore: webhookCertRenewBefore, want: true},
+		{name: "unreadable CA", certPEM: cert, caCertPEM: []byte("not a certificate"), renewBefore: webhookCertRenewBefore, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := certificateNeedsRenewal(tt.certPEM, tt.caCertPEM, tt.renewBefore); got != tt.want {
+				t.Errorf("certificateNeedsRenewal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
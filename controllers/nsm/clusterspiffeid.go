@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const spiffeIDTemplate string = "spiffe://{{ .TrustDomain }}/ns/{{ .PodMeta.Namespace }}/sa/{{ .PodSpec.ServiceAccountName }}"
+
+// ClusterSPIFFEID is served by spire-controller-manager, which may not be
+// installed, so it is handled as an unstructured object
+var clusterSPIFFEIDGVK = schema.GroupVersionKind{Group: "spire.spiffe.io", Version: "v1alpha1", Kind: "ClusterSPIFFEID"}
+
+// SpiffeIDReconciler registers the NSM workloads with SPIRE through
+// ClusterSPIFFEID resources when spire-controller-manager is available
+type SpiffeIDReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func NewSpiffeIDReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme) *SpiffeIDReconciler {
+	return &SpiffeIDReconciler{
+		Client: client,
+		Log:    log,
+		Scheme: scheme,
+	}
+}
+
+func (r *SpiffeIDReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	_, err := r.Client.RESTMapper().RESTMapping(clusterSPIFFEIDGVK.GroupKind(), clusterSPIFFEIDGVK.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			r.Log.Info("ClusterSPIFFEID resources not available, skipping spire registration")
+			return nil
+		}
+		return err
+	}
+
+	for _, desired := range r.clusterSPIFFEIDsForNSM(nsm) {
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(clusterSPIFFEIDGVK)
+		err := r.Client.Get(ctx, types.NamespacedName{Name: desired.GetName()}, current)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			err = r.Client.Create(ctx, desired)
+			if err != nil {
+				r.Log.Error(err, "failed to create ClusterSPIFFEID "+desired.GetName())
+				return err
+			}
+			r.Log.Info("ClusterSPIFFEID " + desired.GetName() + " created")
+			continue
+		}
+
+		if !specContains(current, desired) {
+			current.Object["spec"] = desired.Object["spec"]
+			err = r.Client.Update(ctx, current)
+			if err != nil {
+				r.Log.Error(err, "failed to update ClusterSPIFFEID "+desired.GetName())
+				return err
+			}
+			r.Log.Info("ClusterSPIFFEID " + desired.GetName() + " updated")
+		}
+	}
+	return nil
+}
+
+// ClusterSPIFFEIDs are cluster scoped, they carry the owner labels
+// of the NSM CR so its finalizer can remove them
+func (r *SpiffeIDReconciler) clusterSPIFFEIDsForNSM(nsm *nsmv1alpha1.NSM) []*unstructured.Unstructured {
+
+	namespace := nsm.ObjectMeta.Namespace
+	inNamespace := map[string]interface{}{
+		"matchLabels": map[string]interface{}{"kubernetes.io/metadata.name": namespace},
+	}
+
+	// name suffix and app label of the NSM components
+	components := [][2]string{
+		{"nsmgr", "nsmgr"},
+		{"forwarder", "forwarder"},
+		{"nsm-registry", "nsm-registry"},
+	}
+	if nsm.Spec.Webhook.Image != "" {
+		components = append(components, [2]string{"admission-webhook", "admission-webhook-k8s"})
+	}
+
+	objects := []*unstructured.Unstructured{}
+	apps := []interface{}{}
+	for _, component := range components {
+		objects = append(objects, newClusterSPIFFEID(nsm, namespace+"-"+component[0], map[string]interface{}{
+			"spiffeIDTemplate":  getSpiffeIDTemplate(nsm),
+			"podSelector":       map[string]interface{}{"matchLabels": map[string]interface{}{"app": component[1]}},
+			"namespaceSelector": inNamespace,
+		}))
+		apps = append(apps, component[1])
+	}
+
+	// The other workloads of the NSM namespace, such as nsmgr-proxy,
+	// registry-proxy-dns, the floating registry, the NSMEndpoints and
+	// the vL3 networks, are labeled for SPIFFE registration
+	objects = append(objects, newClusterSPIFFEID(nsm, namespace+"-workloads", map[string]interface{}{
+		"spiffeIDTemplate": getSpiffeIDTemplate(nsm),
+		"podSelector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{"spiffe.io/spiffe-id": "true"},
+			"matchExpressions": []interface{}{map[string]interface{}{
+				"key":      "app",
+				"operator": "NotIn",
+				"values":   apps,
+			}},
+		},
+		"namespaceSelector": inNamespace,
+	}))
+
+	// NSCs injected by the webhook and NSEs are labeled for SPIFFE
+	// registration in the namespaces the clients are injected in,
+	// the NSM namespace has its own entry
+	clientNamespaces := getClientNamespaceSelector(nsm, nil)
+	if nsm.Spec.Webhook.NamespaceSelector != nil {
+		clientNamespaces.MatchExpressions = append(clientNamespaces.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      "kubernetes.io/metadata.name",
+			Operator: metav1.LabelSelectorOpNotIn,
+			Values:   []string{namespace},
+		})
+	}
+	namespaceSelector, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(clientNamespaces)
+	objects = append(objects, newClusterSPIFFEID(nsm, namespace+"-nsm-workloads", map[string]interface{}{
+		"spiffeIDTemplate":  getSpiffeIDTemplate(nsm),
+		"podSelector":       map[string]interface{}{"matchLabels": map[string]interface{}{"spiffe.io/spiffe-id": "true"}},
+		"namespaceSelector": namespaceSelector,
+	}))
+	return objects
+}
+
+func newClusterSPIFFEID(nsm *nsmv1alpha1.NSM, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	if nsm.Spec.Spiffe.SVIDTTL != nil {
+		spec["ttl"] = nsm.Spec.Spiffe.SVIDTTL.Duration.String()
+	}
+	if federatesWith := getFederatesWith(nsm); len(federatesWith) > 0 {
+		spec["federatesWith"] = toInterfaceSlice(federatesWith)
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetGroupVersionKind(clusterSPIFFEIDGVK)
+	obj.SetName(name)
+	obj.SetLabels(getOwnerLabels(nsm))
+	return obj
+}
+
+// Get the SPIFFE ID template for NSM workloads, with the trust domain
+// filled in when it's defined in the CR or by the SPIRE it deploys
+func getSpiffeIDTemplate(nsm *nsmv1alpha1.NSM) string {
+	template := nsm.Spec.Spiffe.IDTemplate
+	if template == "" {
+		template = spiffeIDTemplate
+	}
+	if trustDomain := getTrustDomain(nsm); trustDomain != "" {
+		template = strings.ReplaceAll(template, "{{ .TrustDomain }}", trustDomain)
+	}
+	return template
+}
+
+// Check that the spec of the current object holds all the desired fields,
+// ignoring the ones defaulted by the API server. The optional fields
+// set from the CR must be gone from the current spec when they are unset.
+func specContains(current, desired *unstructured.Unstructured) bool {
+	currentSpec, _, _ := unstructured.NestedMap(current.Object, "spec")
+	desiredSpec, _, _ := unstructured.NestedMap(desired.Object, "spec")
+	for key, value := range desiredSpec {
+		if !equality.Semantic.DeepEqual(currentSpec[key], value) {
+			return false
+		}
+	}
+	for _, key := range []string{"ttl", "federatesWith"} {
+		if _, ok := desiredSpec[key]; !ok && currentSpec[key] != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func toInterfaceSlice(values []string) []interface{} {
+	items := []interface{}{}
+	for _, value := range values {
+		items = append(items, value)
+	}
+	return items
+}
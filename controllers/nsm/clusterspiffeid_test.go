@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestClusterSPIFFEIDsForNSM(t *testing.T) {
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+	nsm.Spec.Webhook.Image = "cmd-admission-webhook-k8s"
+
+	objects := map[string]*unstructured.Unstructured{}
+	for _, obj := range (&SpiffeIDReconciler{}).clusterSPIFFEIDsForNSM(nsm) {
+		objects[obj.GetName()] = obj
+	}
+	for _, name := range []string{"nsm-nsmgr", "nsm-forwarder", "nsm-nsm-registry", "nsm-admission-webhook", "nsm-workloads", "nsm-nsm-workloads"} {
+		if _, ok := objects[name]; !ok {
+			t.Errorf("missing ClusterSPIFFEID %s", name)
+		}
+	}
+
+	// The labeled workloads of the NSM namespace, other than the
+	// components with their own entry, are registered as well
+	if obj, ok := objects["nsm-workloads"]; ok {
+		namespace, _, _ := unstructured.NestedString(obj.Object, "spec", "namespaceSelector", "matchLabels", "kubernetes.io/metadata.name")
+		if namespace != "nsm" {
+			t.Errorf("nsm-workloads selects namespace %q", namespace)
+		}
+		expressions, _, _ := unstructured.NestedSlice(obj.Object, "spec", "podSelector", "matchExpressions")
+		if len(expressions) != 1 {
+			t.Fatalf("nsm-workloads pod selector expressions: %v", expressions)
+		}
+		excluded := expressions[0].(map[string]interface{})["values"].([]interface{})
+		if len(excluded) != 4 {
+			t.Errorf("nsm-workloads excludes %v", excluded)
+		}
+	}
+}
+
+func TestGetSpiffeIDTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		trustDomain string
+		spire       bool
+		want        string
+	}{
+		{name: "trust domain of spire-controller-manager", want: spiffeIDTemplate},
+		{name: "trust domain of the CR", trustDomain: "nsm.example.org", want: "spiffe://nsm.example.org/ns/{{ .PodMeta.Namespace }}/sa/{{ .PodSpec.ServiceAccountName }}"},
+		{name: "trust domain of the SPIRE deployed by the operator", spire: true, want: "spiffe://" + spireTrustDomain + "/ns/{{ .PodMeta.Namespace }}/sa/{{ .PodSpec.ServiceAccountName }}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsm := &nsmv1alpha1.NSM{}
+			nsm.Spec.Spiffe.TrustDomain = tt.trustDomain
+			nsm.Spec.Spire.Enabled = tt.spire
+			if got := getSpiffeIDTemplate(nsm); got != tt.want {
+				t.Errorf("getSpiffeIDTemplate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNSMWorkloadsNamespaceSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector *metav1.LabelSelector
+		want     *metav1.LabelSelector
+	}{
+		{
+			name: "default",
+			want: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "kubernetes.io/metadata.name", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"kube-system", "nsm"}},
+			}},
+		},
+		{
+			// Only the namespaces the clients are injected in
+			name:     "webhook namespace selector",
+			selector: &metav1.LabelSelector{MatchLabels: map[string]string{"nsm-injection": "enabled"}},
+			want: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"nsm-injection": "enabled"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "kubernetes.io/metadata.name", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"nsm"}},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+			nsm.Spec.Webhook.NamespaceSelector = tt.selector
+			var workloads *unstructured.Unstructured
+			for _, obj := range (&SpiffeIDReconciler{}).clusterSPIFFEIDsForNSM(nsm) {
+				if obj.GetName() == "nsm-nsm-workloads" {
+					workloads = obj
+				}
+			}
+			if workloads == nil {
+				t.Fatalf("missing ClusterSPIFFEID nsm-nsm-workloads")
+			}
+			namespaceSelector, _, _ := unstructured.NestedMap(workloads.Object, "spec", "namespaceSelector")
+			got := &metav1.LabelSelector{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(namespaceSelector, got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("namespace selector = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Annotation rolling the pods of the workloads rendered by the operator when their spec changes
+const configHashAnnotation string = "nsm.networkservicemesh.io/config-hash"
+
+func getConfigHash(configs ...string) string {
+	hash := sha256.Sum256([]byte(strings.Join(configs, "\n")))
+	return hex.EncodeToString(hash[:])
+}
+
+// Roll the pods when anything rendered into their template changes, or
+// the configurations they read from outside of it, like config maps
+func setConfigHash(template *corev1.PodTemplateSpec, configs ...string) {
+	rendered, _ := json.Marshal(template)
+	template.Annotations = mergeAnnotations(template.Annotations,
+		map[string]string{configHashAnnotation: getConfigHash(append([]string{string(rendered)}, configs...)...)})
+}
+
+// Check whether the template rendered for a workload differs from the
+// one it runs, the other fields are defaulted by the API server
+func templateChanged(current, desired corev1.PodTemplateSpec) bool {
+	return current.Annotations[configHashAnnotation] != desired.Annotations[configHashAnnotation]
+}
+
+// Create the deployment or roll it when its rendered spec changes
+func reconcileComponentDeployment(ctx context.Context, c client.Client, log logr.Logger, desired *appsv1.Deployment) (*appsv1.Deployment, error) {
+
+	deploy := &appsv1.Deployment{}
+	err := c.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, deploy)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		err = c.Create(ctx, desired)
+		if err != nil {
+			log.Error(err, "failed to create deployment for "+desired.Name)
+			return nil, err
+		}
+		log.Info(desired.Name + " deployment created")
+		return desired, nil
+	}
+	if !isControlledAs(deploy, desired) {
+		return nil, &notControlledError{kind: "deployment", name: desired.Name}
+	}
+	if *deploy.Spec.Replicas != *desired.Spec.Replicas || templateChanged(deploy.Spec.Template, desired.Spec.Template) {
+		deploy.Spec.Replicas = desired.Spec.Replicas
+		deploy.Spec.Template = desired.Spec.Template
+		err = c.Update(ctx, deploy)
+		if err != nil {
+			log.Error(err, "failed to update deployment of "+desired.Name)
+			return nil, err
+		}
+		log.Info(desired.Name + " deployment updated")
+	}
+	return deploy, nil
+}
+
+// Create the daemonset or roll it when its rendered spec changes
+func reconcileComponentDaemonSet(ctx context.Context, c client.Client, log logr.Logger, desired *appsv1.DaemonSet) error {
+
+	ds := &appsv1.DaemonSet{}
+	err := c.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, ds)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		err = c.Create(ctx, desired)
+		if err != nil {
+			log.Error(err, "failed to create daemonset for "+desired.Name)
+			return err
+		}
+		log.Info(desired.Name + " daemonset created")
+		return nil
+	}
+	if !isControlledAs(ds, desired) {
+		return &notControlledError{kind: "daemonset", name: desired.Name}
+	}
+	if templateChanged(ds.Spec.Template, desired.Spec.Template) {
+		ds.Spec.Template = desired.Spec.Template
+		err = c.Update(ctx, ds)
+		if err != nil {
+			log.Error(err, "failed to update daemonset of "+desired.Name)
+			return err
+		}
+		log.Info(desired.Name + " daemonset updated")
+	}
+	return nil
+}
+
+// Delete the objects of a disabled component, the ones
+// controlled by other owners are left untouched
+func deleteComponentObjects(ctx context.Context, c client.Client, log logr.Logger, nsm *nsmv1alpha1.NSM, objs ...client.Object) error {
+
+	for _, obj := range objs {
+		err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !metav1.IsControlledBy(obj, nsm) {
+			continue
+		}
+		if err := c.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			log.Error(err, "failed to delete "+obj.GetName())
+			return err
+		}
+		log.Info(obj.GetName() + " deleted")
+	}
+	return nil
+}
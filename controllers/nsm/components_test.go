@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestSetConfigHash(t *testing.T) {
+	template := func(image string) *corev1.PodTemplateSpec {
+		return &corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "c", Image: image}}}}
+	}
+	hash := func(template *corev1.PodTemplateSpec, configs ...string) string {
+		setConfigHash(template, configs...)
+		return template.Annotations[configHashAnnotation]
+	}
+
+	base := hash(template("image:v1"))
+	if base == "" {
+		t.Fatalf("setConfigHash() didn't set %s", configHashAnnotation)
+	}
+	if got := hash(template("image:v1")); got != base {
+		t.Errorf("same template hashed to %s, want %s", got, base)
+	}
+	if got := hash(template("image:v2")); got == base {
+		t.Errorf("changed image didn't change the hash")
+	}
+	if got := hash(template("image:v1"), "config"); got == base {
+		t.Errorf("configuration didn't change the hash")
+	}
+}
+
+func TestReconcileComponentDeployment(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	other := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "nsm", UID: "other-uid"}}
+	deployment := func(owner *nsmv1alpha1.NSM, name string, replicas int32, image string) *appsv1.Deployment {
+		deploy := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "nsm"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: name, Image: image}}}},
+			},
+		}
+		setConfigHash(&deploy.Spec.Template)
+		_ = controllerutil.SetControllerReference(owner, deploy, scheme)
+		return deploy
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deployment(other, "taken", 1, "image:v1")).Build()
+
+	tests := []struct {
+		name          string
+		desired       *appsv1.Deployment
+		wantImage     string
+		wantReplicas  int32
+		notControlled bool
+	}{
+		{name: "created", desired: deployment(nsm, "component", 1, "image:v1"), wantImage: "image:v1", wantReplicas: 1},
+		{name: "scaled", desired: deployment(nsm, "component", 2, "image:v1"), wantImage: "image:v1", wantReplicas: 2},
+		{name: "rolled", desired: deployment(nsm, "component", 2, "image:v2"), wantImage: "image:v2", wantReplicas: 2},
+		{name: "controlled by another owner", desired: deployment(nsm, "taken", 2, "image:v2"), wantImage: "image:v1", wantReplicas: 1, notControlled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := reconcileComponentDeployment(context.TODO(), c, logr.Discard(), tt.desired)
+			if tt.notControlled != isNotControlled(err) || (!tt.notControlled && err != nil) {
+				t.Fatalf("reconcileComponentDeployment() error = %v, want not controlled %v", err, tt.notControlled)
+			}
+			deploy := &appsv1.Deployment{}
+			if err := c.Get(context.TODO(), types.NamespacedName{Name: tt.desired.Name, Namespace: "nsm"}, deploy); err != nil {
+				t.Fatal(err)
+			}
+			if image := deploy.Spec.Template.Spec.Containers[0].Image; image != tt.wantImage {
+				t.Errorf("image = %s, want %s", image, tt.wantImage)
+			}
+			if *deploy.Spec.Replicas != tt.wantReplicas {
+				t.Errorf("replicas = %d, want %d", *deploy.Spec.Replicas, tt.wantReplicas)
+			}
+		})
+	}
+}
@@ -18,14 +18,28 @@ package controllers
 
 import (
 	"context"
+	"path"
+	"strings"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
 )
@@ -34,26 +48,81 @@ import (
 type NSMReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// Platform detected by the operator at startup
+	Platform nsmv1alpha1.Platform
+	// Uncached reader for cluster wide lookups
+	APIReader client.Reader
+	// Recorder of the events of the NSM instances
+	Recorder record.EventRecorder
+	// Set when the NSM instance is reconciled from a fleet hub, no
+	// operator runs next to it to serve the policy webhooks
+	HubDriven bool
+
+	controller controller.Controller
+	// Registration kinds watched once their CRDs are served
+	registryWatches map[schema.GroupVersionKind]bool
+	// SCC access of the NSM client service accounts
+	sccReviews sccReviewCache
+	// Cluster wide cache of the injection policies and the client workloads
+	clusterCache cache.Cache
 }
 
 // +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=nsms,verbs=get;list;watch;create;update;patch;delete,namespace=nsm
 // +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=nsms/status,verbs=get;update;patch,namespace=nsm
-// +kubebuilder:rbac:groups=apps,resources=daemonsets;deployments;replicasets,verbs=get;list;watch;create;update;patch;delete,namespace=nsm
+// +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=nsms/finalizers,verbs=update,namespace=nsm
+// +kubebuilder:rbac:groups=apps,resources=daemonsets;deployments;replicasets;statefulsets,verbs=get;list;watch;create;update;patch;delete,namespace=nsm
 // +kubebuilder:rbac:groups=apps,resourceNames=nsm-operator,resources=deployments/finalizers,verbs=update,namespace=nsm
 // +kubebuilder:rbac:groups=core,resources=secrets;services;services/finalizers;configmaps;events;persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete,namespace=nsm
 // +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;create,namespace=nsm
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get,namespace=nsm
-// +kubebuilder:rbac:groups=admissionregistration,resources=mutatingwebhookconfigurations;mutatingwebhookconfigurations/finalizers,verbs=get;list;watch;create;update;patch;delete
-
-// +kubebuilder:rbac:groups="*",resources="*",verbs="*"
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=mutatingwebhookconfigurations,verbs=get;list;watch;create;update;patch;delete;deletecollection
+// +kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list
+// +kubebuilder:rbac:groups=apps,resources=daemonsets;deployments;statefulsets,verbs=list;watch
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete,namespace=nsm
+// +kubebuilder:rbac:groups=security.openshift.io,resources=securitycontextconstraints,resourceNames=privileged,verbs=use
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+// +kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete,namespace=nsm
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=get;list;watch;create;update;patch;delete;deletecollection
+// +kubebuilder:rbac:groups=networkservicemesh.io,resources=networkservices;networkserviceendpoints,verbs=get;list;watch;create;update;patch;delete,namespace=nsm
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=spire.spiffe.io,resources=clusterspiffeids,verbs=get;list;watch;create;update;patch;delete;deletecollection
+// +kubebuilder:rbac:groups=core,resources=pods;nodes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=configmaps;services,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=nodes/proxy,verbs=get
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=storage.k8s.io,resources=csinodes,verbs=list
+// +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=nsminjectionpolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=nsminjectionpolicies/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete,namespace=nsm
+// +kubebuilder:rbac:groups=cert-manager.io,resources=issuers;certificates,verbs=get;list;watch;create;update;patch;delete,namespace=nsm
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,resourceNames=networkservices.networkservicemesh.io;networkserviceendpoints.networkservicemesh.io,verbs=delete
+// +kubebuilder:rbac:groups=networkservicemesh.io,resources=networkservices;networkserviceendpoints,verbs=list
 
 const (
-	serviceAccountName  string = "nsm-operator"
 	registryMemoryImage string = "ghcr.io/networkservicemesh/cmd-registry-memory"
 	registryK8sImage    string = "ghcr.io/networkservicemesh/cmd-registry-k8s"
 	nsmgrImage          string = "ghcr.io/networkservicemesh/cmd-nsmgr"
 	exclPrefImage       string = "ghcr.io/networkservicemesh/cmd-exclude-prefixes-k8s"
 	forwarderImage      string = "ghcr.io/networkservicemesh/cmd-forwarder-"
+	nscImage            string = "ghcr.io/networkservicemesh/cmd-nsc"
+	nscInitImage        string = "ghcr.io/networkservicemesh/cmd-nsc-init"
+	nsmgrProxyImage     string = "ghcr.io/networkservicemesh/cmd-nsmgr-proxy"
+	registryProxyImage  string = "ghcr.io/networkservicemesh/cmd-registry-proxy-dns"
+	mapIPImage          string = "ghcr.io/networkservicemesh/cmd-map-ip-k8s"
+	spireServerImage    string = "gcr.io/spiffe-io/spire-server:1.2.3"
+	spireAgentImage     string = "gcr.io/spiffe-io/spire-agent:1.2.3"
+	spireRegistrarImage string = "gcr.io/spiffe-io/k8s-workload-registrar:1.2.3"
+)
+
+// Default SPIRE agent socket and host directories mounted into NSM components
+const (
+	spireAgentSocket    string = "unix:///run/spire/sockets/agent.sock"
+	spireAgentSocketDir string = "/run/spire/sockets"
+	kubeletHostPath     string = "/var/lib/kubelet"
+	nsmSocketHostPath   string = "/var/lib/networkservicemesh"
+	cgroupHostPath      string = "/sys/fs/cgroup"
+	vfioHostPath        string = "/dev/vfio"
 )
 
 // Reconcile for NSMs
@@ -76,6 +145,34 @@ func (r *NSMReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, err
 	}
 
+	// Clean up the cluster scoped resources before the NSM instance goes away
+	if !nsm.ObjectMeta.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(nsm, nsmFinalizer) {
+			if nsm.Status.Phase != nsmv1alpha1.NSMPhaseTerminating {
+				nsm.Status.Phase = nsmv1alpha1.NSMPhaseTerminating
+				if updateErr := r.Client.Status().Update(ctx, nsm); updateErr != nil {
+					Log.Info("Failed to update status", "Error", updateErr.Error())
+				}
+			}
+			if err := r.finalize(ctx, nsm); err != nil {
+				Log.Error(err, "error while finalizing")
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(nsm, nsmFinalizer)
+			if err := r.Client.Update(ctx, nsm); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(nsm, nsmFinalizer) {
+		controllerutil.AddFinalizer(nsm, nsmFinalizer)
+		if err := r.Client.Update(ctx, nsm); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Update the status field to creating
 	if nsm.Status.Phase == nsmv1alpha1.NSMPhaseInitial {
 		nsm.Status.Phase = nsmv1alpha1.NSMPhaseCreating
@@ -84,6 +181,36 @@ func (r *NSMReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		}
 	}
 
+	status := nsm.Status.DeepCopy()
+
+	// setting up platform specific defaults
+	platform := getPlatform(nsm, r.Platform)
+	applyPlatformProfile(nsm, platform)
+
+	// SPIFFE settings that don't fit together would
+	// leave NSM components without valid identities
+	err = validateSpiffe(nsm)
+	meta.SetStatusCondition(&nsm.Status.Conditions, getSpiffeCondition(err))
+	if err != nil {
+		Log.Error(err, "invalid SPIFFE settings, waiting for the NSM CR to be fixed")
+		nsm.Status.Phase = nsmv1alpha1.NSMPhasePending
+		nsm.Status.Platform = platform
+		if !equality.Semantic.DeepEqual(status, &nsm.Status) {
+			if updateErr := r.Client.Status().Update(context.TODO(), nsm); updateErr != nil {
+				Log.Info("Failed to update status", "Error", updateErr.Error())
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Inconsistent network services are left as they are registered
+	// while the rest of the NSM components are still reconciled
+	servicesErr := validateNetworkServices(nsm)
+	meta.SetStatusCondition(&nsm.Status.Conditions, getNetworkServicesCondition(servicesErr))
+	if servicesErr != nil {
+		Log.Error(servicesErr, "invalid network services, waiting for the NSM CR to be fixed")
+	}
+
 	// setting up default images for registry
 	if nsm.Spec.Registry.Image == "" {
 		switch nsm.Spec.Registry.Type {
@@ -102,19 +229,163 @@ func (r *NSMReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		nsm.Spec.ExclPref.Image = exclPrefImage + ":" + nsm.Spec.Version
 	}
 
+	// setting up default images of the injected clients
+	if nsm.Spec.Webhook.NSCImage == "" {
+		nsm.Spec.Webhook.NSCImage = nscImage + ":" + nsm.Spec.Version
+	}
+	if nsm.Spec.Webhook.NSCInitImage == "" {
+		nsm.Spec.Webhook.NSCInitImage = nscInitImage + ":" + nsm.Spec.Version
+	}
+
+	// setting up default images of the interdomain components
+	if nsm.Spec.Interdomain.NsmgrProxy.Image == "" {
+		nsm.Spec.Interdomain.NsmgrProxy.Image = nsmgrProxyImage + ":" + nsm.Spec.Version
+	}
+	if nsm.Spec.Interdomain.RegistryProxyDNS.Image == "" {
+		nsm.Spec.Interdomain.RegistryProxyDNS.Image = registryProxyImage + ":" + nsm.Spec.Version
+	}
+	if nsm.Spec.FloatingRegistry.Image == "" {
+		nsm.Spec.FloatingRegistry.Image = registryMemoryImage + ":" + nsm.Spec.Version
+	}
+	if nsm.Spec.Interdomain.MapIPImage == "" {
+		nsm.Spec.Interdomain.MapIPImage = mapIPImage + ":" + nsm.Spec.Version
+	}
+
+	// setting up default images for SPIRE
+	if nsm.Spec.Spire.Enabled {
+		if nsm.Spec.Spire.ServerImage == "" {
+			nsm.Spec.Spire.ServerImage = spireServerImage
+		}
+		if nsm.Spec.Spire.AgentImage == "" {
+			nsm.Spec.Spire.AgentImage = spireAgentImage
+		}
+		if nsm.Spec.Spire.RegistrarImage == "" {
+			nsm.Spec.Spire.RegistrarImage = spireRegistrarImage
+		}
+	}
+
+	dependencies := []Reconciler{
+		NewRBACReconciler(r.Client, Log, r.Scheme),
+	}
+
+	// Install the CRDs registry-k8s stores the registrations in
+	if nsm.Spec.Registry.Type == "k8s" {
+		dependencies = append(dependencies,
+			NewCRDReconciler(r.Client, Log, r.Scheme))
+	}
+
+	// Deploy SPIRE ahead of the NSM components on demand
+	if nsm.Spec.Spire.Enabled {
+		dependencies = append(dependencies,
+			NewSpireReconciler(r.Client, Log, r.Scheme))
+	}
+
+	for _, r := range dependencies {
+		err := r.Reconcile(ctx, nsm)
+		if err != nil {
+			Log.Error(err, "error while reconciling")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// NSM components crash without the SPIRE agent socket,
+	// hold them back until SPIRE is ready
+	ready, message, err := r.spireReady(ctx, nsm)
+	if err != nil {
+		Log.Error(err, "error while checking SPIRE")
+		return ctrl.Result{}, err
+	}
+	meta.SetStatusCondition(&nsm.Status.Conditions, getSpireCondition(ready, message))
+	if !ready {
+		Log.Info("SPIRE is not ready, waiting to deploy NSM components", "reason", message)
+		nsm.Status.Phase = nsmv1alpha1.NSMPhasePending
+		nsm.Status.Platform = platform
+		if !equality.Semantic.DeepEqual(status, &nsm.Status) {
+			if updateErr := r.Client.Status().Update(context.TODO(), nsm); updateErr != nil {
+				Log.Info("Failed to update status", "Error", updateErr.Error())
+			}
+		}
+		return ctrl.Result{RequeueAfter: dependencyRequeueDelay}, nil
+	}
+
 	reconcilers := []Reconciler{
 		NewRegistryReconciler(r.Client, Log, r.Scheme),
 		NewRegistryServiceReconciler(r.Client, Log, r.Scheme),
 		NewNsmgrReconciler(r.Client, Log, r.Scheme),
+		NewSpiffeIDReconciler(r.Client, Log, r.Scheme),
 	}
 
+	// Register admission-webhook-k8s with the API server, or remove
+	// its configuration when the webhook is disabled
+	reconcilers = append(reconcilers,
+		NewMutatingWebhookReconciler(r.Client, Log, r.Scheme, r.APIReader, r.HubDriven))
+
 	// Add admission-webhook-k8s reconciler on demand
 	if nsm.Spec.Webhook.Image != "" {
 		reconcilers = append(reconcilers,
 			NewWebhookReconciler(r.Client, Log, r.Scheme),
-			NewWebhookServiceReconciler(r.Client, Log, r.Scheme))
+			NewWebhookServiceReconciler(r.Client, Log, r.Scheme),
+			NewInjectionPolicyReconciler(r.Client, Log, r.Scheme, r.APIReader))
+	}
+
+	// Keep admission-webhook-k8s running through node drains, or remove
+	// the budget when the webhook is disabled or runs a single replica
+	reconcilers = append(reconcilers,
+		NewWebhookPDBReconciler(r.Client, Log, r.Scheme))
+
+	// Host the registry shared with other domains on demand, ahead of
+	// the registry-proxy-dns resolving it. It's left out without
+	// interdomain, the registrations couldn't be forwarded.
+	if nsm.Spec.FloatingRegistry.Enabled {
+		floatingErr := validateFloatingRegistry(nsm)
+		meta.SetStatusCondition(&nsm.Status.Conditions, getFloatingRegistryCondition(floatingErr))
+		if floatingErr != nil {
+			Log.Error(floatingErr, "invalid floating registry settings, waiting for the NSM CR to be fixed")
+		}
+	} else {
+		meta.RemoveStatusCondition(&nsm.Status.Conditions, nsmv1alpha1.NSMConditionFloatingRegistryValid)
+	}
+
+	// Deploy the floating registry, nsmgr-proxy and registry-proxy-dns
+	// on demand, or remove them when they are disabled. The registry
+	// forwards the requests for the other domains to nsmgr-proxy.
+	reconcilers = append(reconcilers,
+		NewFloatingRegistryReconciler(r.Client, Log, r.Scheme),
+		NewInterdomainReconciler(r.Client, Log, r.Scheme))
+
+	// Register the network services of the CR with registry-k8s
+	if nsm.Spec.Registry.Type == "k8s" && servicesErr == nil {
+		reconcilers = append(reconcilers,
+			NewNetworkServiceReconciler(r.Client, Log, r.Scheme))
+	}
+
+	// Delete the expired registrations ahead of reporting the registry
+	// contents, the report leaves out the ones the cache still holds
+	deletedRegistrations := map[types.UID]bool{}
+	if nsm.Spec.Registry.Type == "k8s" && nsm.Spec.Registry.GarbageCollection.Enabled {
+		reconcilers = append(reconcilers,
+			NewRegistryGCReconciler(r.Client, Log, r.Scheme, r.Recorder, deletedRegistrations))
+	}
+
+	// Report the registrations stored by registry-k8s
+	if nsm.Spec.Registry.Type == "k8s" {
+		if err := r.watchRegistry(); err != nil {
+			Log.Error(err, "error while watching the registry")
+			return ctrl.Result{}, err
+		}
+		reconcilers = append(reconcilers,
+			NewRegistryStatusReconciler(r.Client, Log, r.Scheme, deletedRegistrations))
+	} else {
+		nsm.Status.Registry = nil
+		meta.RemoveStatusCondition(&nsm.Status.Conditions, nsmv1alpha1.NSMConditionForwardersRegistered)
 	}
 
+	// Grant SecurityContextConstraints to NSM components on demand,
+	// or remove the grant when the platform has none
+	reconcilers = append(reconcilers,
+		NewSCCReconciler(r.Client, Log, r.Scheme, r.APIReader, r.clusterCache,
+			platformProfiles[platform].securityContextConstraints, &r.sccReviews))
+
 	// Add forwarder reconcilers
 	for _, pf := range nsm.Spec.Forwarders {
 		reconcilers = append(reconcilers,
@@ -122,8 +393,14 @@ func (r *NSMReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	}
 
 	// Call all reconcilers
+	waiting := false
 	for _, r := range reconcilers {
 		err := r.Reconcile(ctx, nsm)
+		if isDependencyNotReady(err) {
+			Log.Info("waiting for a dependency, reconciling the other components", "reason", err.Error())
+			waiting = true
+			continue
+		}
 		if err != nil {
 			Log.Error(err, "error while reconciling")
 			return ctrl.Result{}, err
@@ -131,24 +408,72 @@ func (r *NSMReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 	}
 
 	// Update Status field after creating all resources
-	if nsm.Status.Phase != nsmv1alpha1.NSMPhaseRunning {
-		nsm.Status.Phase = nsmv1alpha1.NSMPhaseRunning
+	nsm.Status.Phase = nsmv1alpha1.NSMPhaseRunning
+	nsm.Status.Platform = platform
+	if !equality.Semantic.DeepEqual(status, &nsm.Status) {
 		if updateErr := r.Client.Status().Update(context.TODO(), nsm); updateErr != nil {
 			Log.Info("Failed to update status", "Error", updateErr.Error())
 		}
 	}
 
+	if waiting {
+		return ctrl.Result{RequeueAfter: dependencyRequeueDelay}, nil
+	}
+
+	// Check the endpoint registrations and the webhook certificates
+	// regularly, the certificates are rotated before they expire and
+	// cert-manager renews them on its own, registrations expire
+	// without any event
+	switch {
+	case nsm.Spec.Registry.Type == "k8s":
+		return ctrl.Result{RequeueAfter: networkServiceCheckInterval}, nil
+	case nsm.Spec.Webhook.Image != "":
+		return ctrl.Result{RequeueAfter: webhookCertCheckInterval}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
 // SetupWithManager registers the controlller with the manager and adds the owned resource types
 func (r *NSMReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&nsmv1alpha1.NSM{}).
 		Owns(&corev1.Service{}).
 		Owns(&appsv1.Deployment{}).
 		Owns(&appsv1.DaemonSet{}).
-		Complete(r)
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Build(r)
+	if err != nil {
+		return err
+	}
+
+	// The injection policies and the client workloads live in the client
+	// namespaces, out of the cache of the manager, they are read through a
+	// cluster wide one. Its informers are only started on first use.
+	clusterCache, err := cache.New(mgr.GetConfig(), cache.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		return err
+	}
+	for _, obj := range []client.Object{&appsv1.Deployment{}, &appsv1.StatefulSet{}, &appsv1.DaemonSet{}} {
+		if err := clusterCache.IndexField(context.TODO(), obj, templateAnnotationsIndex, indexTemplateAnnotations); err != nil {
+			return err
+		}
+	}
+	if err := mgr.Add(clusterCache); err != nil {
+		return err
+	}
+	r.clusterCache = clusterCache
+	err = c.Watch(source.NewKindWithCache(&nsmv1alpha1.NSMInjectionPolicy{}, clusterCache),
+		handler.EnqueueRequestsFromMapFunc(r.nsmsForInjectionPolicy), predicate.GenerationChangedPredicate{})
+	if err != nil {
+		return err
+	}
+
+	// The registrations are watched on demand, see watchRegistry
+	r.controller = c
+	return nil
 }
 
 // Get value for NSM_LOG_LEVEL environment variable (defaul: "INFO")
@@ -162,11 +487,66 @@ func getNsmLogLevel(nsm *nsmv1alpha1.NSM) string {
 func getSpireAgentSocket(nsm *nsmv1alpha1.NSM) string {
 	SpireAgentSocket := nsm.Spec.SpireAgentSocket
 	if SpireAgentSocket == "" {
-		SpireAgentSocket = "unix:///run/spire/sockets/agent.sock"
+		SpireAgentSocket = spireAgentSocket
 	}
 	return SpireAgentSocket
 }
 
+// Get the directory of the SPIRE agent socket inside the NSM containers,
+// parsed from the socket address (default: "/run/spire/sockets")
+func getSpireAgentSocketDir(nsm *nsmv1alpha1.NSM) string {
+	socketPath := strings.TrimPrefix(getSpireAgentSocket(nsm), "unix://")
+	if !path.IsAbs(socketPath) {
+		return spireAgentSocketDir
+	}
+	return path.Dir(socketPath)
+}
+
+// Get the host directories to be mounted into NSM components, using
+// the defaults for the ones not defined in the CR
+func getHostPaths(nsm *nsmv1alpha1.NSM) nsmv1alpha1.HostPaths {
+	hostPaths := nsm.Spec.HostPaths
+	if hostPaths.Kubelet == "" {
+		hostPaths.Kubelet = kubeletHostPath
+	}
+	if hostPaths.NsmSocket == "" {
+		hostPaths.NsmSocket = nsmSocketHostPath
+	}
+	if hostPaths.SpireAgentSocket == "" {
+		hostPaths.SpireAgentSocket = getSpireAgentSocketDir(nsm)
+	}
+	if hostPaths.Cgroup == "" {
+		hostPaths.Cgroup = cgroupHostPath
+	}
+	if hostPaths.Vfio == "" {
+		hostPaths.Vfio = vfioHostPath
+	}
+	return hostPaths
+}
+
+// Get the volume serving the SPIRE agent socket to NSM components, the
+// spiffe-csi-driver one when it's enabled in the CR or the host directory
+func getSpireAgentSocketVolume(nsm *nsmv1alpha1.NSM) corev1.Volume {
+	if nsm.Spec.Spiffe.CSIDriver {
+		readOnly := true
+		return corev1.Volume{
+			Name: "spire-agent-socket",
+			VolumeSource: corev1.VolumeSource{
+				CSI: &corev1.CSIVolumeSource{
+					Driver:   spiffeCSIDriver,
+					ReadOnly: &readOnly,
+				}}}
+	}
+	volTypeDirectory := corev1.HostPathDirectory
+	return corev1.Volume{
+		Name: "spire-agent-socket",
+		VolumeSource: corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{
+				Path: getHostPaths(nsm).SpireAgentSocket,
+				Type: &volTypeDirectory,
+			}}}
+}
+
 // If SpireAgentSocket is defined in the CR then its value will be used in
 // SPIFFE_ENDPOINT_SOCKET environment variable
 func insertSpireAgentSocketEnv(envVars []corev1.EnvVar, SpireAgentSocket string) []corev1.EnvVar {
@@ -179,6 +559,23 @@ func insertSpireAgentSocketEnv(envVars []corev1.EnvVar, SpireAgentSocket string)
 	return append(SpireAgentSocketEnv, envVars...)
 }
 
+// Replace the environment variables overridden in the CR and append the other ones
+func mergeEnvVars(envVars []corev1.EnvVar, overrides []corev1.EnvVar) []corev1.EnvVar {
+	for _, override := range overrides {
+		overridden := false
+		for i := range envVars {
+			if envVars[i].Name == override.Name {
+				envVars[i] = override
+				overridden = true
+			}
+		}
+		if !overridden {
+			envVars = append(envVars, override)
+		}
+	}
+	return envVars
+}
+
 func removeItem(EnvVars []corev1.EnvVar, index int) []corev1.EnvVar {
 	return append(EnvVars[:index], EnvVars[index+1:]...)
 }
@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"path"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+// The networkservicemesh.io CRDs registry-k8s needs, in a directory named
+// after the first NSM version they apply to
+//
+//go:embed crds
+var crdManifests embed.FS
+
+// Annotation recording the NSM version of the manifests a CRD was installed from
+const crdVersionAnnotation string = "nsm.networkservicemesh.io/crd-version"
+
+// Names of the CRDs in the manifests, the only ones the operator may delete
+var crdNames = []string{"networkservices.networkservicemesh.io", "networkserviceendpoints.networkservicemesh.io"}
+
+// CRDReconciler installs the networkservicemesh.io CRDs matching the NSM
+// version and upgrades them when a newer version is deployed
+type CRDReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func NewCRDReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme) *CRDReconciler {
+	return &CRDReconciler{
+		Client: client,
+		Log:    log,
+		Scheme: scheme,
+	}
+}
+
+func (r *CRDReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	crdVersion, crds, err := getCRDManifests(nsm.Spec.Version)
+	if err != nil {
+		return err
+	}
+
+	for _, desired := range crds {
+		current := &apiextensionsv1.CustomResourceDefinition{}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: desired.Name}, current)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			// CRDs are cluster scoped, the finalizer finds them by label
+			desired.Labels = getOwnerLabels(nsm)
+			desired.Annotations = map[string]string{crdVersionAnnotation: crdVersion}
+			err = r.Client.Create(ctx, desired)
+			if err != nil {
+				r.Log.Error(err, "failed to create CRD "+desired.Name)
+				return err
+			}
+			r.Log.Info("CRD " + desired.Name + " created")
+			continue
+		}
+
+		// Never downgrade the CRDs, another NSM instance may run a newer version.
+		// CRDs installed from static manifests carry no version and are upgraded
+		// but keep their labels, so the operator never deletes them
+		installed := current.Annotations[crdVersionAnnotation]
+		if installed != "" && compareVersions(installed, crdVersion) >= 0 {
+			continue
+		}
+		current.Spec = desired.Spec
+		if current.Annotations == nil {
+			current.Annotations = map[string]string{}
+		}
+		current.Annotations[crdVersionAnnotation] = crdVersion
+		err = r.Client.Update(ctx, current)
+		if err != nil {
+			r.Log.Error(err, "failed to update CRD "+desired.Name)
+			return err
+		}
+		r.Log.Info("CRD "+desired.Name+" upgraded", "version", crdVersion)
+	}
+	return nil
+}
+
+// Get the CRDs of the newest manifests that apply to the NSM version,
+// an unparsable version like a development tag gets the newest ones
+func getCRDManifests(nsmVersion string) (string, []*apiextensionsv1.CustomResourceDefinition, error) {
+
+	dirs, err := crdManifests.ReadDir("crds")
+	if err != nil {
+		return "", nil, err
+	}
+	selected := ""
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		if compareVersions(dir.Name(), nsmVersion) > 0 {
+			continue
+		}
+		if selected == "" || compareVersions(dir.Name(), selected) > 0 {
+			selected = dir.Name()
+		}
+	}
+	if selected == "" {
+		return "", nil, fmt.Errorf("no networkservicemesh.io CRDs for NSM version %s", nsmVersion)
+	}
+
+	files, err := crdManifests.ReadDir(path.Join("crds", selected))
+	if err != nil {
+		return "", nil, err
+	}
+	crds := []*apiextensionsv1.CustomResourceDefinition{}
+	for _, file := range files {
+		data, err := crdManifests.ReadFile(path.Join("crds", selected, file.Name()))
+		if err != nil {
+			return "", nil, err
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := yaml.Unmarshal(data, crd); err != nil {
+			return "", nil, fmt.Errorf("invalid CRD manifest %s/%s: %w", selected, file.Name(), err)
+		}
+		crds = append(crds, crd)
+	}
+	return selected, crds, nil
+}
+
+// Compare two NSM versions, a version that can't be parsed
+// is newer than any release
+func compareVersions(a, b string) int {
+	va, errA := version.ParseGeneric(a)
+	vb, errB := version.ParseGeneric(b)
+	switch {
+	case errA != nil && errB != nil:
+		return 0
+	case errA != nil:
+		return 1
+	case errB != nil:
+		return -1
+	}
+	switch {
+	case va.LessThan(vb):
+		return -1
+	case vb.LessThan(va):
+		return 1
+	}
+	return 0
+}
+
+// Delete the networkservicemesh.io CRDs installed for an NSM CR unless
+// network services or endpoints are left, deleting a CRD deletes all its
+// objects too. The objects registered from the CR are garbage collected
+// together with it and don't keep the CRDs
+func (r *NSMReconciler) deleteCRDs(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	Log := log.FromContext(ctx)
+
+	owner := getOwnerLabels(nsm)[ownerLabel]
+	for _, gvk := range []schema.GroupVersionKind{networkServiceGVK, networkServiceEndpointGVK} {
+		objs := &unstructured.UnstructuredList{}
+		objs.SetGroupVersionKind(gvk)
+		if err := r.APIReader.List(ctx, objs); err != nil {
+			if meta.IsNoMatchError(err) || apierrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		for _, obj := range objs.Items {
+			if obj.GetLabels()[ownerLabel] != owner {
+				Log.Info("networkservicemesh.io objects left, keeping the CRDs",
+					"kind", obj.GetKind(), "object", obj.GetNamespace()+"/"+obj.GetName())
+				return nil
+			}
+		}
+	}
+
+	for _, name := range crdNames {
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		err := r.Client.Get(ctx, types.NamespacedName{Name: name}, crd)
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if crd.Labels[ownerLabel] != owner {
+			continue
+		}
+		if err := r.Client.Delete(ctx, crd); err != nil && !apierrors.IsNotFound(err) {
+			Log.Error(err, "failed to delete CRD "+name)
+			return err
+		}
+		Log.Info("CRD " + name + " deleted")
+	}
+	return nil
+}
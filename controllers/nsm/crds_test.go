@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.7.0", "v1.7.0", 0},
+		{"v1.6.1", "v1.7.0", -1},
+		{"v1.10.0", "v1.7.0", 1},
+		{"v1.7.0", "1.7.0", 0},
+		{"v1.7", "v1.7.0", 0},
+		{"v1.7.0-rc.1", "v1.6.0", 1},
+		{"main", "v1.7.0", 1},
+		{"v1.7.0", "main", -1},
+		{"main", "latest", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			if got := compareVersions(tt.a, tt.b); got != tt.want {
+				t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCRDManifests(t *testing.T) {
+	tests := []struct {
+		name        string
+		nsmVersion  string
+		wantVersion string
+		wantErr     bool
+	}{
+		{name: "first release of the manifests", nsmVersion: "v1.0.0", wantVersion: "v1.0.0"},
+		{name: "later release", nsmVersion: "v1.7.1", wantVersion: "v1.0.0"},
+		{name: "development version", nsmVersion: "main", wantVersion: "v1.0.0"},
+		{name: "release older than the manifests", nsmVersion: "v0.9.0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, crds, err := getCRDManifests(tt.nsmVersion)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCRDManifests() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if version != tt.wantVersion {
+				t.Errorf("getCRDManifests() version = %s, want %s", version, tt.wantVersion)
+			}
+			names := []string{}
+			for _, crd := range crds {
+				names = append(names, crd.Name)
+			}
+			sort.Strings(names)
+			want := []string{"networkserviceendpoints.networkservicemesh.io", "networkservices.networkservicemesh.io"}
+			if len(names) != len(want) || names[0] != want[0] || names[1] != want[1] {
+				t.Errorf("getCRDManifests() CRDs = %v, want %v", names, want)
+			}
+		})
+	}
+}
+
+func TestDeleteCRDs(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = apiextensionsv1.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+	crd := func(name string, labels map[string]string) *apiextensionsv1.CustomResourceDefinition {
+		return &apiextensionsv1.CustomResourceDefinition{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		crd("networkservices.networkservicemesh.io", getOwnerLabels(nsm)),
+		// Installed from static manifests
+		crd("networkserviceendpoints.networkservicemesh.io", nil),
+		// Not one of the manifests, even with the owner label
+		crd("other.networkservicemesh.io", getOwnerLabels(nsm)),
+	).Build()
+	r := &NSMReconciler{Client: c, Scheme: scheme, APIReader: c}
+
+	if err := r.deleteCRDs(context.TODO(), nsm); err != nil {
+		t.Fatalf("deleteCRDs() error = %v", err)
+	}
+	for name, kept := range map[string]bool{
+		"networkservices.networkservicemesh.io":         false,
+		"networkserviceendpoints.networkservicemesh.io": true,
+		"other.networkservicemesh.io":                   true,
+	} {
+		err := c.Get(context.TODO(), types.NamespacedName{Name: name}, &apiextensionsv1.CustomResourceDefinition{})
+		if kept != (err == nil) {
+			t.Errorf("CRD %s kept = %v, want %v", name, err == nil, kept)
+		}
+	}
+
+	// Every CRD of the manifests can be deleted by the operator
+	_, crds, err := getCRDManifests("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, crd := range crds {
+		if !containsString(crdNames, crd.Name) {
+			t.Errorf("CRD %s missing from crdNames", crd.Name)
+		}
+	}
+}
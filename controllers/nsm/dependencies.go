@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// Name of the SPIFFE CSI driver serving the SPIRE agent socket to the pods
+	spiffeCSIDriver string = "csi.spiffe.io"
+	// Delay before checking the dependencies of NSM again
+	dependencyRequeueDelay time.Duration = 10 * time.Second
+	// Nodes named in the messages of the SpireReady condition
+	maxReportedNodes int = 5
+)
+
+// Taints tolerated by the pods of every daemonset
+var daemonSetTolerations = []string{
+	corev1.TaintNodeNotReady,
+	corev1.TaintNodeUnreachable,
+	corev1.TaintNodeDiskPressure,
+	corev1.TaintNodeMemoryPressure,
+	corev1.TaintNodePIDPressure,
+	corev1.TaintNodeUnschedulable,
+}
+
+// dependencyNotReadyError reports a dependency a reconciler waits for,
+// the other reconcilers carry on and the NSM instance is reconciled
+// again after dependencyRequeueDelay
+type dependencyNotReadyError struct {
+	message string
+}
+
+func (e *dependencyNotReadyError) Error() string {
+	return e.message
+}
+
+func isDependencyNotReady(err error) bool {
+	_, ok := err.(*dependencyNotReadyError)
+	return ok
+}
+
+// Check that SPIRE serves the workload API on the nodes, through the SPIRE
+// agent ready on each node the NSM components run on or through the SPIFFE
+// CSI driver when the NSM components mount it. The message tells what is
+// missing when SPIRE is not ready.
+func (r *NSMReconciler) spireReady(ctx context.Context, nsm *nsmv1alpha1.NSM) (bool, string, error) {
+
+	if nsm.Spec.Spiffe.CSIDriver {
+		return r.spiffeCSIDriverReady(ctx)
+	}
+
+	agent, message, err := r.getSpireAgentDaemonSet(ctx, nsm)
+	if agent == nil || err != nil {
+		return false, message, err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(agent.Spec.Selector)
+	if err != nil {
+		return false, "", err
+	}
+	pods := &corev1.PodList{}
+	if err := r.APIReader.List(ctx, pods, client.InNamespace(agent.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return false, "", err
+	}
+	nodes := &corev1.NodeList{}
+	if err := r.APIReader.List(ctx, nodes); err != nil {
+		return false, "", err
+	}
+	ready, message := spireAgentReady(agent, pods.Items, nodes.Items)
+	return ready, message, nil
+}
+
+// Get the SPIRE agent daemonset, the one deployed by the operator, the one
+// named in the NSM CR or else the first one running a spire-agent image.
+// The message tells what is missing when there isn't any.
+func (r *NSMReconciler) getSpireAgentDaemonSet(ctx context.Context, nsm *nsmv1alpha1.NSM) (*appsv1.DaemonSet, string, error) {
+
+	name := types.NamespacedName{}
+	switch {
+	case nsm.Spec.Spire.Enabled:
+		name = types.NamespacedName{Name: spireAgentName, Namespace: nsm.ObjectMeta.Namespace}
+	case nsm.Spec.SpireAgentDaemonSet != "":
+		parts := strings.SplitN(nsm.Spec.SpireAgentDaemonSet, "/", 2)
+		name = types.NamespacedName{Name: parts[1], Namespace: parts[0]}
+	default:
+		// spire-agent can run in any namespace, deployed by
+		// the SPIRE helm chart and manifests
+		daemonSets := &appsv1.DaemonSetList{}
+		if err := r.APIReader.List(ctx, daemonSets); err != nil {
+			return nil, "", err
+		}
+		for i := range daemonSets.Items {
+			if isSpireAgent(daemonSets.Items[i]) {
+				return &daemonSets.Items[i], "", nil
+			}
+		}
+		return nil, "no SPIRE agent daemonset found", nil
+	}
+
+	agent := &appsv1.DaemonSet{}
+	err := r.APIReader.Get(ctx, name, agent)
+	if apierrors.IsNotFound(err) {
+		return nil, "SPIRE agent daemonset " + name.String() + " not found", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return agent, "", nil
+}
+
+// Check that a pod of the SPIRE agent daemonset is ready on each ready
+// node the NSM components can be scheduled on
+func spireAgentReady(agent *appsv1.DaemonSet, pods []corev1.Pod, nodes []corev1.Node) (bool, string) {
+
+	agentNodes := map[string]bool{}
+	for i := range pods {
+		if metav1.IsControlledBy(&pods[i], agent) && isPodReady(&pods[i]) {
+			agentNodes[pods[i].Spec.NodeName] = true
+		}
+	}
+	missing := []string{}
+	nsmNodes := 0
+	for i := range nodes {
+		if !isNSMNode(&nodes[i]) {
+			continue
+		}
+		nsmNodes++
+		if !agentNodes[nodes[i].Name] {
+			missing = append(missing, nodes[i].Name)
+		}
+	}
+	if nsmNodes == 0 {
+		return false, "no ready node to run the NSM components on"
+	}
+	if len(missing) == 0 {
+		return true, ""
+	}
+	message := fmt.Sprintf("SPIRE agent daemonset %s/%s is ready on %d of %d nodes, missing on ",
+		agent.Namespace, agent.Name, nsmNodes-len(missing), nsmNodes)
+	if len(missing) > maxReportedNodes {
+		return false, message + strings.Join(missing[:maxReportedNodes], ", ") + "..."
+	}
+	return false, message + strings.Join(missing, ", ")
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// Check whether the NSM daemonsets run on a node: it's ready and only has the
+// taints the daemonset pods tolerate by default, like the cordoned nodes
+func isNSMNode(node *corev1.Node) bool {
+	ready := false
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+	if !ready {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect != corev1.TaintEffectPreferNoSchedule && !containsString(daemonSetTolerations, taint.Key) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check that the SPIFFE CSI driver is registered on every node its
+// daemonset is scheduled on, the nodes it's kept away from by taints
+// or node selectors don't run NSM components either
+func (r *NSMReconciler) spiffeCSIDriverReady(ctx context.Context) (bool, string, error) {
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := r.APIReader.List(ctx, daemonSets); err != nil {
+		return false, "", err
+	}
+	var driver *appsv1.DaemonSet
+	for i := range daemonSets.Items {
+		if isSpiffeCSIDriver(daemonSets.Items[i]) {
+			driver = &daemonSets.Items[i]
+			break
+		}
+	}
+	if driver == nil {
+		return false, "no SPIFFE CSI driver daemonset found", nil
+	}
+
+	csiNodes := &storagev1.CSINodeList{}
+	if err := r.APIReader.List(ctx, csiNodes); err != nil {
+		return false, "", err
+	}
+	ready, message := spiffeCSIDriverRegistered(driver, csiNodes.Items)
+	return ready, message, nil
+}
+
+func spiffeCSIDriverRegistered(driver *appsv1.DaemonSet, csiNodes []storagev1.CSINode) (bool, string) {
+
+	registered := int32(0)
+	for _, csiNode := range csiNodes {
+		for _, d := range csiNode.Spec.Drivers {
+			if d.Name == spiffeCSIDriver {
+				registered++
+				break
+			}
+		}
+	}
+	desired := driver.Status.DesiredNumberScheduled
+	if desired > 0 && registered >= desired {
+		return true, ""
+	}
+	return false, fmt.Sprintf("SPIFFE CSI driver %s/%s is registered on %d of %d nodes",
+		driver.Namespace, driver.Name, registered, desired)
+}
+
+func isSpireAgent(ds appsv1.DaemonSet) bool {
+	for _, container := range ds.Spec.Template.Spec.Containers {
+		if strings.Contains(container.Image, "spire-agent") {
+			return true
+		}
+	}
+	return false
+}
+
+func isSpiffeCSIDriver(ds appsv1.DaemonSet) bool {
+	for _, container := range ds.Spec.Template.Spec.Containers {
+		if strings.Contains(container.Image, "spiffe-csi-driver") {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the SpireReady condition of the NSM status
+func getSpireCondition(ready bool, message string) metav1.Condition {
+	if ready {
+		return metav1.Condition{
+			Type:    nsmv1alpha1.NSMConditionSpireReady,
+			Status:  metav1.ConditionTrue,
+			Reason:  nsmv1alpha1.NSMReasonSpireReady,
+			Message: "SPIRE serves the workload API on the nodes",
+		}
+	}
+	return metav1.Condition{
+		Type:    nsmv1alpha1.NSMConditionSpireReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  nsmv1alpha1.NSMReasonSpireNotReady,
+		Message: message,
+	}
+}
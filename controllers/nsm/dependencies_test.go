@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSpiffeCSIDriverRegistered(t *testing.T) {
+	csiNode := func(drivers ...string) storagev1.CSINode {
+		node := storagev1.CSINode{}
+		for _, d := range drivers {
+			node.Spec.Drivers = append(node.Spec.Drivers, storagev1.CSINodeDriver{Name: d})
+		}
+		return node
+	}
+	tests := []struct {
+		name     string
+		desired  int32
+		csiNodes []storagev1.CSINode
+		want     bool
+	}{
+		{
+			name:     "registered on every scheduled node",
+			desired:  2,
+			csiNodes: []storagev1.CSINode{csiNode(spiffeCSIDriver), csiNode("ebs.csi.aws.com", spiffeCSIDriver)},
+			want:     true,
+		},
+		{
+			// Control plane nodes the driver isn't scheduled on
+			name:     "nodes outside of the daemonset",
+			desired:  2,
+			csiNodes: []storagev1.CSINode{csiNode(spiffeCSIDriver), csiNode(spiffeCSIDriver), csiNode()},
+			want:     true,
+		},
+		{
+			name:     "missing on a scheduled node",
+			desired:  3,
+			csiNodes: []storagev1.CSINode{csiNode(spiffeCSIDriver), csiNode(spiffeCSIDriver), csiNode()},
+			want:     false,
+		},
+		{
+			name:     "not scheduled yet",
+			desired:  0,
+			csiNodes: []storagev1.CSINode{csiNode()},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			driver := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "spiffe-csi-driver", Namespace: "spire"}}
+			driver.Status.DesiredNumberScheduled = tt.desired
+
+			if got, message := spiffeCSIDriverRegistered(driver, tt.csiNodes); got != tt.want {
+				t.Errorf("spiffeCSIDriverRegistered() = %v (%s), want %v", got, message, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpireAgentReady(t *testing.T) {
+	agent := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "spire-agent", Namespace: "spire", UID: "agent-uid"}}
+	other := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "spire", UID: "other-uid"}}
+	pod := func(owner *appsv1.DaemonSet, node string, ready bool) corev1.Pod {
+		pod := corev1.Pod{Spec: corev1.PodSpec{NodeName: node}}
+		pod.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(owner, appsv1.SchemeGroupVersion.WithKind("DaemonSet"))}
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}
+		return pod
+	}
+	node := func(name string, ready bool, taints ...corev1.Taint) corev1.Node {
+		node := corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: corev1.NodeSpec{Taints: taints}}
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}
+		return node
+	}
+	controlPlane := corev1.Taint{Key: "node-role.kubernetes.io/control-plane", Effect: corev1.TaintEffectNoSchedule}
+	cordoned := corev1.Taint{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule}
+
+	tests := []struct {
+		name  string
+		pods  []corev1.Pod
+		nodes []corev1.Node
+		want  bool
+	}{
+		{
+			name:  "ready on every node",
+			pods:  []corev1.Pod{pod(agent, "a", true), pod(agent, "b", true)},
+			nodes: []corev1.Node{node("a", true), node("b", true)},
+			want:  true,
+		},
+		{
+			name:  "not ready on a node",
+			pods:  []corev1.Pod{pod(agent, "a", true), pod(agent, "b", false)},
+			nodes: []corev1.Node{node("a", true), node("b", true)},
+		},
+		{
+			name:  "missing on a node",
+			pods:  []corev1.Pod{pod(agent, "a", true)},
+			nodes: []corev1.Node{node("a", true), node("b", true)},
+		},
+		{
+			// Pods matching the selector of the agent daemonset
+			name:  "pod of another daemonset",
+			pods:  []corev1.Pod{pod(agent, "a", true), pod(other, "b", true)},
+			nodes: []corev1.Node{node("a", true), node("b", true)},
+		},
+		{
+			name:  "nodes without NSM components",
+			pods:  []corev1.Pod{pod(agent, "a", true)},
+			nodes: []corev1.Node{node("a", true), node("control-plane", true, controlPlane), node("down", false)},
+			want:  true,
+		},
+		{
+			name:  "cordoned node",
+			pods:  []corev1.Pod{pod(agent, "a", true)},
+			nodes: []corev1.Node{node("a", true), node("cordoned", true, cordoned)},
+		},
+		{
+			name:  "no node",
+			nodes: []corev1.Node{node("down", false)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, message := spireAgentReady(agent, tt.pods, tt.nodes); got != tt.want {
+				t.Errorf("spireAgentReady() = %v (%s), want %v", got, message, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSpireAgentDaemonSet(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	daemonSet := func(name, namespace, image string) *appsv1.DaemonSet {
+		ds := &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+		ds.Spec.Template.Spec.Containers = []corev1.Container{{Name: name, Image: image}}
+		return ds
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		daemonSet(spireAgentName, "nsm", "gcr.io/spiffe-io/spire-agent:1.2.3"),
+		daemonSet("agent", "spire", "ghcr.io/spiffe/spire-agent:1.5.1"),
+	).Build()
+	r := &NSMReconciler{Client: c, Scheme: scheme, APIReader: c}
+
+	tests := []struct {
+		name      string
+		spire     bool
+		daemonSet string
+		namespace string
+		want      string
+	}{
+		{name: "deployed by the operator", spire: true, namespace: "nsm", want: "nsm/" + spireAgentName},
+		{name: "deployed by the operator, missing", spire: true, namespace: "other"},
+		{name: "named", daemonSet: "spire/agent", namespace: "nsm", want: "spire/agent"},
+		{name: "named, missing", daemonSet: "spire/missing", namespace: "nsm"},
+		{name: "looked up", namespace: "nsm", want: "nsm/" + spireAgentName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: tt.namespace}}
+			nsm.Spec.Spire.Enabled = tt.spire
+			nsm.Spec.SpireAgentDaemonSet = tt.daemonSet
+			agent, message, err := r.getSpireAgentDaemonSet(context.TODO(), nsm)
+			if err != nil {
+				t.Fatalf("getSpireAgentDaemonSet() error = %v", err)
+			}
+			if tt.want == "" {
+				if agent != nil || message == "" {
+					t.Errorf("getSpireAgentDaemonSet() = %v, %q, want none", agent, message)
+				}
+				return
+			}
+			if agent == nil || agent.Namespace+"/"+agent.Name != tt.want {
+				t.Errorf("getSpireAgentDaemonSet() = %v, %q, want %s", agent, message, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Endpoints reach the sockets of nsmgr and the SPIRE agent in host directories
+var endpointCapabilities = []corev1.Capability{"DAC_OVERRIDE"}
+
+// NSMEndpointReconciler reconciles a NSMEndpoint object
+type NSMEndpointReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=nsmendpoints,verbs=get;list;watch;create;update;patch;delete,namespace=nsm
+// +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=nsmendpoints/status,verbs=get;update;patch,namespace=nsm
+// +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=nsmendpoints/finalizers,verbs=update,namespace=nsm
+
+// Reconcile for NSMEndpoints, the endpoint is deployed with the
+// mesh plumbing of the NSM instance it connects to
+func (r *NSMEndpointReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	Log := log.FromContext(ctx).WithValues("nsmendpoint", req.NamespacedName)
+
+	// Fetch the NSMEndpoint instance
+	endpoint := &nsmv1alpha1.NSMEndpoint{}
+	err := r.Client.Get(ctx, req.NamespacedName, endpoint)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// The deployment is owned by the endpoint and garbage collected with it
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	status := endpoint.Status.DeepCopy()
+
+	nsm, message, err := getNamespaceNSM(ctx, r.Client, endpoint.Namespace, endpoint.Spec.NSM)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if nsm == nil {
+		// The endpoint is reconciled again when the NSM instance shows up
+		meta.SetStatusCondition(&endpoint.Status.Conditions, metav1.Condition{
+			Type:    nsmv1alpha1.NSMEndpointConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  nsmv1alpha1.NSMEndpointReasonNSMNotFound,
+			Message: message,
+		})
+		return ctrl.Result{}, r.updateStatus(ctx, endpoint, status)
+	}
+
+	deploy, err := reconcileComponentDeployment(ctx, r.Client, Log, r.deploymentForEndpoint(endpoint, nsm))
+	if isNotControlled(err) {
+		// The deployment of another workload is left as it is
+		meta.SetStatusCondition(&endpoint.Status.Conditions, metav1.Condition{
+			Type:    nsmv1alpha1.NSMEndpointConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  nsmv1alpha1.NSMEndpointReasonDeploymentConflict,
+			Message: "deployment " + endpoint.Name + " already exists and is not controlled by the endpoint",
+		})
+		return ctrl.Result{}, r.updateStatus(ctx, endpoint, status)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	endpoint.Status.ReadyReplicas = deploy.Status.ReadyReplicas
+	meta.SetStatusCondition(&endpoint.Status.Conditions, getEndpointCondition(deploy))
+	return ctrl.Result{}, r.updateStatus(ctx, endpoint, status)
+}
+
+func (r *NSMEndpointReconciler) updateStatus(ctx context.Context, endpoint *nsmv1alpha1.NSMEndpoint, status *nsmv1alpha1.NSMEndpointStatus) error {
+	if equality.Semantic.DeepEqual(status, &endpoint.Status) {
+		return nil
+	}
+	return r.Client.Status().Update(ctx, endpoint)
+}
+
+// Get the NSM instance of a namespace, the named one or the only one
+// when no name is given. When there isn't any the returned message says
+// why, the operator only watches its own namespace so the workloads
+// can't be deployed with the NSM instance of another one.
+func getNamespaceNSM(ctx context.Context, reader client.Reader, namespace string, name string) (*nsmv1alpha1.NSM, string, error) {
+
+	if name != "" {
+		nsm := &nsmv1alpha1.NSM{}
+		err := reader.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, nsm)
+		if errors.IsNotFound(err) {
+			return nil, "no NSM instance " + name + " in namespace " + namespace + ", it has to be in the same namespace", nil
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return nsm, "", nil
+	}
+	nsms := &nsmv1alpha1.NSMList{}
+	if err := reader.List(ctx, nsms, client.InNamespace(namespace)); err != nil {
+		return nil, "", err
+	}
+	switch len(nsms.Items) {
+	case 0:
+		return nil, "no NSM instance in namespace " + namespace + ", it has to be in the same namespace", nil
+	case 1:
+		return &nsms.Items[0], "", nil
+	}
+	return nil, fmt.Sprintf("%d NSM instances in namespace %s, spec.nsm has to name one", len(nsms.Items), namespace), nil
+}
+
+func (r *NSMEndpointReconciler) deploymentForEndpoint(endpoint *nsmv1alpha1.NSMEndpoint, nsm *nsmv1alpha1.NSM) *appsv1.Deployment {
+
+	objectMeta := newObjectMeta(endpoint.Name, endpoint.Namespace, map[string]string{"app": "nsm"})
+	endpointLabel := map[string]string{"app": endpoint.Name, "spiffe.io/spiffe-id": "true"}
+	volType := corev1.HostPathDirectoryOrCreate
+
+	envVars := []corev1.EnvVar{
+		{Name: "NSM_NAME", ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "metadata.name",
+			}}},
+		{Name: "NSM_CONNECT_TO", Value: "unix:///var/lib/networkservicemesh/nsm.io.sock"},
+		{Name: "NSM_SERVICE_NAMES", Value: strings.Join(endpoint.Spec.NetworkServices, ",")},
+		{Name: "NSM_LOG_LEVEL", Value: getNsmLogLevel(nsm)},
+	}
+	if endpoint.Spec.CIDRPrefix != "" {
+		envVars = append(envVars, corev1.EnvVar{Name: "NSM_CIDR_PREFIX", Value: endpoint.Spec.CIDRPrefix})
+	}
+	envVars = mergeEnvVars(envVars, endpoint.Spec.EnvVars)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: objectMeta,
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: endpointLabel,
+			},
+			Replicas: getEndpointReplicas(endpoint),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: endpointLabel,
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: nseServiceAccountName,
+					Containers: []corev1.Container{{
+						Name:            "nse",
+						Image:           endpoint.Spec.Image,
+						ImagePullPolicy: nsm.Spec.NsmPullPolicy,
+						SecurityContext: getEndpointSecurityContext(endpoint, nsm),
+						Env:             insertSpireAgentSocketEnv(envVars, getSpireAgentSocket(nsm)),
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "spire-agent-socket",
+								MountPath: getSpireAgentSocketDir(nsm),
+								ReadOnly:  true,
+							},
+							{Name: "nsm-socket",
+								MountPath: "/var/lib/networkservicemesh",
+								ReadOnly:  true,
+							},
+						},
+						Resources: getEndpointResources(endpoint),
+					}},
+					Volumes: []corev1.Volume{
+						getSpireAgentSocketVolume(nsm),
+						{
+							Name: "nsm-socket",
+							VolumeSource: corev1.VolumeSource{
+								HostPath: &corev1.HostPathVolumeSource{
+									Path: getHostPaths(nsm).NsmSocket,
+									Type: &volType,
+								}}},
+					},
+				},
+			},
+		},
+	}
+
+	// The pods are rolled when anything rendered from the
+	// endpoint or the NSM instance changes
+	setConfigHash(&deploy.Spec.Template)
+
+	// Set NSMEndpoint instance as the owner and controller
+	controllerutil.SetControllerReference(endpoint, deploy, r.Scheme)
+	return deploy
+}
+
+func getEndpointReplicas(endpoint *nsmv1alpha1.NSMEndpoint) *int32 {
+	replicas := int32(1)
+	if endpoint.Spec.ReplicaCount > 0 {
+		return &endpoint.Spec.ReplicaCount
+	}
+	return &replicas
+}
+
+// Get the resources of the endpoint container
+func getEndpointResources(endpoint *nsmv1alpha1.NSMEndpoint) corev1.ResourceRequirements {
+	if endpoint.Spec.Resources != nil {
+		return *endpoint.Spec.Resources
+	}
+	return corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("200m"),
+			corev1.ResourceMemory: resource.MustParse("80Mi"),
+		},
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("40Mi"),
+		},
+	}
+}
+
+// Get the security context of the endpoint container
+func getEndpointSecurityContext(endpoint *nsmv1alpha1.NSMEndpoint, nsm *nsmv1alpha1.NSM) *corev1.SecurityContext {
+	if endpoint.Spec.SecurityContext != nil {
+		return endpoint.Spec.SecurityContext
+	}
+	if nsm.Spec.Privileged {
+		return getPrivilegedSecurityContext()
+	}
+	return getCapabilitiesSecurityContext(endpointCapabilities)
+}
+
+// Get the Ready condition of the endpoint status
+func getEndpointCondition(deploy *appsv1.Deployment) metav1.Condition {
+	if deploy.Spec.Replicas != nil && deploy.Status.ReadyReplicas >= *deploy.Spec.Replicas {
+		return metav1.Condition{
+			Type:    nsmv1alpha1.NSMEndpointConditionReady,
+			Status:  metav1.ConditionTrue,
+			Reason:  nsmv1alpha1.NSMEndpointReasonDeploymentReady,
+			Message: "all the replicas of the endpoint are ready",
+		}
+	}
+	return metav1.Condition{
+		Type:    nsmv1alpha1.NSMEndpointConditionReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  nsmv1alpha1.NSMEndpointReasonDeploymentNotReady,
+		Message: "waiting for the replicas of the endpoint to be ready",
+	}
+}
+
+// Get the endpoints connecting to an NSM instance, they are rendered again when it changes
+func (r *NSMEndpointReconciler) endpointsForNSM(obj client.Object) []reconcile.Request {
+	endpoints := &nsmv1alpha1.NSMEndpointList{}
+	if err := r.Client.List(context.Background(), endpoints, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, endpoint := range endpoints.Items {
+		if endpoint.Spec.NSM == "" || endpoint.Spec.NSM == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: endpoint.Name, Namespace: endpoint.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager registers the controller with the manager and adds the owned resource types
+func (r *NSMEndpointReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&nsmv1alpha1.NSMEndpoint{}).
+		Owns(&appsv1.Deployment{}).
+		Watches(&source.Kind{Type: &nsmv1alpha1.NSM{}}, handler.EnqueueRequestsFromMapFunc(r.endpointsForNSM)).
+		Complete(r)
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetNamespaceNSM(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := func(name, namespace string) client.Object {
+		return &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		nsm("only", "nsm"),
+		nsm("first", "several"), nsm("second", "several"),
+		nsm("elsewhere", "other"),
+	).Build()
+
+	tests := []struct {
+		name      string
+		namespace string
+		nsm       string
+		want      string
+	}{
+		{name: "only one of the namespace", namespace: "nsm", want: "only"},
+		{name: "named", namespace: "several", nsm: "second", want: "second"},
+		{name: "several without a name", namespace: "several"},
+		{name: "none in the namespace", namespace: "empty"},
+		{name: "named in another namespace", namespace: "nsm", nsm: "elsewhere"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, message, err := getNamespaceNSM(context.TODO(), c, tt.namespace, tt.nsm)
+			if err != nil {
+				t.Fatalf("getNamespaceNSM() error = %v", err)
+			}
+			if tt.want == "" {
+				if got != nil || message == "" {
+					t.Errorf("getNamespaceNSM() = %v, %q, want no NSM instance and a message", got, message)
+				}
+				return
+			}
+			if got == nil || got.Name != tt.want || message != "" {
+				t.Errorf("getNamespaceNSM() = %v, %q, want %s", got, message, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpointDeployment(t *testing.T) {
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+	nsm.Spec.NsmLogLevel = "DEBUG"
+	endpoint := &nsmv1alpha1.NSMEndpoint{
+		ObjectMeta: metav1.ObjectMeta{Name: "nse", Namespace: "nsm"},
+		Spec: nsmv1alpha1.NSMEndpointSpec{
+			Image:           "cmd-nse-icmp-responder:v1.8.0",
+			NetworkServices: []string{"icmp-responder", "other"},
+			CIDRPrefix:      "172.16.1.100/31",
+			EnvVars:         []corev1.EnvVar{{Name: "NSM_LOG_LEVEL", Value: "TRACE"}, {Name: "EXTRA", Value: "value"}},
+		},
+	}
+	r := &NSMEndpointReconciler{Scheme: runtime.NewScheme()}
+	container := r.deploymentForEndpoint(endpoint, nsm).Spec.Template.Spec.Containers[0]
+
+	env := map[string]string{}
+	for _, e := range container.Env {
+		env[e.Name] = e.Value
+	}
+	want := map[string]string{
+		"NSM_SERVICE_NAMES": "icmp-responder,other",
+		"NSM_CIDR_PREFIX":   "172.16.1.100/31",
+		// The env vars of the endpoint take precedence
+		"NSM_LOG_LEVEL": "TRACE",
+		"EXTRA":         "value",
+	}
+	for name, value := range want {
+		if env[name] != value {
+			t.Errorf("env %s = %q, want %q", name, env[name], value)
+		}
+	}
+	if !reflect.DeepEqual(container.SecurityContext, getCapabilitiesSecurityContext(endpointCapabilities)) {
+		t.Errorf("security context = %v, want the endpoint capabilities", container.SecurityContext)
+	}
+	nsm.Spec.Privileged = true
+	if container := r.deploymentForEndpoint(endpoint, nsm).Spec.Template.Spec.Containers[0]; !reflect.DeepEqual(container.SecurityContext, getPrivilegedSecurityContext()) {
+		t.Errorf("security context = %v, want privileged with a privileged NSM instance", container.SecurityContext)
+	}
+}
+
+func TestNSMEndpointReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	endpoint := &nsmv1alpha1.NSMEndpoint{
+		ObjectMeta: metav1.ObjectMeta{Name: "nse", Namespace: "nsm"},
+		Spec:       nsmv1alpha1.NSMEndpointSpec{Image: "cmd-nse-icmp-responder:v1.8.0", NetworkServices: []string{"icmp-responder"}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(endpoint).Build()
+	r := &NSMEndpointReconciler{Client: c, Scheme: scheme}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "nse", Namespace: "nsm"}}
+
+	reconcile := func() *metav1.Condition {
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		endpoint := &nsmv1alpha1.NSMEndpoint{}
+		if err := c.Get(context.TODO(), req.NamespacedName, endpoint); err != nil {
+			t.Fatal(err)
+		}
+		return meta.FindStatusCondition(endpoint.Status.Conditions, nsmv1alpha1.NSMEndpointConditionReady)
+	}
+
+	// Waiting for an NSM instance next to the endpoint
+	if condition := reconcile(); condition == nil || condition.Reason != nsmv1alpha1.NSMEndpointReasonNSMNotFound {
+		t.Fatalf("Ready condition = %v, want %s", condition, nsmv1alpha1.NSMEndpointReasonNSMNotFound)
+	}
+
+	if err := c.Create(context.TODO(), &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}); err != nil {
+		t.Fatal(err)
+	}
+	if condition := reconcile(); condition == nil || condition.Reason != nsmv1alpha1.NSMEndpointReasonDeploymentNotReady {
+		t.Fatalf("Ready condition = %v, want %s", condition, nsmv1alpha1.NSMEndpointReasonDeploymentNotReady)
+	}
+	deploy := &appsv1.Deployment{}
+	if err := c.Get(context.TODO(), req.NamespacedName, deploy); err != nil {
+		t.Fatalf("endpoint deployment missing: %v", err)
+	}
+	if *deploy.Spec.Replicas != 1 {
+		t.Errorf("replicas = %d, want 1", *deploy.Spec.Replicas)
+	}
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"context"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	nsmFinalizer string = "nsm.networkservicemesh.io/finalizer"
+	// Label pointing cluster scoped objects to the NSM CR they belong to
+	ownerLabel string = "nsm.networkservicemesh.io/owner"
+)
+
+// Get the labels for cluster scoped objects created for an NSM CR.
+// Those objects can't have a namespaced owner so the finalizer
+// finds them by label to delete them together with the CR
+func getOwnerLabels(nsm *nsmv1alpha1.NSM) map[string]string {
+	return map[string]string{
+		"app":      "nsm",
+		ownerLabel: nsm.ObjectMeta.Namespace + "." + nsm.ObjectMeta.Name,
+	}
+}
+
+// Delete the cluster scoped objects created for an NSM CR
+func (r *NSMReconciler) finalize(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	clusterSPIFFEID := &unstructured.Unstructured{}
+	clusterSPIFFEID.SetGroupVersionKind(clusterSPIFFEIDGVK)
+
+	selector := client.MatchingLabels{ownerLabel: getOwnerLabels(nsm)[ownerLabel]}
+	for _, obj := range []client.Object{
+		&rbacv1.ClusterRoleBinding{},
+		&rbacv1.ClusterRole{},
+		&admissionregistrationv1.MutatingWebhookConfiguration{},
+		&admissionregistrationv1.ValidatingWebhookConfiguration{},
+		clusterSPIFFEID,
+	} {
+		// Skip the kinds whose CRDs are not installed
+		if err := r.Client.DeleteAllOf(ctx, obj, selector); err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+	}
+	return r.deleteCRDs(ctx, nsm)
+}
@@ -0,0 +1,605 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// The member clusters can't be watched from the hub,
+	// their NSM instances are reconciled at this interval
+	fleetCheckInterval time.Duration = time.Minute
+	// Timeouts of the requests sent to a member and of its whole reconcile,
+	// a member that doesn't answer doesn't hold back the other ones
+	memberRequestTimeout   time.Duration = 15 * time.Second
+	memberReconcileTimeout time.Duration = 2 * time.Minute
+	fleetFinalizer         string        = "nsm.networkservicemesh.io/fleet-finalizer"
+	// Label pointing the NSM instances of the members to their fleet
+	fleetLabel           string = "nsm.networkservicemesh.io/fleet"
+	defaultKubeconfigKey string = "kubeconfig"
+	// Namespace of the NSM instances in the member clusters
+	memberNamespace string = "nsm"
+	nsmCRDName      string = "nsms.nsm.networkservicemesh.io"
+)
+
+// Label of the nsm-operator deployment, a member running its own
+// operator reconciles the NSM instance the hub applies to it
+var operatorLabels = map[string]string{"control-plane": "nsm-operator"}
+
+// NSMFleetReconciler reconciles a NSMFleet object
+type NSMFleetReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Uncached reader for the NSM CRD copied to the member clusters
+	APIReader client.Reader
+
+	// Connections to the member clusters by fleet and member,
+	// reconciles run one at a time so they are not locked
+	members map[string]*memberCluster
+}
+
+// memberCluster holds the connection to a member cluster
+type memberCluster struct {
+	// Hash of the kubeconfig the connection was made with
+	kubeconfigHash string
+	client         client.Client
+	platform       nsmv1alpha1.Platform
+	broadcaster    record.EventBroadcaster
+	recorder       record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=nsmfleets,verbs=get;list;watch;create;update;patch;delete,namespace=nsm
+// +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=nsmfleets/status,verbs=get;update;patch,namespace=nsm
+// +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=nsmfleets/finalizers,verbs=update,namespace=nsm
+
+// Reconcile for NSMFleets, the NSM instance of each member cluster is
+// rendered from the template and reconciled from the hub
+func (r *NSMFleetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	Log := log.FromContext(ctx).WithValues("nsmfleet", req.NamespacedName)
+
+	// Fetch the NSMFleet instance
+	fleet := &nsmv1alpha1.NSMFleet{}
+	err := r.Client.Get(ctx, req.NamespacedName, fleet)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	// Remove the NSM instances of the members before the fleet goes away,
+	// the members whose kubeconfig secret is gone are skipped
+	if !fleet.ObjectMeta.DeletionTimestamp.IsZero() {
+		if controllerutil.ContainsFinalizer(fleet, fleetFinalizer) {
+			status := fleet.Status.DeepCopy()
+			var removeErr error
+			for _, member := range fleet.Spec.Members {
+				orphaned, err := r.removeMemberWithTimeout(ctx, Log, fleet, member)
+				if err != nil {
+					Log.Error(err, "error while removing NSM from member "+member.Name)
+					removeErr = err
+					continue
+				}
+				if orphaned {
+					fleet.Status.OrphanedMembers = appendOrphanedMember(fleet.Status.OrphanedMembers, member.Name)
+				}
+			}
+			if err := r.updateStatus(ctx, fleet, status); err != nil {
+				return ctrl.Result{}, err
+			}
+			if removeErr != nil {
+				return ctrl.Result{}, removeErr
+			}
+			controllerutil.RemoveFinalizer(fleet, fleetFinalizer)
+			if err := r.Client.Update(ctx, fleet); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(fleet, fleetFinalizer) {
+		controllerutil.AddFinalizer(fleet, fleetFinalizer)
+		if err := r.Client.Update(ctx, fleet); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	status := fleet.Status.DeepCopy()
+
+	// A member that can't be reached doesn't hold back the other ones
+	requeueAfter := fleetCheckInterval
+	declared := map[string]bool{}
+	fleet.Status.Members = nil
+	fleet.Status.ReadyMembers = 0
+	for _, member := range fleet.Spec.Members {
+		declared[member.Name] = true
+		memberCtx, cancel := context.WithTimeout(ctx, memberReconcileTimeout)
+		memberStatus, result := r.reconcileMember(memberCtx, Log, fleet, member)
+		cancel()
+		if memberStatus.Phase == nsmv1alpha1.NSMPhaseRunning && memberStatus.Error == "" {
+			fleet.Status.ReadyMembers++
+		}
+		if result.RequeueAfter > 0 && result.RequeueAfter < requeueAfter {
+			requeueAfter = result.RequeueAfter
+		}
+		fleet.Status.Members = append(fleet.Status.Members, memberStatus)
+	}
+
+	// The members added again are managed by the fleet once more
+	orphanedMembers := fleet.Status.OrphanedMembers
+	fleet.Status.OrphanedMembers = nil
+	for _, name := range orphanedMembers {
+		if !declared[name] {
+			fleet.Status.OrphanedMembers = append(fleet.Status.OrphanedMembers, name)
+		}
+	}
+
+	// Remove the NSM instances of the members dropped from the fleet, the
+	// ones that can't be removed yet stay in the status until they are
+	for _, member := range status.Members {
+		if declared[member.Name] {
+			continue
+		}
+		if member.KubeconfigSecret == nil {
+			Log.Info("no kubeconfig secret known for member " + member.Name + ", leaving its NSM instance")
+			continue
+		}
+		removed := nsmv1alpha1.NSMFleetMember{Name: member.Name, KubeconfigSecret: *member.KubeconfigSecret}
+		orphaned, err := r.removeMemberWithTimeout(ctx, Log, fleet, removed)
+		if err != nil {
+			Log.Error(err, "error while removing NSM from member "+member.Name)
+			member.Phase = ""
+			member.Error = "removal failed: " + err.Error()
+			fleet.Status.Members = append(fleet.Status.Members, member)
+		} else if orphaned {
+			fleet.Status.OrphanedMembers = appendOrphanedMember(fleet.Status.OrphanedMembers, member.Name)
+		}
+	}
+
+	meta.SetStatusCondition(&fleet.Status.Conditions, getFleetCondition(fleet))
+	if err := r.updateStatus(ctx, fleet, status); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+func (r *NSMFleetReconciler) updateStatus(ctx context.Context, fleet *nsmv1alpha1.NSMFleet, status *nsmv1alpha1.NSMFleetStatus) error {
+	if equality.Semantic.DeepEqual(status, &fleet.Status) {
+		return nil
+	}
+	return r.Client.Status().Update(ctx, fleet)
+}
+
+// Render the NSM instance of a member and reconcile it with the NSM
+// reconciler of the operator working on the member cluster
+func (r *NSMFleetReconciler) reconcileMember(ctx context.Context, Log logr.Logger, fleet *nsmv1alpha1.NSMFleet, member nsmv1alpha1.NSMFleetMember) (nsmv1alpha1.NSMFleetMemberStatus, ctrl.Result) {
+
+	Log = Log.WithValues("member", member.Name)
+	memberStatus := nsmv1alpha1.NSMFleetMemberStatus{Name: member.Name, KubeconfigSecret: member.KubeconfigSecret.DeepCopy()}
+
+	cluster, err := r.getMemberCluster(ctx, fleet, member)
+	if err != nil {
+		Log.Error(err, "failed to connect to member")
+		memberStatus.Error = err.Error()
+		return memberStatus, ctrl.Result{}
+	}
+	spec, err := getMemberSpec(fleet, member)
+	if err != nil {
+		Log.Error(err, "invalid overrides of member")
+		memberStatus.Error = "invalid overrides: " + err.Error()
+		return memberStatus, ctrl.Result{}
+	}
+	localOperator, err := hasLocalOperator(ctx, cluster.client)
+	if err != nil {
+		Log.Error(err, "failed to look for an operator in member")
+		memberStatus.Error = err.Error()
+		return memberStatus, ctrl.Result{}
+	}
+	memberStatus.LocalOperator = localOperator
+	if err := r.applyMemberNSM(ctx, Log, cluster, fleet, spec, localOperator); err != nil {
+		Log.Error(err, "failed to apply NSM to member")
+		memberStatus.Error = err.Error()
+		return memberStatus, ctrl.Result{}
+	}
+
+	// The operator of the member reconciles the NSM instance
+	// itself, the hub doesn't fight it over the components
+	name := types.NamespacedName{Name: fleet.Name, Namespace: memberNamespace}
+	result := ctrl.Result{}
+	if !localOperator {
+		reconciler := &NSMReconciler{
+			Client:    cluster.client,
+			Scheme:    r.Scheme,
+			Platform:  cluster.platform,
+			APIReader: cluster.client,
+			Recorder:  cluster.recorder,
+			HubDriven: true,
+		}
+		result, err = reconciler.Reconcile(log.IntoContext(ctx, Log), ctrl.Request{NamespacedName: name})
+		if err != nil {
+			memberStatus.Error = err.Error()
+		}
+	}
+
+	nsm := &nsmv1alpha1.NSM{}
+	if err := cluster.client.Get(ctx, name, nsm); err != nil {
+		memberStatus.Error = err.Error()
+		return memberStatus, result
+	}
+	memberStatus.Phase = nsm.Status.Phase
+	memberStatus.Platform = nsm.Status.Platform
+	memberStatus.Conditions = nsm.Status.Conditions
+	return memberStatus, result
+}
+
+// Connect to a member cluster, the connection is made again
+// when the kubeconfig in its secret changes
+func (r *NSMFleetReconciler) getMemberCluster(ctx context.Context, fleet *nsmv1alpha1.NSMFleet, member nsmv1alpha1.NSMFleetMember) (*memberCluster, error) {
+
+	secret := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: member.KubeconfigSecret.Name, Namespace: fleet.Namespace}, secret)
+	if err != nil {
+		return nil, err
+	}
+	key := member.KubeconfigSecret.Key
+	if key == "" {
+		key = defaultKubeconfigKey
+	}
+	kubeconfig, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("secret %s has no %s key", secret.Name, key)
+	}
+
+	memberKey := getMemberKey(fleet, member.Name)
+	hash := getConfigHash(string(kubeconfig))
+	if cluster, ok := r.members[memberKey]; ok {
+		if cluster.kubeconfigHash == hash {
+			return cluster, nil
+		}
+		cluster.broadcaster.Shutdown()
+		delete(r.members, memberKey)
+	}
+
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateKubeconfig(config); err != nil {
+		return nil, fmt.Errorf("kubeconfig of secret %s rejected: %w", secret.Name, err)
+	}
+	cfg, err := clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Timeout = memberRequestTimeout
+	memberClient, err := client.New(cfg, client.Options{Scheme: r.Scheme})
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	platform, err := DetectPlatform(ctx, cfg, memberClient)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to detect platform of member "+member.Name+", falling back to defaults")
+	}
+
+	// Events are recorded in the member cluster next to the objects they are about
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+	cluster := &memberCluster{
+		kubeconfigHash: hash,
+		client:         memberClient,
+		platform:       platform,
+		broadcaster:    broadcaster,
+		recorder:       broadcaster.NewRecorder(r.Scheme, corev1.EventSource{Component: "nsm-operator"}),
+	}
+	if r.members == nil {
+		r.members = map[string]*memberCluster{}
+	}
+	r.members[memberKey] = cluster
+	return cluster, nil
+}
+
+// Create or update the NSM instance of a member together with its
+// namespace and the NSM CRD, copied from the hub cluster
+func (r *NSMFleetReconciler) applyMemberNSM(ctx context.Context, Log logr.Logger, cluster *memberCluster, fleet *nsmv1alpha1.NSMFleet, spec *nsmv1alpha1.NSMSpec, localOperator bool) error {
+
+	// The operator of the member installs the CRD of its own version
+	if !localOperator {
+		if err := r.applyMemberCRD(ctx, Log, cluster); err != nil {
+			return err
+		}
+	}
+
+	ns := &corev1.Namespace{}
+	err := cluster.client.Get(ctx, types.NamespacedName{Name: memberNamespace}, ns)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		ns = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: memberNamespace}}
+		if err := cluster.client.Create(ctx, ns); err != nil {
+			return err
+		}
+		Log.Info("namespace " + memberNamespace + " created")
+	}
+
+	nsm := &nsmv1alpha1.NSM{}
+	err = cluster.client.Get(ctx, types.NamespacedName{Name: fleet.Name, Namespace: memberNamespace}, nsm)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		nsm = &nsmv1alpha1.NSM{
+			ObjectMeta: newObjectMeta(fleet.Name, memberNamespace, map[string]string{fleetLabel: fleet.Namespace + "." + fleet.Name}),
+			Spec:       *spec,
+		}
+		if err := cluster.client.Create(ctx, nsm); err != nil {
+			return err
+		}
+		Log.Info("NSM " + fleet.Name + " created")
+		return nil
+	}
+	if !equality.Semantic.DeepEqual(nsm.Spec, *spec) {
+		nsm.Spec = *spec
+		if err := cluster.client.Update(ctx, nsm); err != nil {
+			return err
+		}
+		Log.Info("NSM " + fleet.Name + " updated")
+	}
+	return nil
+}
+
+// Copy the NSM CRD of the hub to a member cluster
+func (r *NSMFleetReconciler) applyMemberCRD(ctx context.Context, Log logr.Logger, cluster *memberCluster) error {
+
+	hubCRD := &apiextensionsv1.CustomResourceDefinition{}
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Name: nsmCRDName}, hubCRD); err != nil {
+		return err
+	}
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	err := cluster.client.Get(ctx, types.NamespacedName{Name: nsmCRDName}, crd)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		crd = &apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: nsmCRDName, Annotations: hubCRD.Annotations},
+			Spec:       hubCRD.Spec,
+		}
+		if err := cluster.client.Create(ctx, crd); err != nil {
+			return err
+		}
+		Log.Info("NSM CRD created")
+	} else if !equality.Semantic.DeepEqual(crd.Spec, hubCRD.Spec) {
+		crd.Spec = hubCRD.Spec
+		if err := cluster.client.Update(ctx, crd); err != nil {
+			return err
+		}
+		Log.Info("NSM CRD updated")
+	}
+	return nil
+}
+
+// Look for a running nsm-operator in a member cluster
+func hasLocalOperator(ctx context.Context, c client.Client) (bool, error) {
+	deploys := &appsv1.DeploymentList{}
+	if err := c.List(ctx, deploys, client.InNamespace(memberNamespace), client.MatchingLabels(operatorLabels)); err != nil {
+		return false, err
+	}
+	for _, deploy := range deploys.Items {
+		if deploy.Spec.Replicas == nil || *deploy.Spec.Replicas > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Check a member kubeconfig only holds inline credentials, anyone able to
+// write the secret could otherwise run credential plugins in the operator
+// pod or have it send the files it can read, its own token included
+func validateKubeconfig(config *clientcmdapi.Config) error {
+	for name, authInfo := range config.AuthInfos {
+		switch {
+		case authInfo.Exec != nil:
+			return fmt.Errorf("user %s runs an exec credential plugin", name)
+		case authInfo.AuthProvider != nil:
+			return fmt.Errorf("user %s uses an auth provider", name)
+		case authInfo.TokenFile != "" || authInfo.ClientCertificate != "" || authInfo.ClientKey != "":
+			return fmt.Errorf("user %s reads its credentials from files", name)
+		}
+	}
+	for name, cluster := range config.Clusters {
+		if cluster.CertificateAuthority != "" {
+			return fmt.Errorf("cluster %s reads its certificate authority from a file", name)
+		}
+	}
+	return nil
+}
+
+// Remove a member within its own timeout
+func (r *NSMFleetReconciler) removeMemberWithTimeout(ctx context.Context, Log logr.Logger, fleet *nsmv1alpha1.NSMFleet, member nsmv1alpha1.NSMFleetMember) (bool, error) {
+	memberCtx, cancel := context.WithTimeout(ctx, memberReconcileTimeout)
+	defer cancel()
+	return r.removeMember(memberCtx, Log, fleet, member)
+}
+
+// Delete the NSM instance of a member and run its finalizer, nothing
+// else reconciles it in the member cluster. The connection is made
+// again when the operator restarted since the member was reconciled.
+// A member whose kubeconfig secret is gone can't be reached anymore,
+// its NSM instance is left in place and the member reported orphaned.
+func (r *NSMFleetReconciler) removeMember(ctx context.Context, Log logr.Logger, fleet *nsmv1alpha1.NSMFleet, member nsmv1alpha1.NSMFleetMember) (bool, error) {
+
+	name := member.Name
+	secret := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: member.KubeconfigSecret.Name, Namespace: fleet.Namespace}, secret)
+	if errors.IsNotFound(err) {
+		Log.Info("kubeconfig secret " + member.KubeconfigSecret.Name + " of member " + name + " not found, leaving its NSM instance")
+		if cluster, ok := r.members[getMemberKey(fleet, name)]; ok {
+			cluster.broadcaster.Shutdown()
+			delete(r.members, getMemberKey(fleet, name))
+		}
+		return true, nil
+	}
+	cluster, err := r.getMemberCluster(ctx, fleet, member)
+	if err != nil {
+		return false, err
+	}
+
+	nsm := &nsmv1alpha1.NSM{}
+	nsmName := types.NamespacedName{Name: fleet.Name, Namespace: memberNamespace}
+	err = cluster.client.Get(ctx, nsmName, nsm)
+	if err == nil {
+		if err := cluster.client.Delete(ctx, nsm); err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+		// The operator of the member runs the finalizer itself
+		localOperator, err := hasLocalOperator(ctx, cluster.client)
+		if err != nil {
+			return false, err
+		}
+		if localOperator {
+			Log.Info("NSM deleted from member " + name)
+			cluster.broadcaster.Shutdown()
+			delete(r.members, getMemberKey(fleet, name))
+			return false, nil
+		}
+		reconciler := &NSMReconciler{
+			Client:    cluster.client,
+			Scheme:    r.Scheme,
+			Platform:  cluster.platform,
+			APIReader: cluster.client,
+			Recorder:  cluster.recorder,
+			HubDriven: true,
+		}
+		if _, err := reconciler.Reconcile(log.IntoContext(ctx, Log.WithValues("member", name)), ctrl.Request{NamespacedName: nsmName}); err != nil {
+			return false, err
+		}
+		Log.Info("NSM removed from member " + name)
+	} else if !errors.IsNotFound(err) {
+		return false, err
+	}
+
+	cluster.broadcaster.Shutdown()
+	delete(r.members, getMemberKey(fleet, name))
+	return false, nil
+}
+
+// Apply the overrides of a member to the NSM spec template
+func getMemberSpec(fleet *nsmv1alpha1.NSMFleet, member nsmv1alpha1.NSMFleetMember) (*nsmv1alpha1.NSMSpec, error) {
+
+	spec := fleet.Spec.Template.DeepCopy()
+	if member.Overrides == nil || len(member.Overrides.Raw) == 0 {
+		return spec, nil
+	}
+	template, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := jsonpatch.MergePatch(template, member.Overrides.Raw)
+	if err != nil {
+		return nil, err
+	}
+	spec = &nsmv1alpha1.NSMSpec{}
+	if err := json.Unmarshal(patched, spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// Add a member to the orphaned ones once
+func appendOrphanedMember(orphaned []string, name string) []string {
+	if containsString(orphaned, name) {
+		return orphaned
+	}
+	return append(orphaned, name)
+}
+
+func getMemberKey(fleet *nsmv1alpha1.NSMFleet, member string) string {
+	return fleet.Namespace + "/" + fleet.Name + "/" + member
+}
+
+// Get the Ready condition of the fleet status
+func getFleetCondition(fleet *nsmv1alpha1.NSMFleet) metav1.Condition {
+	notReady := []string{}
+	for _, member := range fleet.Status.Members {
+		if member.Phase != nsmv1alpha1.NSMPhaseRunning || member.Error != "" {
+			notReady = append(notReady, member.Name)
+		}
+	}
+	if len(notReady) > 0 {
+		return metav1.Condition{
+			Type:    nsmv1alpha1.NSMFleetConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  nsmv1alpha1.NSMFleetReasonMembersNotReady,
+			Message: "NSM is not running in members: " + strings.Join(notReady, ", "),
+		}
+	}
+	return metav1.Condition{
+		Type:    nsmv1alpha1.NSMFleetConditionReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  nsmv1alpha1.NSMFleetReasonMembersReady,
+		Message: "NSM is running in every member",
+	}
+}
+
+// Get the fleets using a kubeconfig secret
+func (r *NSMFleetReconciler) fleetsForSecret(obj client.Object) []reconcile.Request {
+	fleets := &nsmv1alpha1.NSMFleetList{}
+	if err := r.Client.List(context.Background(), fleets, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, fleet := range fleets.Items {
+		for _, member := range fleet.Spec.Members {
+			if member.KubeconfigSecret.Name == obj.GetName() {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: fleet.Name, Namespace: fleet.Namespace},
+				})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// SetupWithManager registers the controller with the manager and watches the kubeconfig secrets
+func (r *NSMFleetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&nsmv1alpha1.NSMFleet{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.fleetsForSecret)).
+		Complete(r)
+}
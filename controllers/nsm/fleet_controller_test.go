@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestValidateKubeconfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		authInfo clientcmdapi.AuthInfo
+		cluster  clientcmdapi.Cluster
+		valid    bool
+	}{
+		{"inline token", clientcmdapi.AuthInfo{Token: "token"}, clientcmdapi.Cluster{CertificateAuthorityData: []byte("ca")}, true},
+		{"inline certificate", clientcmdapi.AuthInfo{ClientCertificateData: []byte("cert"), ClientKeyData: []byte("key")}, clientcmdapi.Cluster{}, true},
+		{"exec plugin", clientcmdapi.AuthInfo{Exec: &clientcmdapi.ExecConfig{Command: "sh"}}, clientcmdapi.Cluster{}, false},
+		{"auth provider", clientcmdapi.AuthInfo{AuthProvider: &clientcmdapi.AuthProviderConfig{Name: "gcp"}}, clientcmdapi.Cluster{}, false},
+		{"token file", clientcmdapi.AuthInfo{TokenFile: "/var/run/secrets/kubernetes.io/serviceaccount/token"}, clientcmdapi.Cluster{}, false},
+		{"certificate file", clientcmdapi.AuthInfo{ClientCertificate: "/tmp/cert", ClientKey: "/tmp/key"}, clientcmdapi.Cluster{}, false},
+		{"certificate authority file", clientcmdapi.AuthInfo{Token: "token"}, clientcmdapi.Cluster{CertificateAuthority: "/tmp/ca"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := clientcmdapi.NewConfig()
+			config.AuthInfos["member"] = &test.authInfo
+			config.Clusters["member"] = &test.cluster
+			err := validateKubeconfig(config)
+			if test.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Error("expected the kubeconfig to be rejected")
+			}
+		})
+	}
+}
+
+func TestGetMemberSpec(t *testing.T) {
+	fleet := &nsmv1alpha1.NSMFleet{
+		Spec: nsmv1alpha1.NSMFleetSpec{
+			Template: nsmv1alpha1.NSMSpec{
+				Version:     "v1.7.0",
+				NsmLogLevel: "INFO",
+				Spiffe:      nsmv1alpha1.Spiffe{TrustDomain: "hub.example.org"},
+				Forwarders:  []nsmv1alpha1.Forwarder{{Type: nsmv1alpha1.ForwarderVpp}},
+			},
+		},
+	}
+	overrides := func(raw string) *apiextensionsv1.JSON {
+		return &apiextensionsv1.JSON{Raw: []byte(raw)}
+	}
+	tests := []struct {
+		name      string
+		overrides *apiextensionsv1.JSON
+		want      func(spec *nsmv1alpha1.NSMSpec)
+		wantErr   bool
+	}{
+		{name: "no overrides", want: func(spec *nsmv1alpha1.NSMSpec) {}},
+		{name: "empty overrides", overrides: overrides(""), want: func(spec *nsmv1alpha1.NSMSpec) {}},
+		{
+			name:      "field override",
+			overrides: overrides(`{"nsmLogLevel":"DEBUG"}`),
+			want:      func(spec *nsmv1alpha1.NSMSpec) { spec.NsmLogLevel = "DEBUG" },
+		},
+		{
+			name:      "nested field override keeps the siblings",
+			overrides: overrides(`{"spiffe":{"svidTTL":"1h"}}`),
+			want: func(spec *nsmv1alpha1.NSMSpec) {
+				spec.Spiffe.SVIDTTL = &metav1.Duration{Duration: time.Hour}
+			},
+		},
+		{
+			name:      "list override replaces the list",
+			overrides: overrides(`{"forwarders":[{"type":"ovs"}]}`),
+			want: func(spec *nsmv1alpha1.NSMSpec) {
+				spec.Forwarders = []nsmv1alpha1.Forwarder{{Type: nsmv1alpha1.ForwarderOvs}}
+			},
+		},
+		{
+			name:      "null removes the field",
+			overrides: overrides(`{"spiffe":{"trustDomain":null}}`),
+			want:      func(spec *nsmv1alpha1.NSMSpec) { spec.Spiffe.TrustDomain = "" },
+		},
+		{name: "invalid overrides", overrides: overrides(`{"nsmLogLevel":`), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			spec, err := getMemberSpec(fleet, nsmv1alpha1.NSMFleetMember{Name: "member", Overrides: test.overrides})
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want := fleet.Spec.Template.DeepCopy()
+			test.want(want)
+			if !reflect.DeepEqual(spec, want) {
+				t.Errorf("getMemberSpec() = %+v, want %+v", spec, want)
+			}
+			if spec == &fleet.Spec.Template {
+				t.Errorf("getMemberSpec() returned the fleet template")
+			}
+		})
+	}
+}
+
+func TestFleetMembersWithoutSecret(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	secret := nsmv1alpha1.KubeconfigSecretReference{Name: "gone-kubeconfig"}
+	fleet := &nsmv1alpha1.NSMFleet{
+		ObjectMeta: metav1.ObjectMeta{Name: "fleet", Namespace: "nsm", Finalizers: []string{fleetFinalizer}},
+		Status: nsmv1alpha1.NSMFleetStatus{
+			Members: []nsmv1alpha1.NSMFleetMemberStatus{{Name: "gone", KubeconfigSecret: &secret}},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(fleet).Build()
+	r := &NSMFleetReconciler{Client: c, Scheme: scheme, APIReader: c}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "fleet", Namespace: "nsm"}}
+
+	reconcile := func() *nsmv1alpha1.NSMFleet {
+		if _, err := r.Reconcile(context.TODO(), req); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+		fleet := &nsmv1alpha1.NSMFleet{}
+		if err := c.Get(context.TODO(), req.NamespacedName, fleet); err != nil {
+			t.Fatal(err)
+		}
+		return fleet
+	}
+
+	// The member dropped from the fleet can't be reached anymore
+	fleet = reconcile()
+	if len(fleet.Status.Members) != 0 || !reflect.DeepEqual(fleet.Status.OrphanedMembers, []string{"gone"}) {
+		t.Errorf("members = %v, orphaned = %v, want the member orphaned", fleet.Status.Members, fleet.Status.OrphanedMembers)
+	}
+	fleet = reconcile()
+	if !reflect.DeepEqual(fleet.Status.OrphanedMembers, []string{"gone"}) {
+		t.Errorf("orphaned = %v, want the member kept orphaned", fleet.Status.OrphanedMembers)
+	}
+
+	// Added again, the member is managed by the fleet
+	fleet.Spec.Members = []nsmv1alpha1.NSMFleetMember{{Name: "gone", KubeconfigSecret: secret}}
+	if err := c.Update(context.TODO(), fleet); err != nil {
+		t.Fatal(err)
+	}
+	fleet = reconcile()
+	if len(fleet.Status.OrphanedMembers) != 0 {
+		t.Errorf("orphaned = %v, want none", fleet.Status.OrphanedMembers)
+	}
+	if len(fleet.Status.Members) != 1 || fleet.Status.Members[0].Error == "" {
+		t.Errorf("members = %v, want the member without secret in error", fleet.Status.Members)
+	}
+
+	// The missing secret doesn't hold the fleet finalizer
+	if err := c.Delete(context.TODO(), fleet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.TODO(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	fleet = &nsmv1alpha1.NSMFleet{}
+	if err := c.Get(context.TODO(), req.NamespacedName, fleet); err == nil && controllerutil.ContainsFinalizer(fleet, fleetFinalizer) {
+		t.Errorf("fleet finalizer kept")
+	}
+}
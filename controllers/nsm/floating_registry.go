@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	floatingRegistryName string = "floating-registry"
+	floatingRegistryPort int32  = 5002
+)
+
+// FloatingRegistryReconciler deploys a registry shared by several
+// domains, exposed through a load balancer under a stable DNS name
+type FloatingRegistryReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func NewFloatingRegistryReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme) *FloatingRegistryReconciler {
+	return &FloatingRegistryReconciler{
+		Client: client,
+		Log:    log,
+		Scheme: scheme,
+	}
+}
+
+func (r *FloatingRegistryReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	// The registry is removed along with its load balancer when
+	// it's disabled, or when interdomain is no longer there
+	if !nsm.Spec.FloatingRegistry.Enabled || validateFloatingRegistry(nsm) != nil {
+		return deleteComponentObjects(ctx, r.Client, r.Log, nsm,
+			&appsv1.Deployment{ObjectMeta: newObjectMeta(floatingRegistryName, nsm.ObjectMeta.Namespace, nil)},
+			&corev1.Service{ObjectMeta: newObjectMeta(floatingRegistryName, nsm.ObjectMeta.Namespace, nil)})
+	}
+
+	if _, err := reconcileComponentDeployment(ctx, r.Client, r.Log, r.deploymentForFloatingRegistry(nsm)); err != nil {
+		return err
+	}
+	return reconcileExposedService(ctx, r.Client, r.Log, r.serviceForFloatingRegistry(nsm))
+}
+
+func (r *FloatingRegistryReconciler) deploymentForFloatingRegistry(nsm *nsmv1alpha1.NSM) *appsv1.Deployment {
+
+	labels := map[string]string{"app": floatingRegistryName, "spiffe.io/spiffe-id": "true"}
+	replicas := int32(1)
+
+	// The registrations of the other domains are forwarded
+	// through the local nsmgr-proxy
+	envVars := mergeEnvVars([]corev1.EnvVar{
+		{Name: "REGISTRY_MEMORY_LISTEN_ON", Value: fmt.Sprintf("tcp://:%d", floatingRegistryPort)},
+		{Name: "REGISTRY_MEMORY_PROXY_REGISTRY_URL", Value: fmt.Sprintf("%s:%d", nsmgrProxyName, nsmgrProxyPort)},
+		{Name: "REGISTRY_MEMORY_LOG_LEVEL", Value: getNsmLogLevel(nsm)},
+	}, nsm.Spec.FloatingRegistry.EnvVars)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: newObjectMeta(floatingRegistryName, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"}),
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: getFederationAnnotations(nsm),
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: registryServiceAccountName,
+					SecurityContext:    getPodSecurityContext(nsm.Spec.Registry.PodSecurity, true),
+					Containers: []corev1.Container{{
+						Name:            floatingRegistryName,
+						Image:           nsm.Spec.FloatingRegistry.Image,
+						ImagePullPolicy: nsm.Spec.NsmPullPolicy,
+						SecurityContext: getUnprivilegedSecurityContext(),
+						Env:             insertSpireAgentSocketEnv(envVars, getSpireAgentSocket(nsm)),
+						Ports: []corev1.ContainerPort{{
+							ContainerPort: floatingRegistryPort}},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "spire-agent-socket",
+								MountPath: getSpireAgentSocketDir(nsm),
+								ReadOnly:  true},
+						},
+					}},
+					Volumes: []corev1.Volume{
+						getSpireAgentSocketVolume(nsm),
+					},
+				},
+			},
+		},
+	}
+	setConfigHash(&deploy.Spec.Template)
+
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, deploy, r.Scheme)
+	return deploy
+}
+
+func (r *FloatingRegistryReconciler) serviceForFloatingRegistry(nsm *nsmv1alpha1.NSM) *corev1.Service {
+
+	svc := &corev1.Service{
+		ObjectMeta: newObjectMeta(floatingRegistryName, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"}),
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": floatingRegistryName},
+			Ports: []corev1.ServicePort{{
+				Name:       floatingRegistryName,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       floatingRegistryPort,
+				TargetPort: intstr.FromInt(int(floatingRegistryPort)),
+			}},
+			Type:           corev1.ServiceTypeLoadBalancer,
+			LoadBalancerIP: nsm.Spec.FloatingRegistry.LoadBalancerIP,
+		},
+	}
+	svc.Annotations = setExternalDNSHostname(nil, nsm.Spec.FloatingRegistry.Hostname)
+
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, svc, r.Scheme)
+	return svc
+}
+
+// Get the host alias resolving the floating registry hosted next to the NSM
+// instance to its cluster IP, the local registry-proxy-dns resolving the
+// floating domain reaches it without going through the load balancer
+// and before the DNS name is published
+func getFloatingRegistryHostAliases(ctx context.Context, reader client.Reader, nsm *nsmv1alpha1.NSM) ([]corev1.HostAlias, error) {
+
+	if !nsm.Spec.FloatingRegistry.Enabled || nsm.Spec.FloatingRegistry.Hostname == "" {
+		return nil, nil
+	}
+	svc := &corev1.Service{}
+	err := reader.Get(ctx, types.NamespacedName{Name: floatingRegistryName, Namespace: nsm.ObjectMeta.Namespace}, svc)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+		return nil, nil
+	}
+	return []corev1.HostAlias{{
+		IP:        svc.Spec.ClusterIP,
+		Hostnames: []string{nsm.Spec.FloatingRegistry.Hostname},
+	}}, nil
+}
+
+// The floating registry forwards the registrations of the other
+// domains through nsmgr-proxy, it needs interdomain NSM
+func validateFloatingRegistry(nsm *nsmv1alpha1.NSM) error {
+	if !nsm.Spec.Interdomain.Enabled {
+		return errors.New("the floating registry requires interdomain to be enabled")
+	}
+	return nil
+}
+
+func getFloatingRegistryCondition(err error) metav1.Condition {
+	if err != nil {
+		return metav1.Condition{
+			Type:    nsmv1alpha1.NSMConditionFloatingRegistryValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  nsmv1alpha1.NSMReasonFloatingRegistryInvalid,
+			Message: err.Error(),
+		}
+	}
+	return metav1.Condition{
+		Type:    nsmv1alpha1.NSMConditionFloatingRegistryValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  nsmv1alpha1.NSMReasonFloatingRegistryValid,
+		Message: "the floating registry is deployed",
+	}
+}
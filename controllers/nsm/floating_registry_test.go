@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"testing"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidateFloatingRegistry(t *testing.T) {
+	tests := []struct {
+		name        string
+		interdomain bool
+		wantStatus  metav1.ConditionStatus
+	}{
+		{name: "interdomain enabled", interdomain: true, wantStatus: metav1.ConditionTrue},
+		{name: "interdomain disabled", interdomain: false, wantStatus: metav1.ConditionFalse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsm := &nsmv1alpha1.NSM{}
+			nsm.Spec.FloatingRegistry.Enabled = true
+			nsm.Spec.Interdomain.Enabled = tt.interdomain
+
+			err := validateFloatingRegistry(nsm)
+			if (err != nil) != (tt.wantStatus == metav1.ConditionFalse) {
+				t.Fatalf("validateFloatingRegistry() error = %v", err)
+			}
+			if cond := getFloatingRegistryCondition(err); cond.Status != tt.wantStatus {
+				t.Errorf("condition status = %s, want %s", cond.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestFloatingRegistryHostAliases(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+	hostAliases := []corev1.HostAlias{{IP: "10.0.0.10", Hostnames: []string{"floating.example.com"}}}
+
+	// registry-proxy-dns resolves the floating domain, not nsmgr-proxy
+	r := &InterdomainReconciler{Scheme: scheme}
+	if got := r.deploymentForRegistryProxyDNS(nsm, hostAliases).Spec.Template.Spec.HostAliases; len(got) != 1 {
+		t.Errorf("registry-proxy-dns host aliases = %v, want %v", got, hostAliases)
+	}
+	if got := r.deploymentForNsmgrProxy(nsm).Spec.Template.Spec.HostAliases; len(got) != 0 {
+		t.Errorf("nsmgr-proxy host aliases = %v, want none", got)
+	}
+}
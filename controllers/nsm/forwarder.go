@@ -7,11 +7,9 @@ import (
 	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -32,41 +30,33 @@ func NewForwarderReconciler(client client.Client, log logr.Logger, scheme *runti
 	}
 }
 
+// Each reconciler renders the forwarders of its type, their daemonsets
+// are rolled when anything rendered from the NSM instance changes
 func (r *ForwarderReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
 
 	for _, fp := range nsm.Spec.Forwarders {
-
-		ds := &appsv1.DaemonSet{}
+		if fp.Type != r.ForwarderType {
+			continue
+		}
 		Name := fp.Name
 		if Name == "" {
 			Name = "forwarder-" + string(fp.Type)
 		}
-		err := r.Client.Get(ctx, types.NamespacedName{Name: Name, Namespace: nsm.ObjectMeta.Namespace}, ds)
-		if err != nil {
-			if apierrors.IsNotFound(err) {
-
-				objectMeta := newObjectMeta(Name, "nsm", map[string]string{"app": "nsm"})
-				ds = r.daemonSetForForwarder(nsm, objectMeta, r.ForwarderType, fp.EnvVars)
-
-				err = r.Client.Create(context.TODO(), ds)
-				if err != nil {
-					r.Log.Error(err, "failed to create deployment for "+Name)
-					return err
-				}
-				r.Log.Info("nsm " + Name + " daemonset created")
-				return nil
-			}
+		objectMeta := newObjectMeta(Name, "nsm", map[string]string{"app": "nsm"})
+		ds := r.daemonSetForForwarder(nsm, objectMeta, r.ForwarderType, fp.EnvVars)
+		if err := reconcileComponentDaemonSet(ctx, r.Client, r.Log, ds); err != nil {
 			return err
 		}
-		r.Log.Info("nsm " + Name + " daemonset already exists, skipping creation")
 	}
 	return nil
 }
 
 func (r *ForwarderReconciler) daemonSetForForwarder(nsm *nsmv1alpha1.NSM, objectMeta metav1.ObjectMeta, ForwarderType nsmv1alpha1.ForwarderType, envVars []corev1.EnvVar) *appsv1.DaemonSet {
 
-	privmode := true
 	forwarderLabel := map[string]string{"app": "forwarder", "spiffe.io/spiffe-id": "true"}
+	// forwarders switch network namespaces and drive the dataplane with
+	// syscalls the runtime default seccomp profile doesn't allow
+	podSecurity := getForwarderPodSecurity(nsm, ForwarderType)
 
 	if envVars == nil {
 		envVars = getEnvVars(nsm, ForwarderType)
@@ -81,10 +71,12 @@ func (r *ForwarderReconciler) daemonSetForForwarder(nsm *nsmv1alpha1.NSM, object
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: forwarderLabel,
+					Labels:      forwarderLabel,
+					Annotations: mergeAnnotations(getAppArmorAnnotations(podSecurity, objectMeta.Name), getFederationAnnotations(nsm)),
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName: serviceAccountName,
+					ServiceAccountName: forwarderServiceAccountName,
+					SecurityContext:    getPodSecurityContext(podSecurity, false),
 					HostPID:            true,
 					HostNetwork:        true,
 					DNSPolicy:          corev1.DNSClusterFirstWithHostNet,
@@ -95,21 +87,20 @@ func (r *ForwarderReconciler) daemonSetForForwarder(nsm *nsmv1alpha1.NSM, object
 							Name:            objectMeta.Name,
 							Image:           getForwarderImage(nsm, ForwarderType),
 							ImagePullPolicy: nsm.Spec.NsmPullPolicy,
-							SecurityContext: &corev1.SecurityContext{
-								Privileged: &privmode,
-							},
-							Env:            insertSpireAgentSocketEnv(envVars, getSpireAgentSocket(nsm)),
-							ReadinessProbe: getReadinessProbe(ForwarderType),
-							LivenessProbe:  getLivenessProbe(ForwarderType),
-							StartupProbe:   getStartupProbe(ForwarderType),
-							VolumeMounts:   getVolumeMounts(ForwarderType),
-							Resources:      getForwarderResourceReqs(ForwarderType),
+							SecurityContext: getForwarderSecurityContext(nsm, ForwarderType),
+							Env:             insertSpireAgentSocketEnv(envVars, getSpireAgentSocket(nsm)),
+							ReadinessProbe:  getReadinessProbe(ForwarderType),
+							LivenessProbe:   getLivenessProbe(ForwarderType),
+							StartupProbe:    getStartupProbe(ForwarderType),
+							VolumeMounts:    getVolumeMounts(nsm, ForwarderType),
+							Resources:       getForwarderResourceReqs(ForwarderType),
 						}},
-					Volumes: getVolumes(ForwarderType),
+					Volumes: getVolumes(nsm, ForwarderType),
 				},
 			},
 		},
 	}
+	setConfigHash(&daemonset.Spec.Template)
 
 	// Set NSM instance as the owner and controller
 	controllerutil.SetControllerReference(nsm, daemonset, r.Scheme)
@@ -186,13 +177,13 @@ func getEnvVars(nsm *nsmv1alpha1.NSM, ForwarderType nsmv1alpha1.ForwarderType) [
 	return EnvVars
 }
 
-func getVolumeMounts(ForwarderType nsmv1alpha1.ForwarderType) []corev1.VolumeMount {
+func getVolumeMounts(nsm *nsmv1alpha1.NSM, ForwarderType nsmv1alpha1.ForwarderType) []corev1.VolumeMount {
 	VolMounts := []corev1.VolumeMount{
 		{Name: "nsm-socket",
 			MountPath: "/var/lib/networkservicemesh/",
 		},
 		{Name: "spire-agent-socket",
-			MountPath: "/run/spire/sockets",
+			MountPath: getSpireAgentSocketDir(nsm),
 			ReadOnly:  true,
 		},
 		{Name: "kubelet-socket",
@@ -211,8 +202,9 @@ func getVolumeMounts(ForwarderType nsmv1alpha1.ForwarderType) []corev1.VolumeMou
 	return VolMounts
 }
 
-func getVolumes(ForwarderType nsmv1alpha1.ForwarderType) []corev1.Volume {
+func getVolumes(nsm *nsmv1alpha1.NSM, ForwarderType nsmv1alpha1.ForwarderType) []corev1.Volume {
 
+	hostPaths := getHostPaths(nsm)
 	volTypeDirOrCreate := corev1.HostPathDirectoryOrCreate
 	volTypeDir := corev1.HostPathDirectory
 
@@ -221,35 +213,29 @@ func getVolumes(ForwarderType nsmv1alpha1.ForwarderType) []corev1.Volume {
 			Name: "nsm-socket",
 			VolumeSource: corev1.VolumeSource{
 				HostPath: &corev1.HostPathVolumeSource{
-					Path: "/var/lib/networkservicemesh",
+					Path: hostPaths.NsmSocket,
 					Type: &volTypeDirOrCreate,
 				}}},
-		{
-			Name: "spire-agent-socket",
-			VolumeSource: corev1.VolumeSource{
-				HostPath: &corev1.HostPathVolumeSource{
-					Path: "/run/spire/sockets",
-					Type: &volTypeDir,
-				}}},
+		getSpireAgentSocketVolume(nsm),
 		{
 			Name: "kubelet-socket",
 			VolumeSource: corev1.VolumeSource{
 				HostPath: &corev1.HostPathVolumeSource{
-					Path: "/var/lib/kubelet",
+					Path: hostPaths.Kubelet,
 					Type: &volTypeDir,
 				}}},
 		{
 			Name: "cgroup",
 			VolumeSource: corev1.VolumeSource{
 				HostPath: &corev1.HostPathVolumeSource{
-					Path: "/sys/fs/cgroup",
+					Path: hostPaths.Cgroup,
 					Type: &volTypeDir,
 				}}},
 		{
 			Name: "vfio",
 			VolumeSource: corev1.VolumeSource{
 				HostPath: &corev1.HostPathVolumeSource{
-					Path: "/dev/vfio",
+					Path: hostPaths.Vfio,
 					Type: &volTypeDirOrCreate,
 				}}}}
 
@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Images injected by admission-webhook-k8s
+var nscImageNames = []string{"cmd-nsc", "cmd-nsc-init"}
+
+// InjectionPolicyReconciler checks the pods annotated for NSM client
+// injection against the NSMInjectionPolicies of their namespace
+type InjectionPolicyReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Uncached reader for the policies and pods of the client namespaces
+	APIReader client.Reader
+}
+
+func NewInjectionPolicyReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme, apiReader client.Reader) *InjectionPolicyReconciler {
+	return &InjectionPolicyReconciler{
+		Client:    client,
+		Log:       log,
+		Scheme:    scheme,
+		APIReader: apiReader,
+	}
+}
+
+func (r *InjectionPolicyReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	policies, err := listInjectionPolicies(ctx, r.APIReader)
+	if err != nil {
+		return err
+	}
+
+	annotation := getWebhookAnnotation(nsm)
+	for i := range policies {
+		policy := &policies[i]
+		pods := &corev1.PodList{}
+		if err := r.APIReader.List(ctx, pods, client.InNamespace(policy.Namespace)); err != nil {
+			return err
+		}
+
+		status := policy.Status.DeepCopy()
+		policy.Status.Violations = nil
+		for _, pod := range pods.Items {
+			value, ok := pod.Annotations[annotation]
+			if !ok {
+				continue
+			}
+			if reasons := checkInjectionPolicy(policy, value, pod.Spec); len(reasons) > 0 {
+				policy.Status.Violations = append(policy.Status.Violations, nsmv1alpha1.PolicyViolation{
+					Pod:    pod.Name,
+					Reason: strings.Join(reasons, "; "),
+				})
+			}
+		}
+		policy.Status.ObservedGeneration = policy.Generation
+		meta.SetStatusCondition(&policy.Status.Conditions, getInjectionPolicyCondition(policy))
+
+		if !equality.Semantic.DeepEqual(status, &policy.Status) {
+			if err := r.Client.Status().Update(ctx, policy); err != nil {
+				r.Log.Error(err, "failed to update the status of injection policy "+policy.Namespace+"/"+policy.Name)
+				return err
+			}
+			r.Log.Info("injection policy " + policy.Namespace + "/" + policy.Name + " status updated")
+		}
+	}
+	return nil
+}
+
+// Get the NSM instances running the webhook, the policies apply to all of them
+func (r *NSMReconciler) nsmsForInjectionPolicy(obj client.Object) []reconcile.Request {
+	nsms := &nsmv1alpha1.NSMList{}
+	if err := r.Client.List(context.Background(), nsms); err != nil {
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, nsm := range nsms.Items {
+		if nsm.Spec.Webhook.Image != "" {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: nsm.Name, Namespace: nsm.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// List the NSMInjectionPolicies of all the namespaces, none when their CRD isn't installed
+func listInjectionPolicies(ctx context.Context, reader client.Reader) ([]nsmv1alpha1.NSMInjectionPolicy, error) {
+	policies := &nsmv1alpha1.NSMInjectionPolicyList{}
+	if err := reader.List(ctx, policies); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return policies.Items, nil
+}
+
+// Get the namespaces denying NSM client injection, the webhook isn't called for their pods
+func getDeniedNamespaces(policies []nsmv1alpha1.NSMInjectionPolicy) []string {
+	denied := map[string]bool{}
+	for _, policy := range policies {
+		if policy.Spec.Injection == nsmv1alpha1.InjectionDeny {
+			denied[policy.Namespace] = true
+		}
+	}
+	namespaces := []string{}
+	for namespace := range denied {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// Check an annotated pod against a policy, returning what it does against it.
+// Pods created before the policy may run other NSC versions or environments.
+func checkInjectionPolicy(policy *nsmv1alpha1.NSMInjectionPolicy, annotation string, spec corev1.PodSpec) []string {
+
+	reasons := []string{}
+	if policy.Spec.Injection == nsmv1alpha1.InjectionDeny {
+		reasons = append(reasons, "NSM client injection is denied in the namespace")
+	}
+	reasons = append(reasons, checkRequestedNetworkServices(policy, annotation)...)
+
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		name, tag := splitImage(container.Image)
+		if !containsString(nscImageNames, name) {
+			continue
+		}
+		if policy.Spec.NSCVersion != "" && tag != policy.Spec.NSCVersion {
+			reasons = append(reasons, "container "+container.Name+" runs "+container.Image+" instead of "+policy.Spec.NSCVersion)
+		}
+		for _, envName := range getSortedKeys(policy.Spec.Env) {
+			if !hasEnvVar(container.Env, envName, policy.Spec.Env[envName]) {
+				reasons = append(reasons, "container "+container.Name+" doesn't set "+envName+"="+policy.Spec.Env[envName])
+			}
+		}
+	}
+	return reasons
+}
+
+// Check the network services requested by an annotated pod are allowed by a policy
+func checkRequestedNetworkServices(policy *nsmv1alpha1.NSMInjectionPolicy, annotation string) []string {
+	reasons := []string{}
+	if len(policy.Spec.NetworkServices) == 0 {
+		return reasons
+	}
+	for _, networkService := range getRequestedNetworkServices(annotation) {
+		if !containsString(policy.Spec.NetworkServices, networkService) {
+			reasons = append(reasons, "network service "+networkService+" is not allowed")
+		}
+	}
+	return reasons
+}
+
+// Set the NSC version and the environment of a policy on the injected containers
+func applyInjectionPolicy(policy *nsmv1alpha1.NSMInjectionPolicy, spec *corev1.PodSpec) {
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			container := &containers[i]
+			name, _ := splitImage(container.Image)
+			if !containsString(nscImageNames, name) {
+				continue
+			}
+			if policy.Spec.NSCVersion != "" {
+				container.Image = setImageTag(container.Image, policy.Spec.NSCVersion)
+			}
+			for _, envName := range getSortedKeys(policy.Spec.Env) {
+				container.Env = mergeEnvVars(container.Env, []corev1.EnvVar{{Name: envName, Value: policy.Spec.Env[envName]}})
+			}
+		}
+	}
+}
+
+// Get the network services requested by the value of the injection annotation,
+// a comma separated list of URLs such as "kernel://my-service/nsm-1". The
+// services of other domains, "my-service@domain", are parsed as user info.
+func getRequestedNetworkServices(annotation string) []string {
+	networkServices := []string{}
+	for _, request := range strings.Split(annotation, ",") {
+		request = strings.TrimSpace(request)
+		if request == "" {
+			continue
+		}
+		if u, err := url.Parse(request); err == nil && u.Host != "" {
+			if u.User != nil {
+				networkServices = append(networkServices, u.User.String()+"@"+u.Host)
+				continue
+			}
+			networkServices = append(networkServices, u.Host)
+			continue
+		}
+		networkServices = append(networkServices, strings.SplitN(request, "/", 2)[0])
+	}
+	return networkServices
+}
+
+// Split an image reference into the last element of its repository and its tag
+func splitImage(image string) (name, tag string) {
+	image = strings.SplitN(image, "@", 2)[0]
+	name = image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+// Replace the tag of an image reference, its digest is dropped
+func setImageTag(image, tag string) string {
+	image = strings.SplitN(image, "@", 2)[0]
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image + ":" + tag
+}
+
+func hasEnvVar(envVars []corev1.EnvVar, name, value string) bool {
+	for _, envVar := range envVars {
+		if envVar.Name == name && envVar.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func getSortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get the Compliant condition of the policy status
+func getInjectionPolicyCondition(policy *nsmv1alpha1.NSMInjectionPolicy) metav1.Condition {
+	if len(policy.Status.Violations) > 0 {
+		return metav1.Condition{
+			Type:               nsmv1alpha1.InjectionPolicyConditionCompliant,
+			Status:             metav1.ConditionFalse,
+			Reason:             nsmv1alpha1.InjectionPolicyReasonPodsViolating,
+			Message:            "annotated pods don't follow the policy, see the violations",
+			ObservedGeneration: policy.Generation,
+		}
+	}
+	return metav1.Condition{
+		Type:               nsmv1alpha1.InjectionPolicyConditionCompliant,
+		Status:             metav1.ConditionTrue,
+		Reason:             nsmv1alpha1.InjectionPolicyReasonPodsCompliant,
+		Message:            "annotated pods follow the policy",
+		ObservedGeneration: policy.Generation,
+	}
+}
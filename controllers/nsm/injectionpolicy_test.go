@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetRequestedNetworkServices(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       []string
+	}{
+		{name: "single service", annotation: "kernel://my-service/nsm-1", want: []string{"my-service"}},
+		{
+			name:       "several services",
+			annotation: "kernel://service-a/nsm-1, memif://service-b/nsm-2",
+			want:       []string{"service-a", "service-b"},
+		},
+		{name: "labels", annotation: "kernel://my-service/nsm-1?app=client", want: []string{"my-service"}},
+		{name: "service of another domain", annotation: "kernel://my-service@domain2.example.org/nsm-1", want: []string{"my-service@domain2.example.org"}},
+		{name: "without mechanism", annotation: "my-service/nsm-1", want: []string{"my-service"}},
+		{name: "empty entries", annotation: " , kernel://my-service/nsm-1,", want: []string{"my-service"}},
+		{name: "empty", annotation: "", want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getRequestedNetworkServices(tt.annotation); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRequestedNetworkServices(%q) = %v, want %v", tt.annotation, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		image    string
+		wantName string
+		wantTag  string
+	}{
+		{"ghcr.io/networkservicemesh/cmd-nsc:v1.7.0", "cmd-nsc", "v1.7.0"},
+		{"cmd-nsc", "cmd-nsc", ""},
+		{"registry.local:5000/cmd-nsc:v1.7.0", "cmd-nsc", "v1.7.0"},
+		{"registry.local:5000/cmd-nsc", "cmd-nsc", ""},
+		{"ghcr.io/networkservicemesh/cmd-nsc:v1.7.0@sha256:abcd", "cmd-nsc", "v1.7.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			name, tag := splitImage(tt.image)
+			if name != tt.wantName || tag != tt.wantTag {
+				t.Errorf("splitImage(%q) = %q, %q, want %q, %q", tt.image, name, tag, tt.wantName, tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestSetImageTag(t *testing.T) {
+	tests := []struct {
+		image string
+		want  string
+	}{
+		{"ghcr.io/networkservicemesh/cmd-nsc:v1.7.0", "ghcr.io/networkservicemesh/cmd-nsc:v1.8.0"},
+		{"cmd-nsc", "cmd-nsc:v1.8.0"},
+		{"registry.local:5000/cmd-nsc", "registry.local:5000/cmd-nsc:v1.8.0"},
+		{"registry.local:5000/cmd-nsc:v1.7.0", "registry.local:5000/cmd-nsc:v1.8.0"},
+		{"ghcr.io/networkservicemesh/cmd-nsc:v1.7.0@sha256:abcd", "ghcr.io/networkservicemesh/cmd-nsc:v1.8.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := setImageTag(tt.image, "v1.8.0"); got != tt.want {
+				t.Errorf("setImageTag(%q) = %q, want %q", tt.image, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyInjectionPolicy(t *testing.T) {
+	spec := corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "cmd-nsc-init", Image: "ghcr.io/networkservicemesh/cmd-nsc-init:v1.7.0"}},
+		Containers: []corev1.Container{
+			{Name: "app", Image: "nginx:1.21", Env: []corev1.EnvVar{{Name: "NSM_LOG_LEVEL", Value: "INFO"}}},
+			{Name: "cmd-nsc", Image: "ghcr.io/networkservicemesh/cmd-nsc:v1.7.0", Env: []corev1.EnvVar{{Name: "NSM_LOG_LEVEL", Value: "INFO"}}},
+		},
+	}
+	policy := &nsmv1alpha1.NSMInjectionPolicy{Spec: nsmv1alpha1.NSMInjectionPolicySpec{
+		NSCVersion: "v1.8.0",
+		Env:        map[string]string{"NSM_LOG_LEVEL": "DEBUG", "NSM_DIAL_TIMEOUT": "30s"},
+	}}
+	applyInjectionPolicy(policy, &spec)
+
+	want := corev1.PodSpec{
+		InitContainers: []corev1.Container{{
+			Name:  "cmd-nsc-init",
+			Image: "ghcr.io/networkservicemesh/cmd-nsc-init:v1.8.0",
+			Env:   []corev1.EnvVar{{Name: "NSM_DIAL_TIMEOUT", Value: "30s"}, {Name: "NSM_LOG_LEVEL", Value: "DEBUG"}},
+		}},
+		Containers: []corev1.Container{
+			{Name: "app", Image: "nginx:1.21", Env: []corev1.EnvVar{{Name: "NSM_LOG_LEVEL", Value: "INFO"}}},
+			{
+				Name:  "cmd-nsc",
+				Image: "ghcr.io/networkservicemesh/cmd-nsc:v1.8.0",
+				Env:   []corev1.EnvVar{{Name: "NSM_LOG_LEVEL", Value: "DEBUG"}, {Name: "NSM_DIAL_TIMEOUT", Value: "30s"}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("applyInjectionPolicy() = %+v, want %+v", spec, want)
+	}
+	if reasons := checkInjectionPolicy(policy, "kernel://my-service/nsm-1", spec); len(reasons) > 0 {
+		t.Errorf("pod doesn't follow the applied policy: %v", reasons)
+	}
+}
@@ -0,0 +1,337 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// Service of the operator validating the pods against the injection policies
+	policyWebhookServiceName string = "nsm-operator-webhook"
+	// Secret holding the serving certificate of the operator
+	policyWebhookCertsSecretName string = "nsm-operator-webhook-certs"
+	policyWebhookPath            string = "/validate-injection"
+	policyMutationPath           string = "/mutate-injection"
+	policyWebhookPort            int32  = 9443
+)
+
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingwebhookconfigurations,verbs=get;list;watch;create;update;patch;delete;deletecollection
+
+// Register the operator as the validating webhook of the namespaces whose
+// policy restricts the network services, the pods requesting other ones are
+// rejected. The NSC version and the environment set by the policies are
+// applied by the operator right after admission-webhook-k8s injected the
+// clients, through the mutating webhook configuration.
+func (r *MutatingWebhookReconciler) reconcilePolicyWebhook(ctx context.Context, nsm *nsmv1alpha1.NSM, caBundle []byte, policies []nsmv1alpha1.NSMInjectionPolicy) error {
+
+	namespaces := getRestrictedNamespaces(policies)
+	if len(namespaces) > 0 || len(getCustomizedNamespaces(policies)) > 0 {
+		if err := r.reconcilePolicyWebhookService(ctx, nsm); err != nil {
+			return err
+		}
+	}
+	if len(namespaces) == 0 {
+		return r.Client.DeleteAllOf(ctx, &admissionregistrationv1.ValidatingWebhookConfiguration{},
+			client.MatchingLabels{ownerLabel: getOwnerLabels(nsm)[ownerLabel]})
+	}
+
+	desired := validatingWebhookConfigurationForNSM(nsm, caBundle, namespaces)
+	vwc := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: desired.Name}, vwc)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		if err := r.Client.Create(ctx, desired); err != nil {
+			r.Log.Error(err, "failed to create validating webhook configuration "+desired.Name)
+			return err
+		}
+		r.Log.Info("validating webhook configuration " + desired.Name + " created")
+		return nil
+	}
+	// The whole webhooks are compared, the namespaces
+	// shrink when policies are removed
+	if !equality.Semantic.DeepEqual(desired.Webhooks, vwc.Webhooks) {
+		vwc.Webhooks = desired.Webhooks
+		if err := r.Client.Update(ctx, vwc); err != nil {
+			r.Log.Error(err, "failed to update validating webhook configuration "+desired.Name)
+			return err
+		}
+		r.Log.Info("validating webhook configuration " + desired.Name + " updated")
+	}
+	return nil
+}
+
+// The operator serves the policy webhooks behind its own service
+func (r *MutatingWebhookReconciler) reconcilePolicyWebhookService(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	svc := &corev1.Service{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: policyWebhookServiceName, Namespace: nsm.ObjectMeta.Namespace}, svc)
+	if err == nil || !apierrors.IsNotFound(err) {
+		return err
+	}
+	svc = serviceForPolicyWebhook(nsm)
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, svc, r.Scheme)
+	if err := r.Client.Create(ctx, svc); err != nil {
+		r.Log.Error(err, "failed to create service "+policyWebhookServiceName)
+		return err
+	}
+	r.Log.Info("service " + policyWebhookServiceName + " created")
+	return nil
+}
+
+// Get the namespaces whose policy restricts the network services of the clients
+func getRestrictedNamespaces(policies []nsmv1alpha1.NSMInjectionPolicy) []string {
+	restricted := map[string]string{}
+	for _, policy := range policies {
+		if policy.Spec.Injection != nsmv1alpha1.InjectionDeny && len(policy.Spec.NetworkServices) > 0 {
+			restricted[policy.Namespace] = policy.Namespace
+		}
+	}
+	return getSortedKeys(restricted)
+}
+
+// Get the namespaces whose policy sets the NSC version or the environment of the clients
+func getCustomizedNamespaces(policies []nsmv1alpha1.NSMInjectionPolicy) []string {
+	customized := map[string]string{}
+	for _, policy := range policies {
+		if policy.Spec.Injection != nsmv1alpha1.InjectionDeny && (policy.Spec.NSCVersion != "" || len(policy.Spec.Env) > 0) {
+			customized[policy.Namespace] = policy.Namespace
+		}
+	}
+	return getSortedKeys(customized)
+}
+
+func serviceForPolicyWebhook(nsm *nsmv1alpha1.NSM) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: newObjectMeta(policyWebhookServiceName, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"}),
+		Spec: corev1.ServiceSpec{
+			Selector: operatorLabels,
+			Ports: []corev1.ServicePort{{
+				Name:       "https",
+				Protocol:   corev1.ProtocolTCP,
+				Port:       443,
+				TargetPort: intstr.FromInt(int(policyWebhookPort)),
+			}},
+		},
+	}
+}
+
+// The configuration is cluster scoped, it's labeled with the
+// NSM CR and removed by its finalizer like the mutating one
+func validatingWebhookConfigurationForNSM(nsm *nsmv1alpha1.NSM, caBundle []byte, namespaces []string) *admissionregistrationv1.ValidatingWebhookConfiguration {
+
+	failurePolicy := admissionregistrationv1.Ignore
+	if nsm.Spec.Webhook.FailurePolicy != nil {
+		failurePolicy = *nsm.Spec.Webhook.FailurePolicy
+	}
+	path := policyWebhookPath
+	port := int32(443)
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	matchPolicy := admissionregistrationv1.Equivalent
+	scope := admissionregistrationv1.NamespacedScope
+	timeoutSeconds := int32(10)
+
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: newObjectMeta(nsm.ObjectMeta.Namespace+"-injection-policy", "", getOwnerLabels(nsm)),
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name: "injection-policy." + nsm.ObjectMeta.Namespace + ".networkservicemesh.io",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{
+					Name:      policyWebhookServiceName,
+					Namespace: nsm.ObjectMeta.Namespace,
+					Path:      &path,
+					Port:      &port,
+				},
+				CABundle: caBundle,
+			},
+			// The pods are checked once admission-webhook-k8s mutated them
+			Rules: []admissionregistrationv1.RuleWithOperations{{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{""},
+					APIVersions: []string{"v1"},
+					Resources:   []string{"pods"},
+					Scope:       &scope,
+				},
+			}},
+			FailurePolicy: &failurePolicy,
+			MatchPolicy:   &matchPolicy,
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      "kubernetes.io/metadata.name",
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   namespaces,
+				}},
+			},
+			ObjectSelector:          &metav1.LabelSelector{},
+			SideEffects:             &sideEffects,
+			TimeoutSeconds:          &timeoutSeconds,
+			AdmissionReviewVersions: []string{"v1"},
+		}},
+	}
+}
+
+// InjectionPolicyWebhook applies the NSMInjectionPolicy of their namespace
+// to the NSM clients injected into the pods, and rejects the pods requesting
+// network services it doesn't allow. It's served by every replica of the
+// operator, leader or not.
+type InjectionPolicyWebhook struct {
+	// Cached client for the NSM instances and the serving certificate
+	Client client.Client
+	// Uncached reader for the policies of the client namespaces
+	APIReader client.Reader
+	// Namespace of the NSM instances
+	Namespace string
+	Log       logr.Logger
+}
+
+func (w *InjectionPolicyWebhook) NeedLeaderElection() bool {
+	return false
+}
+
+func (w *InjectionPolicyWebhook) Start(ctx context.Context) error {
+
+	mux := http.NewServeMux()
+	for path, handler := range map[string]admission.Handler{
+		policyWebhookPath:  w,
+		policyMutationPath: admission.HandlerFunc(w.Mutate),
+	} {
+		hook := &admission.Webhook{Handler: handler}
+		if err := hook.InjectLogger(w.Log); err != nil {
+			return err
+		}
+		mux.Handle(path, hook)
+	}
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", policyWebhookPort),
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: w.getCertificate,
+		},
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServeTLS("", "")
+	}()
+	select {
+	case <-ctx.Done():
+		return server.Shutdown(context.Background())
+	case err := <-errs:
+		return err
+	}
+}
+
+// The serving certificate is issued with the one of admission-webhook-k8s
+// and read for each connection, so its rotations are served right away
+func (w *InjectionPolicyWebhook) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	secret := &corev1.Secret{}
+	err := w.Client.Get(context.Background(), types.NamespacedName{Name: policyWebhookCertsSecretName, Namespace: w.Namespace}, secret)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+func (w *InjectionPolicyWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+
+	pod := &corev1.Pod{}
+	if err := json.Unmarshal(req.Object.Raw, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	annotation, policies, err := w.getPodPolicies(ctx, pod, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	for i := range policies {
+		if reasons := checkRequestedNetworkServices(&policies[i], annotation); len(reasons) > 0 {
+			return admission.Denied("NSMInjectionPolicy " + policies[i].Name + ": " + strings.Join(reasons, "; "))
+		}
+	}
+	return admission.Allowed("")
+}
+
+// Mutate applies the NSC version and the environment set by the policies
+// of the namespace to the clients admission-webhook-k8s injected, the
+// webhook is called right after it
+func (w *InjectionPolicyWebhook) Mutate(ctx context.Context, req admission.Request) admission.Response {
+
+	pod := &corev1.Pod{}
+	if err := json.Unmarshal(req.Object.Raw, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	_, policies, err := w.getPodPolicies(ctx, pod, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(policies) == 0 {
+		return admission.Allowed("")
+	}
+	for i := range policies {
+		applyInjectionPolicy(&policies[i], &pod.Spec)
+	}
+	mutated, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, mutated)
+}
+
+// Get the injection annotation of a pod and the policies that apply to
+// it, none when the pod isn't annotated or its namespace denies injection
+func (w *InjectionPolicyWebhook) getPodPolicies(ctx context.Context, pod *corev1.Pod, namespace string) (string, []nsmv1alpha1.NSMInjectionPolicy, error) {
+
+	nsms := &nsmv1alpha1.NSMList{}
+	if err := w.Client.List(ctx, nsms, client.InNamespace(w.Namespace)); err != nil {
+		return "", nil, err
+	}
+	annotated := false
+	annotation := ""
+	for i := range nsms.Items {
+		if value, ok := pod.Annotations[getWebhookAnnotation(&nsms.Items[i])]; ok {
+			annotated = true
+			annotation = value
+			break
+		}
+	}
+	if !annotated {
+		return "", nil, nil
+	}
+
+	policies := &nsmv1alpha1.NSMInjectionPolicyList{}
+	if err := w.APIReader.List(ctx, policies, client.InNamespace(namespace)); err != nil {
+		return "", nil, err
+	}
+	applied := []nsmv1alpha1.NSMInjectionPolicy{}
+	for _, policy := range policies.Items {
+		// Clients aren't injected in the denied namespaces
+		if policy.Spec.Injection != nsmv1alpha1.InjectionDeny {
+			applied = append(applied, policy)
+		}
+	}
+	return annotation, applied, nil
+}
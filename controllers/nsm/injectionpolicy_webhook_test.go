@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestInjectionPolicyWebhook(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+	policy := &nsmv1alpha1.NSMInjectionPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "apps"},
+		Spec: nsmv1alpha1.NSMInjectionPolicySpec{
+			NetworkServices: []string{"allowed"},
+			NSCVersion:      "v1.8.0",
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(nsm, policy).Build()
+	w := &InjectionPolicyWebhook{Client: c, APIReader: c, Namespace: "nsm"}
+
+	tests := []struct {
+		name       string
+		annotation string
+		image      string
+		allowed    bool
+	}{
+		{"not annotated", "", "cmd-nsc:v1.7.0", true},
+		{"following the policy", "kernel://allowed/nsm-1", "ghcr.io/networkservicemesh/cmd-nsc:v1.8.0", true},
+		{"other network service", "kernel://other/nsm-1", "ghcr.io/networkservicemesh/cmd-nsc:v1.8.0", false},
+		{"other NSC version", "kernel://allowed/nsm-1", "ghcr.io/networkservicemesh/cmd-nsc:v1.7.0", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "client", Namespace: "apps"},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "cmd-nsc", Image: test.image}}},
+			}
+			if test.annotation != "" {
+				pod.Annotations = map[string]string{"networkservicemesh.io": test.annotation}
+			}
+			raw, _ := json.Marshal(pod)
+			response := w.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Namespace: "apps",
+				Object:    runtime.RawExtension{Raw: raw},
+			}})
+			if response.Allowed != test.allowed {
+				t.Errorf("allowed = %v, want %v (%v)", response.Allowed, test.allowed, response.Result)
+			}
+		})
+	}
+}
+
+func TestInjectionPolicyMutation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+	policies := []client.Object{
+		&nsmv1alpha1.NSMInjectionPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "apps"},
+			Spec: nsmv1alpha1.NSMInjectionPolicySpec{
+				NSCVersion: "v1.8.0",
+				Env:        map[string]string{"NSM_LOG_LEVEL": "DEBUG"},
+			},
+		},
+		&nsmv1alpha1.NSMInjectionPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: "denied"},
+			Spec: nsmv1alpha1.NSMInjectionPolicySpec{
+				Injection:  nsmv1alpha1.InjectionDeny,
+				NSCVersion: "v1.8.0",
+			},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(policies, nsm)...).Build()
+	w := &InjectionPolicyWebhook{Client: c, APIReader: c, Namespace: "nsm"}
+
+	tests := []struct {
+		name      string
+		namespace string
+		annotated bool
+		wantImage string
+		wantEnv   []corev1.EnvVar
+	}{
+		{
+			name:      "policy applied",
+			namespace: "apps",
+			annotated: true,
+			wantImage: "ghcr.io/networkservicemesh/cmd-nsc:v1.8.0",
+			wantEnv:   []corev1.EnvVar{{Name: "NSM_LOG_LEVEL", Value: "DEBUG"}, {Name: "NSM_NETWORK_SERVICES", Value: "kernel://allowed/nsm-1"}},
+		},
+		{
+			name:      "not annotated",
+			namespace: "apps",
+			wantImage: "ghcr.io/networkservicemesh/cmd-nsc:v1.7.0",
+			wantEnv:   []corev1.EnvVar{{Name: "NSM_LOG_LEVEL", Value: "INFO"}, {Name: "NSM_NETWORK_SERVICES", Value: "kernel://allowed/nsm-1"}},
+		},
+		{
+			name:      "injection denied",
+			namespace: "denied",
+			annotated: true,
+			wantImage: "ghcr.io/networkservicemesh/cmd-nsc:v1.7.0",
+			wantEnv:   []corev1.EnvVar{{Name: "NSM_LOG_LEVEL", Value: "INFO"}, {Name: "NSM_NETWORK_SERVICES", Value: "kernel://allowed/nsm-1"}},
+		},
+		{
+			name:      "no policy",
+			namespace: "other",
+			annotated: true,
+			wantImage: "ghcr.io/networkservicemesh/cmd-nsc:v1.7.0",
+			wantEnv:   []corev1.EnvVar{{Name: "NSM_LOG_LEVEL", Value: "INFO"}, {Name: "NSM_NETWORK_SERVICES", Value: "kernel://allowed/nsm-1"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "client", Namespace: test.namespace},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name:  "cmd-nsc",
+					Image: "ghcr.io/networkservicemesh/cmd-nsc:v1.7.0",
+					Env:   []corev1.EnvVar{{Name: "NSM_LOG_LEVEL", Value: "INFO"}, {Name: "NSM_NETWORK_SERVICES", Value: "kernel://allowed/nsm-1"}},
+				}}},
+			}
+			if test.annotated {
+				pod.Annotations = map[string]string{"networkservicemesh.io": "kernel://allowed/nsm-1"}
+			}
+			raw, _ := json.Marshal(pod)
+			response := w.Mutate(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Namespace: test.namespace,
+				Object:    runtime.RawExtension{Raw: raw},
+			}})
+			if !response.Allowed {
+				t.Fatalf("pod rejected: %v", response.Result)
+			}
+			patch, _ := json.Marshal(response.Patches)
+			if len(response.Patches) > 0 {
+				decoded, err := jsonpatch.DecodePatch(patch)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if raw, err = decoded.Apply(raw); err != nil {
+					t.Fatal(err)
+				}
+			}
+			mutated := &corev1.Pod{}
+			if err := json.Unmarshal(raw, mutated); err != nil {
+				t.Fatal(err)
+			}
+			if image := mutated.Spec.Containers[0].Image; image != test.wantImage {
+				t.Errorf("image = %s, want %s", image, test.wantImage)
+			}
+			if env := mutated.Spec.Containers[0].Env; !reflect.DeepEqual(env, test.wantEnv) {
+				t.Errorf("env = %v, want %v", env, test.wantEnv)
+			}
+		})
+	}
+}
+
+func TestPolicyWebhookConfigurations(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	nsm.Spec.Webhook.Image = "cmd-admission-webhook-k8s:v1.7.0"
+	policy := func(namespace string, spec nsmv1alpha1.NSMInjectionPolicySpec) *nsmv1alpha1.NSMInjectionPolicy {
+		return &nsmv1alpha1.NSMInjectionPolicy{ObjectMeta: metav1.ObjectMeta{Name: "policy", Namespace: namespace}, Spec: spec}
+	}
+	restricted := nsmv1alpha1.NSMInjectionPolicySpec{NetworkServices: []string{"allowed"}}
+	customized := nsmv1alpha1.NSMInjectionPolicySpec{NSCVersion: "v1.8.0"}
+	denied := nsmv1alpha1.NSMInjectionPolicySpec{Injection: nsmv1alpha1.InjectionDeny}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	r := NewMutatingWebhookReconciler(c, logr.Discard(), scheme, c, false)
+
+	// Each step removes policies of the previous one
+	tests := []struct {
+		name           string
+		policies       []*nsmv1alpha1.NSMInjectionPolicy
+		wantDenied     []string
+		wantCustomized []string
+		wantRestricted []string
+		hubDriven      bool
+	}{
+		{
+			name: "policies created",
+			policies: []*nsmv1alpha1.NSMInjectionPolicy{
+				policy("a", restricted), policy("b", restricted), policy("c", customized), policy("d", customized),
+				policy("x", denied), policy("y", denied),
+			},
+			wantDenied:     []string{"kube-system", "nsm", "x", "y"},
+			wantCustomized: []string{"c", "d"},
+			wantRestricted: []string{"a", "b"},
+		},
+		{
+			name: "policies removed",
+			policies: []*nsmv1alpha1.NSMInjectionPolicy{
+				policy("a", restricted), policy("c", customized), policy("x", denied),
+			},
+			wantDenied:     []string{"kube-system", "nsm", "x"},
+			wantCustomized: []string{"c"},
+			wantRestricted: []string{"a"},
+		},
+		{
+			// No operator serves the policy webhooks in the member clusters
+			name: "reconciled from a fleet hub",
+			policies: []*nsmv1alpha1.NSMInjectionPolicy{
+				policy("a", restricted), policy("c", customized), policy("x", denied),
+			},
+			wantDenied: []string{"kube-system", "nsm", "x"},
+			hubDriven:  true,
+		},
+		{
+			name:       "all policies removed",
+			wantDenied: []string{"kube-system", "nsm"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := c.DeleteAllOf(context.TODO(), &nsmv1alpha1.NSMInjectionPolicy{}, client.InNamespace("")); err != nil {
+				t.Fatal(err)
+			}
+			for _, policy := range test.policies {
+				if err := c.Create(context.TODO(), policy.DeepCopy()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			r.HubDriven = test.hubDriven
+			if err := r.Reconcile(context.TODO(), nsm); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			mwc := &admissionregistrationv1.MutatingWebhookConfiguration{}
+			if err := c.Get(context.TODO(), types.NamespacedName{Name: "nsm-admission-webhook-k8s"}, mwc); err != nil {
+				t.Fatal(err)
+			}
+			if got := mwc.Webhooks[0].NamespaceSelector.MatchExpressions[0].Values; !reflect.DeepEqual(got, test.wantDenied) {
+				t.Errorf("namespaces left out of injection = %v, want %v", got, test.wantDenied)
+			}
+			customized := []string{}
+			if len(mwc.Webhooks) > 1 {
+				customized = mwc.Webhooks[1].NamespaceSelector.MatchExpressions[0].Values
+			}
+			if len(customized) != len(test.wantCustomized) || (len(customized) > 0 && !reflect.DeepEqual(customized, test.wantCustomized)) {
+				t.Errorf("namespaces with customized clients = %v, want %v", customized, test.wantCustomized)
+			}
+
+			vwc := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+			err := c.Get(context.TODO(), types.NamespacedName{Name: "nsm-injection-policy"}, vwc)
+			if len(test.wantRestricted) == 0 {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("validating webhook configuration kept without restricted namespaces: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := vwc.Webhooks[0].NamespaceSelector.MatchExpressions[0].Values; !reflect.DeepEqual(got, test.wantRestricted) {
+				t.Errorf("namespaces with restricted network services = %v, want %v", got, test.wantRestricted)
+			}
+		})
+	}
+}
+
+func TestGetPolicyNamespaces(t *testing.T) {
+	policies := []nsmv1alpha1.NSMInjectionPolicy{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "b"}, Spec: nsmv1alpha1.NSMInjectionPolicySpec{Env: map[string]string{"NSM_LOG_LEVEL": "DEBUG"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "a"}, Spec: nsmv1alpha1.NSMInjectionPolicySpec{NSCVersion: "v1.8.0", NetworkServices: []string{"allowed"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "c"}, Spec: nsmv1alpha1.NSMInjectionPolicySpec{NetworkServices: []string{"allowed"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "denied"}, Spec: nsmv1alpha1.NSMInjectionPolicySpec{Injection: nsmv1alpha1.InjectionDeny, NSCVersion: "v1.8.0", NetworkServices: []string{"allowed"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "open"}, Spec: nsmv1alpha1.NSMInjectionPolicySpec{Injection: nsmv1alpha1.InjectionAllow}},
+	}
+	if namespaces := getCustomizedNamespaces(policies); !reflect.DeepEqual(namespaces, []string{"a", "b"}) {
+		t.Errorf("customized namespaces = %v, want [a b]", namespaces)
+	}
+	if namespaces := getRestrictedNamespaces(policies); !reflect.DeepEqual(namespaces, []string{"a", "c"}) {
+		t.Errorf("restricted namespaces = %v, want [a c]", namespaces)
+	}
+	if namespaces := getDeniedNamespaces(policies); !reflect.DeepEqual(namespaces, []string{"denied"}) {
+		t.Errorf("denied namespaces = %v, want [denied]", namespaces)
+	}
+}
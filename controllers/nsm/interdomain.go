@@ -0,0 +1,333 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Names and ports of the interdomain components, the registry forwards
+// the requests for the other domains to nsmgr-proxy
+const (
+	nsmgrProxyName       string = "nsmgr-proxy"
+	nsmgrProxyPort       int32  = 5004
+	registryProxyDNSName string = "registry-proxy-dns"
+	registryProxyDNSPort int32  = 5005
+	mapIPFilePath        string = "/etc/mapip/map-ip.yaml"
+)
+
+// Annotation publishing a service under a DNS name through external-dns
+const externalDNSHostnameAnnotation string = "external-dns.alpha.kubernetes.io/hostname"
+
+// InterdomainReconciler deploys nsmgr-proxy and registry-proxy-dns
+// connecting the NSM instance with the ones of other domains
+type InterdomainReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func NewInterdomainReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme) *InterdomainReconciler {
+	return &InterdomainReconciler{
+		Client: client,
+		Log:    log,
+		Scheme: scheme,
+	}
+}
+
+func (r *InterdomainReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	// The components stay exposed through their load balancers
+	// until they are removed along with the section
+	if !nsm.Spec.Interdomain.Enabled {
+		return deleteComponentObjects(ctx, r.Client, r.Log, nsm,
+			&appsv1.Deployment{ObjectMeta: newObjectMeta(nsmgrProxyName, nsm.ObjectMeta.Namespace, nil)},
+			&appsv1.Deployment{ObjectMeta: newObjectMeta(registryProxyDNSName, nsm.ObjectMeta.Namespace, nil)},
+			&corev1.Service{ObjectMeta: newObjectMeta(nsmgrProxyName, nsm.ObjectMeta.Namespace, nil)},
+			&corev1.Service{ObjectMeta: newObjectMeta(registryProxyDNSName, nsm.ObjectMeta.Namespace, nil)})
+	}
+
+	// The floating registry hosted next to the NSM instance is
+	// resolved by the local registry-proxy-dns to its cluster IP
+	hostAliases, err := getFloatingRegistryHostAliases(ctx, r.Client, nsm)
+	if err != nil {
+		return err
+	}
+
+	for _, deploy := range []*appsv1.Deployment{
+		r.deploymentForNsmgrProxy(nsm),
+		r.deploymentForRegistryProxyDNS(nsm, hostAliases),
+	} {
+		if _, err := reconcileComponentDeployment(ctx, r.Client, r.Log, deploy); err != nil {
+			return err
+		}
+	}
+	for _, svc := range []*corev1.Service{
+		r.serviceForNsmgrProxy(nsm),
+		r.serviceForRegistryProxyDNS(nsm),
+	} {
+		if err := reconcileExposedService(ctx, r.Client, r.Log, svc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create the service or update the way it's exposed, the cluster
+// IP and the node ports are allocated by the API server
+func reconcileExposedService(ctx context.Context, c client.Client, log logr.Logger, desired *corev1.Service) error {
+
+	svc := &corev1.Service{}
+	err := c.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, svc)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		err = c.Create(ctx, desired)
+		if err != nil {
+			log.Error(err, "failed to create service for "+desired.Name)
+			return err
+		}
+		log.Info(desired.Name + " service created")
+		return nil
+	}
+	if !isControlledAs(svc, desired) {
+		return &notControlledError{kind: "service", name: desired.Name}
+	}
+	if svc.Spec.Type != desired.Spec.Type || svc.Spec.LoadBalancerIP != desired.Spec.LoadBalancerIP ||
+		svc.Annotations[externalDNSHostnameAnnotation] != desired.Annotations[externalDNSHostnameAnnotation] {
+		svc.Spec.Type = desired.Spec.Type
+		svc.Spec.LoadBalancerIP = desired.Spec.LoadBalancerIP
+		svc.Annotations = setExternalDNSHostname(svc.Annotations, desired.Annotations[externalDNSHostnameAnnotation])
+		err = c.Update(ctx, svc)
+		if err != nil {
+			log.Error(err, "failed to update service of "+desired.Name)
+			return err
+		}
+		log.Info(desired.Name + " service updated")
+	}
+	return nil
+}
+
+func (r *InterdomainReconciler) deploymentForNsmgrProxy(nsm *nsmv1alpha1.NSM) *appsv1.Deployment {
+
+	labels := map[string]string{"app": nsmgrProxyName, "spiffe.io/spiffe-id": "true"}
+	replicas := int32(1)
+
+	envVars := mergeEnvVars([]corev1.EnvVar{
+		{Name: "NSM_LISTEN_ON", Value: fmt.Sprintf("tcp://:%d", nsmgrProxyPort)},
+		{Name: "NSM_NAME", ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "metadata.name",
+			}}},
+		{Name: "NSM_REGISTRY_URL", Value: "nsm-registry-svc:5002"},
+		{Name: "NSM_REGISTRY_PROXY_URL", Value: fmt.Sprintf("%s:%d", registryProxyDNSName, registryProxyDNSPort)},
+		{Name: "NSM_MAP_IP_FILE_PATH", Value: mapIPFilePath},
+		{Name: "NSM_LOG_LEVEL", Value: getNsmLogLevel(nsm)},
+	}, nsm.Spec.Interdomain.NsmgrProxy.EnvVars)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: newObjectMeta(nsmgrProxyName, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"}),
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: getFederationAnnotations(nsm),
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: nsmgrProxyServiceAccountName,
+					Containers: []corev1.Container{
+						{
+							Name:            nsmgrProxyName,
+							Image:           nsm.Spec.Interdomain.NsmgrProxy.Image,
+							ImagePullPolicy: nsm.Spec.NsmPullPolicy,
+							SecurityContext: getUnprivilegedSecurityContext(),
+							Env:             insertSpireAgentSocketEnv(envVars, getSpireAgentSocket(nsm)),
+							Ports: []corev1.ContainerPort{{
+								ContainerPort: nsmgrProxyPort}},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "spire-agent-socket",
+									MountPath: getSpireAgentSocketDir(nsm),
+									ReadOnly:  true},
+								{Name: "map-ip",
+									MountPath: "/etc/mapip",
+									ReadOnly:  true},
+							},
+						},
+						// map-ip-k8s maps the internal node addresses
+						// to the external ones advertised to the other domains
+						{
+							Name:            "map-ip-k8s",
+							Image:           nsm.Spec.Interdomain.MapIPImage,
+							ImagePullPolicy: nsm.Spec.NsmPullPolicy,
+							SecurityContext: getUnprivilegedSecurityContext(),
+							Env: []corev1.EnvVar{
+								{Name: "NSM_OUTPUT_PATH", Value: mapIPFilePath},
+								{Name: "NSM_LOG_LEVEL", Value: getNsmLogLevel(nsm)},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "map-ip",
+									MountPath: "/etc/mapip"},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						getSpireAgentSocketVolume(nsm),
+						{Name: "map-ip",
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{},
+							}},
+					},
+				},
+			},
+		},
+	}
+	setConfigHash(&deploy.Spec.Template)
+
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, deploy, r.Scheme)
+	return deploy
+}
+
+func (r *InterdomainReconciler) deploymentForRegistryProxyDNS(nsm *nsmv1alpha1.NSM, hostAliases []corev1.HostAlias) *appsv1.Deployment {
+
+	labels := map[string]string{"app": registryProxyDNSName, "spiffe.io/spiffe-id": "true"}
+	replicas := int32(1)
+
+	envVars := mergeEnvVars([]corev1.EnvVar{
+		{Name: "NSM_LISTEN_ON", Value: fmt.Sprintf("tcp://:%d", registryProxyDNSPort)},
+		{Name: "NSM_PROXY_NSMGR_URL", Value: fmt.Sprintf("%s:%d", nsmgrProxyName, nsmgrProxyPort)},
+		{Name: "NSM_LOG_LEVEL", Value: getNsmLogLevel(nsm)},
+	}, nsm.Spec.Interdomain.RegistryProxyDNS.EnvVars)
+
+	deploy := &appsv1.Deployment{
+		ObjectMeta: newObjectMeta(registryProxyDNSName, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"}),
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: getFederationAnnotations(nsm),
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: registryProxyDNSServiceAccountName,
+					HostAliases:        hostAliases,
+					// The cluster DNS settings are extended, not replaced
+					DNSConfig: nsm.Spec.Interdomain.DNSConfig,
+					Containers: []corev1.Container{{
+						Name:            registryProxyDNSName,
+						Image:           nsm.Spec.Interdomain.RegistryProxyDNS.Image,
+						ImagePullPolicy: nsm.Spec.NsmPullPolicy,
+						SecurityContext: getUnprivilegedSecurityContext(),
+						Env:             insertSpireAgentSocketEnv(envVars, getSpireAgentSocket(nsm)),
+						Ports: []corev1.ContainerPort{{
+							ContainerPort: registryProxyDNSPort}},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "spire-agent-socket",
+								MountPath: getSpireAgentSocketDir(nsm),
+								ReadOnly:  true},
+						},
+					}},
+					Volumes: []corev1.Volume{
+						getSpireAgentSocketVolume(nsm),
+					},
+				},
+			},
+		},
+	}
+	setConfigHash(&deploy.Spec.Template)
+
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, deploy, r.Scheme)
+	return deploy
+}
+
+// nsmgr-proxy is reached by the nsmgr-proxies of the other domains
+func (r *InterdomainReconciler) serviceForNsmgrProxy(nsm *nsmv1alpha1.NSM) *corev1.Service {
+
+	serviceType := nsm.Spec.Interdomain.ServiceType
+	if serviceType == "" {
+		serviceType = corev1.ServiceTypeLoadBalancer
+	}
+	svc := &corev1.Service{
+		ObjectMeta: newObjectMeta(nsmgrProxyName, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"}),
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": nsmgrProxyName},
+			Ports: []corev1.ServicePort{{
+				Name:       nsmgrProxyName,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       nsmgrProxyPort,
+				TargetPort: intstr.FromInt(int(nsmgrProxyPort)),
+			}},
+			Type: serviceType,
+		},
+	}
+	if serviceType == corev1.ServiceTypeLoadBalancer {
+		svc.Spec.LoadBalancerIP = nsm.Spec.Interdomain.LoadBalancerIP
+	}
+	svc.Annotations = setExternalDNSHostname(nil, getInterdomainHostname(nsm, nsmgrProxyName))
+
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, svc, r.Scheme)
+	return svc
+}
+
+// registry-proxy-dns is only reached by the local nsmgr-proxy
+func (r *InterdomainReconciler) serviceForRegistryProxyDNS(nsm *nsmv1alpha1.NSM) *corev1.Service {
+
+	svc := &corev1.Service{
+		ObjectMeta: newObjectMeta(registryProxyDNSName, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"}),
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": registryProxyDNSName},
+			Ports: []corev1.ServicePort{{
+				Name:       registryProxyDNSName,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       registryProxyDNSPort,
+				TargetPort: intstr.FromInt(int(registryProxyDNSPort)),
+			}},
+			Type: corev1.ServiceTypeClusterIP,
+		},
+	}
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, svc, r.Scheme)
+	return svc
+}
+
+// Get the DNS name an interdomain service is published under,
+// empty unless the domain of the NSM instance is set
+func getInterdomainHostname(nsm *nsmv1alpha1.NSM, name string) string {
+	if !nsm.Spec.Interdomain.Enabled || nsm.Spec.Interdomain.Domain == "" {
+		return ""
+	}
+	return name + "." + nsm.Spec.Interdomain.Domain
+}
+
+func setExternalDNSHostname(annotations map[string]string, hostname string) map[string]string {
+	if hostname == "" {
+		delete(annotations, externalDNSHostnameAnnotation)
+		return annotations
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[externalDNSHostnameAnnotation] = hostname
+	return annotations
+}
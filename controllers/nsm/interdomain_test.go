@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestInterdomainDisabled(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	owned := func(obj client.Object) client.Object {
+		_ = controllerutil.SetControllerReference(nsm, obj, scheme)
+		return obj
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		owned(&appsv1.Deployment{ObjectMeta: newObjectMeta(nsmgrProxyName, "nsm", nil)}),
+		owned(&corev1.Service{ObjectMeta: newObjectMeta(nsmgrProxyName, "nsm", nil)}),
+		owned(&appsv1.Deployment{ObjectMeta: newObjectMeta(floatingRegistryName, "nsm", nil)}),
+		owned(&corev1.Service{ObjectMeta: newObjectMeta(floatingRegistryName, "nsm", nil)}),
+		// Left to its own owner
+		&appsv1.Deployment{ObjectMeta: newObjectMeta(registryProxyDNSName, "nsm", nil)},
+	).Build()
+
+	if err := NewFloatingRegistryReconciler(c, logr.Discard(), scheme).Reconcile(context.TODO(), nsm); err != nil {
+		t.Fatalf("floating registry Reconcile() error = %v", err)
+	}
+	if err := NewInterdomainReconciler(c, logr.Discard(), scheme).Reconcile(context.TODO(), nsm); err != nil {
+		t.Fatalf("interdomain Reconcile() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		obj     client.Object
+		deleted bool
+	}{
+		{nsmgrProxyName, &appsv1.Deployment{}, true},
+		{nsmgrProxyName, &corev1.Service{}, true},
+		{floatingRegistryName, &appsv1.Deployment{}, true},
+		{floatingRegistryName, &corev1.Service{}, true},
+		{registryProxyDNSName, &appsv1.Deployment{}, false},
+	}
+	for _, tt := range tests {
+		err := c.Get(context.TODO(), types.NamespacedName{Name: tt.name, Namespace: "nsm"}, tt.obj)
+		if got := apierrors.IsNotFound(err); got != tt.deleted {
+			t.Errorf("%T %s deleted = %v, want %v (error %v)", tt.obj, tt.name, got, tt.deleted, err)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	webhookServiceName string = "admission-webhook-svc"
+	webhookCertsDir    string = "/etc/admission-webhook/certs"
+)
+
+// MutatingWebhookReconciler registers admission-webhook-k8s with the API server
+// in place of the webhook itself, so the MutatingWebhookConfiguration follows
+// the NSM CR and is removed together with the webhook
+type MutatingWebhookReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Uncached reader for the injection policies of the client namespaces
+	APIReader client.Reader
+	// The NSM instance is reconciled from a fleet hub, the policy
+	// webhooks served by the operator are left out
+	HubDriven bool
+}
+
+func NewMutatingWebhookReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme, apiReader client.Reader, hubDriven bool) *MutatingWebhookReconciler {
+	return &MutatingWebhookReconciler{
+		Client:    client,
+		Log:       log,
+		Scheme:    scheme,
+		APIReader: apiReader,
+		HubDriven: hubDriven,
+	}
+}
+
+func (r *MutatingWebhookReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	// Pods can't be mutated without the webhook deployment, leaving the
+	// configuration behind would break their creation with failurePolicy Fail
+	if nsm.Spec.Webhook.Image == "" {
+		selector := client.MatchingLabels{ownerLabel: getOwnerLabels(nsm)[ownerLabel]}
+		if err := r.Client.DeleteAllOf(ctx, &admissionregistrationv1.ValidatingWebhookConfiguration{}, selector); err != nil {
+			return err
+		}
+		return r.Client.DeleteAllOf(ctx, &admissionregistrationv1.MutatingWebhookConfiguration{}, selector)
+	}
+
+	caBundle, err := r.reconcileCertificates(ctx, nsm)
+	if err != nil {
+		return err
+	}
+
+	policies, err := listInjectionPolicies(ctx, r.APIReader)
+	if err != nil {
+		return err
+	}
+
+	// No operator runs in the member clusters of a fleet reconciled from
+	// its hub, the denied namespaces are still left out of the injection
+	customizedNamespaces := []string{}
+	if r.HubDriven {
+		if err := r.Client.DeleteAllOf(ctx, &admissionregistrationv1.ValidatingWebhookConfiguration{},
+			client.MatchingLabels{ownerLabel: getOwnerLabels(nsm)[ownerLabel]}); err != nil {
+			return err
+		}
+	} else {
+		if err := r.reconcilePolicyWebhook(ctx, nsm, caBundle, policies); err != nil {
+			return err
+		}
+		customizedNamespaces = getCustomizedNamespaces(policies)
+	}
+
+	desired := mutatingWebhookConfigurationForNSM(nsm, caBundle, getDeniedNamespaces(policies), customizedNamespaces)
+	mwc := &admissionregistrationv1.MutatingWebhookConfiguration{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: desired.Name}, mwc)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		err = r.Client.Create(ctx, desired)
+		if err != nil {
+			r.Log.Error(err, "failed to create mutating webhook configuration "+desired.Name)
+			return err
+		}
+		r.Log.Info("mutating webhook configuration " + desired.Name + " created")
+		return nil
+	}
+	// The whole webhooks are compared, the selectors shrink
+	// when settings or policies are removed
+	if !equality.Semantic.DeepEqual(desired.Webhooks, mwc.Webhooks) {
+		mwc.Webhooks = desired.Webhooks
+		err = r.Client.Update(ctx, mwc)
+		if err != nil {
+			r.Log.Error(err, "failed to update mutating webhook configuration "+desired.Name)
+			return err
+		}
+		r.Log.Info("mutating webhook configuration " + desired.Name + " updated")
+	}
+	return nil
+}
+
+// Get the selector of the namespaces the clients are injected in, the
+// system and NSM namespaces unless the CR says otherwise. The namespaces
+// denying injection are left out.
+func getClientNamespaceSelector(nsm *nsmv1alpha1.NSM, deniedNamespaces []string) *metav1.LabelSelector {
+	namespaceSelector := nsm.Spec.Webhook.NamespaceSelector.DeepCopy()
+	if namespaceSelector == nil {
+		return &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      "kubernetes.io/metadata.name",
+				Operator: metav1.LabelSelectorOpNotIn,
+				Values:   append([]string{"kube-system", nsm.ObjectMeta.Namespace}, deniedNamespaces...),
+			}},
+		}
+	}
+	if len(deniedNamespaces) > 0 {
+		namespaceSelector.MatchExpressions = append(namespaceSelector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      "kubernetes.io/metadata.name",
+			Operator: metav1.LabelSelectorOpNotIn,
+			Values:   deniedNamespaces,
+		})
+	}
+	return namespaceSelector
+}
+
+// The configuration is cluster scoped, it can't be owned by the
+// NSM CR so it's labeled with it and removed by its finalizer.
+// Namespaces denying injection through their policy are left out.
+// The pods of the namespaces whose policy sets the NSC version or the
+// environment of the clients are then passed to the operator, the
+// webhooks of a configuration are called in order.
+func mutatingWebhookConfigurationForNSM(nsm *nsmv1alpha1.NSM, caBundle []byte, deniedNamespaces []string, customizedNamespaces []string) *admissionregistrationv1.MutatingWebhookConfiguration {
+
+	failurePolicy := admissionregistrationv1.Ignore
+	if nsm.Spec.Webhook.FailurePolicy != nil {
+		failurePolicy = *nsm.Spec.Webhook.FailurePolicy
+	}
+	namespaceSelector := getClientNamespaceSelector(nsm, deniedNamespaces)
+	objectSelector := nsm.Spec.Webhook.ObjectSelector
+	if objectSelector == nil {
+		objectSelector = &metav1.LabelSelector{}
+	}
+	path := "/mutate"
+	port := int32(443)
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	matchPolicy := admissionregistrationv1.Equivalent
+	reinvocationPolicy := admissionregistrationv1.NeverReinvocationPolicy
+	scope := admissionregistrationv1.AllScopes
+	timeoutSeconds := int32(10)
+
+	mwc := &admissionregistrationv1.MutatingWebhookConfiguration{
+		ObjectMeta: newObjectMeta(nsm.ObjectMeta.Namespace+"-admission-webhook-k8s", "", getOwnerLabels(nsm)),
+		Webhooks: []admissionregistrationv1.MutatingWebhook{{
+			Name: "admission-webhook-k8s." + nsm.ObjectMeta.Namespace + ".networkservicemesh.io",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{
+					Name:      webhookServiceName,
+					Namespace: nsm.ObjectMeta.Namespace,
+					Path:      &path,
+					Port:      &port,
+				},
+				CABundle: caBundle,
+			},
+			Rules: []admissionregistrationv1.RuleWithOperations{{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{"", "apps", "batch"},
+					APIVersions: []string{"v1"},
+					Resources:   []string{"pods", "deployments", "statefulsets", "daemonsets", "replicasets", "jobs", "cronjobs"},
+					Scope:       &scope,
+				},
+			}},
+			FailurePolicy:           &failurePolicy,
+			MatchPolicy:             &matchPolicy,
+			NamespaceSelector:       namespaceSelector,
+			ObjectSelector:          objectSelector,
+			SideEffects:             &sideEffects,
+			TimeoutSeconds:          &timeoutSeconds,
+			AdmissionReviewVersions: []string{"v1"},
+			ReinvocationPolicy:      &reinvocationPolicy,
+		}},
+	}
+	if len(customizedNamespaces) == 0 {
+		return mwc
+	}
+
+	policyPath := policyMutationPath
+	podScope := admissionregistrationv1.NamespacedScope
+	mwc.Webhooks = append(mwc.Webhooks, admissionregistrationv1.MutatingWebhook{
+		Name: "injection-policy." + nsm.ObjectMeta.Namespace + ".networkservicemesh.io",
+		ClientConfig: admissionregistrationv1.WebhookClientConfig{
+			Service: &admissionregistrationv1.ServiceReference{
+				Name:      policyWebhookServiceName,
+				Namespace: nsm.ObjectMeta.Namespace,
+				Path:      &policyPath,
+				Port:      &port,
+			},
+			CABundle: caBundle,
+		},
+		// The clients are injected into the pod specs of the workloads
+		// too, the policy is applied to the pods created from them
+		Rules: []admissionregistrationv1.RuleWithOperations{{
+			Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create},
+			Rule: admissionregistrationv1.Rule{
+				APIGroups:   []string{""},
+				APIVersions: []string{"v1"},
+				Resources:   []string{"pods"},
+				Scope:       &podScope,
+			},
+		}},
+		FailurePolicy: &failurePolicy,
+		MatchPolicy:   &matchPolicy,
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      "kubernetes.io/metadata.name",
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   customizedNamespaces,
+			}},
+		},
+		ObjectSelector:          objectSelector,
+		SideEffects:             &sideEffects,
+		TimeoutSeconds:          &timeoutSeconds,
+		AdmissionReviewVersions: []string{"v1"},
+		ReinvocationPolicy:      &reinvocationPolicy,
+	})
+	return mwc
+}
+
+// Get the DNS names the webhook service is reached with
+func getWebhookDNSNames(nsm *nsmv1alpha1.NSM) []string {
+	return getServiceDNSNames(webhookServiceName, nsm.ObjectMeta.Namespace)
+}
+
+// Get the DNS names a service is reached with
+func getServiceDNSNames(name, namespace string) []string {
+	return []string{
+		name,
+		name + "." + namespace,
+		name + "." + namespace + ".svc",
+		name + "." + namespace + ".svc.cluster.local",
+	}
+}
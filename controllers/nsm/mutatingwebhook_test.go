@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestMutatingWebhookSelectors(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	nsm.Spec.Webhook.Image = "cmd-admission-webhook-k8s:v1.7.0"
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	r := NewMutatingWebhookReconciler(c, logr.Discard(), scheme, c, false)
+
+	defaultNamespaceSelector := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+		Key: "kubernetes.io/metadata.name", Operator: metav1.LabelSelectorOpNotIn, Values: []string{"kube-system", "nsm"},
+	}}}
+	exists := metav1.LabelSelectorRequirement{Key: "nsm", Operator: metav1.LabelSelectorOpExists}
+	team := func(values ...string) metav1.LabelSelectorRequirement {
+		return metav1.LabelSelectorRequirement{Key: "team", Operator: metav1.LabelSelectorOpIn, Values: values}
+	}
+	objectSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"nsm": "true"}}
+
+	// Each step only shrinks the selectors of the previous one
+	tests := []struct {
+		name              string
+		namespaceSelector *metav1.LabelSelector
+		objectSelector    *metav1.LabelSelector
+		wantNamespace     *metav1.LabelSelector
+		wantObject        *metav1.LabelSelector
+	}{
+		{
+			name:              "selectors set",
+			namespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{exists, team("a", "b")}},
+			objectSelector:    objectSelector,
+			wantNamespace:     &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{exists, team("a", "b")}},
+			wantObject:        objectSelector,
+		},
+		{
+			name:              "value removed",
+			namespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{exists, team("a")}},
+			objectSelector:    objectSelector,
+			wantNamespace:     &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{exists, team("a")}},
+			wantObject:        objectSelector,
+		},
+		{
+			name:              "match expression removed",
+			namespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{exists}},
+			objectSelector:    objectSelector,
+			wantNamespace:     &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{exists}},
+			wantObject:        objectSelector,
+		},
+		{
+			name:              "object selector cleared",
+			namespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{exists}},
+			wantNamespace:     &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{exists}},
+			wantObject:        &metav1.LabelSelector{},
+		},
+		{
+			name:          "namespace selector cleared",
+			wantNamespace: defaultNamespaceSelector,
+			wantObject:    &metav1.LabelSelector{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsm.Spec.Webhook.NamespaceSelector = tt.namespaceSelector
+			nsm.Spec.Webhook.ObjectSelector = tt.objectSelector
+			if err := r.Reconcile(context.TODO(), nsm); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			mwc := &admissionregistrationv1.MutatingWebhookConfiguration{}
+			if err := c.Get(context.TODO(), types.NamespacedName{Name: "nsm-admission-webhook-k8s"}, mwc); err != nil {
+				t.Fatal(err)
+			}
+			if got := mwc.Webhooks[0].NamespaceSelector; !reflect.DeepEqual(got, tt.wantNamespace) {
+				t.Errorf("namespaceSelector = %v, want %v", got, tt.wantNamespace)
+			}
+			if got := mwc.Webhooks[0].ObjectSelector; !reflect.DeepEqual(got, tt.wantObject) {
+				t.Errorf("objectSelector = %v, want %v", got, tt.wantObject)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// The endpoints register themselves with registry-k8s, their
+// registrations are read again at this interval
+const networkServiceCheckInterval time.Duration = time.Minute
+
+var networkServiceEndpointGVK = schema.GroupVersionKind{Group: "networkservicemesh.io", Version: "v1", Kind: "NetworkServiceEndpoint"}
+
+// NetworkServiceReconciler registers the network services of the CR with
+// registry-k8s and reports the endpoints registered for them
+type NetworkServiceReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func NewNetworkServiceReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme) *NetworkServiceReconciler {
+	return &NetworkServiceReconciler{
+		Client: client,
+		Log:    log,
+		Scheme: scheme,
+	}
+}
+
+func (r *NetworkServiceReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	// The registry-k8s CRDs may not be installed
+	_, err := r.Client.RESTMapper().RESTMapping(networkServiceGVK.GroupKind(), networkServiceGVK.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			r.Log.Info("NetworkService CRD not installed, skipping the network services")
+			return nil
+		}
+		return err
+	}
+
+	declared := map[string]bool{}
+	for _, service := range nsm.Spec.NetworkServices {
+		declared[service.Name] = true
+		if err := r.reconcileNetworkService(ctx, nsm, newNetworkService(nsm, service)); err != nil {
+			return err
+		}
+	}
+
+	// Remove the network services dropped from the CR
+	current := &unstructured.UnstructuredList{}
+	current.SetGroupVersionKind(networkServiceGVK)
+	if err := r.Client.List(ctx, current, client.InNamespace(nsm.ObjectMeta.Namespace),
+		client.MatchingLabels{ownerLabel: getOwnerLabels(nsm)[ownerLabel]}); err != nil {
+		return err
+	}
+	for i := range current.Items {
+		if declared[current.Items[i].GetName()] {
+			continue
+		}
+		if err := r.Client.Delete(ctx, &current.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			r.Log.Error(err, "failed to delete network service "+current.Items[i].GetName())
+			return err
+		}
+		r.Log.Info("network service " + current.Items[i].GetName() + " deleted")
+	}
+
+	endpoints, err := r.getRegisteredEndpoints(ctx, nsm)
+	if err != nil {
+		return err
+	}
+	nsm.Status.NetworkServices = nil
+	for _, service := range nsm.Spec.NetworkServices {
+		nsm.Status.NetworkServices = append(nsm.Status.NetworkServices, nsmv1alpha1.NetworkServiceStatus{
+			Name:      service.Name,
+			Endpoints: endpoints[service.Name],
+		})
+	}
+	return nil
+}
+
+func (r *NetworkServiceReconciler) reconcileNetworkService(ctx context.Context, nsm *nsmv1alpha1.NSM, desired *unstructured.Unstructured) error {
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(networkServiceGVK)
+	err := r.Client.Get(ctx, types.NamespacedName{Name: desired.GetName(), Namespace: desired.GetNamespace()}, current)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		// Set NSM instance as the owner and controller
+		controllerutil.SetControllerReference(nsm, desired, r.Scheme)
+		err = r.Client.Create(ctx, desired)
+		if err != nil {
+			r.Log.Error(err, "failed to create network service "+desired.GetName())
+			return err
+		}
+		r.Log.Info("network service " + desired.GetName() + " created")
+		return nil
+	}
+	// The whole spec comes from the CR, nothing is defaulted in it
+	if !equality.Semantic.DeepEqual(current.Object["spec"], desired.Object["spec"]) {
+		current.Object["spec"] = desired.Object["spec"]
+		current.SetLabels(desired.GetLabels())
+		err = r.Client.Update(ctx, current)
+		if err != nil {
+			r.Log.Error(err, "failed to update network service "+desired.GetName())
+			return err
+		}
+		r.Log.Info("network service " + desired.GetName() + " updated")
+	}
+	return nil
+}
+
+// Get the names of the endpoints registered for each network service
+func (r *NetworkServiceReconciler) getRegisteredEndpoints(ctx context.Context, nsm *nsmv1alpha1.NSM) (map[string][]string, error) {
+
+	nses := &unstructured.UnstructuredList{}
+	nses.SetGroupVersionKind(networkServiceEndpointGVK)
+	if err := r.Client.List(ctx, nses, client.InNamespace(nsm.ObjectMeta.Namespace)); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	endpoints := map[string][]string{}
+	for _, nse := range nses.Items {
+		services, _, _ := unstructured.NestedStringSlice(nse.Object, "spec", "network_service_names")
+		for _, service := range services {
+			endpoints[service] = append(endpoints[service], nse.GetName())
+		}
+	}
+	for service := range endpoints {
+		sort.Strings(endpoints[service])
+	}
+	return endpoints, nil
+}
+
+// The spec follows the JSON encoding of the registry NetworkService
+func newNetworkService(nsm *nsmv1alpha1.NSM, service nsmv1alpha1.NetworkService) *unstructured.Unstructured {
+
+	payload := service.Payload
+	if payload == "" {
+		payload = "IP"
+	}
+	spec := map[string]interface{}{
+		"name":    service.Name,
+		"payload": payload,
+	}
+	matches := []interface{}{}
+	for _, match := range service.Matches {
+		routes := []interface{}{}
+		for _, route := range match.Routes {
+			routes = append(routes, map[string]interface{}{
+				"destination_selector": toInterfaceMap(route.DestinationSelector),
+			})
+		}
+		matches = append(matches, map[string]interface{}{
+			"source_selector": toInterfaceMap(match.SourceSelector),
+			"routes":          routes,
+			"fallthrough":     match.Fallthrough,
+		})
+	}
+	if len(matches) > 0 {
+		spec["matches"] = matches
+	}
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetGroupVersionKind(networkServiceGVK)
+	obj.SetName(service.Name)
+	obj.SetNamespace(nsm.ObjectMeta.Namespace)
+	obj.SetLabels(getOwnerLabels(nsm))
+	return obj
+}
+
+func toInterfaceMap(values map[string]string) map[string]interface{} {
+	items := map[string]interface{}{}
+	for key, value := range values {
+		items[key] = value
+	}
+	return items
+}
+
+// Check the network services of the CR that the CRD schema can't validate
+func validateNetworkServices(nsm *nsmv1alpha1.NSM) error {
+
+	names := map[string]bool{}
+	for _, service := range nsm.Spec.NetworkServices {
+		if names[service.Name] {
+			return fmt.Errorf("network service %s is declared more than once", service.Name)
+		}
+		names[service.Name] = true
+
+		// A match for all the clients ends the evaluation unless it falls through
+		for i, match := range service.Matches {
+			if len(match.SourceSelector) == 0 && !match.Fallthrough && i < len(service.Matches)-1 {
+				return fmt.Errorf("match %d of network service %s selects all the clients, the next ones are never evaluated",
+					i, service.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// Get the NetworkServicesValid condition of the NSM status
+func getNetworkServicesCondition(err error) metav1.Condition {
+	if err != nil {
+		return metav1.Condition{
+			Type:    nsmv1alpha1.NSMConditionNetworkServicesValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  nsmv1alpha1.NSMReasonNetworkServicesInvalid,
+			Message: err.Error(),
+		}
+	}
+	return metav1.Condition{
+		Type:    nsmv1alpha1.NSMConditionNetworkServicesValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  nsmv1alpha1.NSMReasonNetworkServicesValid,
+		Message: "network services are valid",
+	}
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateNetworkServices(t *testing.T) {
+	route := []nsmv1alpha1.NetworkServiceRoute{{DestinationSelector: map[string]string{"app": "nse"}}}
+	tests := []struct {
+		name     string
+		services []nsmv1alpha1.NetworkService
+		wantErr  bool
+	}{
+		{name: "none"},
+		{
+			name: "matches ending with all the clients",
+			services: []nsmv1alpha1.NetworkService{{Name: "icmp", Matches: []nsmv1alpha1.NetworkServiceMatch{
+				{SourceSelector: map[string]string{"app": "nsc"}, Routes: route},
+				{Routes: route},
+			}}},
+		},
+		{
+			name: "falling through all the clients",
+			services: []nsmv1alpha1.NetworkService{{Name: "icmp", Matches: []nsmv1alpha1.NetworkServiceMatch{
+				{Routes: route, Fallthrough: true},
+				{SourceSelector: map[string]string{"app": "nsc"}, Routes: route},
+			}}},
+		},
+		{
+			name: "unreachable matches",
+			services: []nsmv1alpha1.NetworkService{{Name: "icmp", Matches: []nsmv1alpha1.NetworkServiceMatch{
+				{Routes: route},
+				{SourceSelector: map[string]string{"app": "nsc"}, Routes: route},
+			}}},
+			wantErr: true,
+		},
+		{name: "declared twice", services: []nsmv1alpha1.NetworkService{{Name: "icmp"}, {Name: "icmp"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsm := &nsmv1alpha1.NSM{Spec: nsmv1alpha1.NSMSpec{NetworkServices: tt.services}}
+			err := validateNetworkServices(nsm)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateNetworkServices() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if condition := getNetworkServicesCondition(err); (condition.Status == metav1.ConditionFalse) != tt.wantErr {
+				t.Errorf("condition = %v, wantErr %v", condition, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewNetworkService(t *testing.T) {
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+	service := nsmv1alpha1.NetworkService{
+		Name: "icmp",
+		Matches: []nsmv1alpha1.NetworkServiceMatch{{
+			SourceSelector: map[string]string{"app": "nsc"},
+			Routes:         []nsmv1alpha1.NetworkServiceRoute{{DestinationSelector: map[string]string{"app": "nse"}}},
+		}},
+	}
+
+	obj := newNetworkService(nsm, service)
+	if obj.GetName() != "icmp" || obj.GetNamespace() != "nsm" || obj.GroupVersionKind() != networkServiceGVK {
+		t.Errorf("network service = %s %s/%s", obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+	}
+	if !reflect.DeepEqual(obj.GetLabels(), getOwnerLabels(nsm)) {
+		t.Errorf("labels = %v, want the owner labels", obj.GetLabels())
+	}
+	want := map[string]interface{}{
+		"name":    "icmp",
+		"payload": "IP",
+		"matches": []interface{}{map[string]interface{}{
+			"source_selector": map[string]interface{}{"app": "nsc"},
+			"routes":          []interface{}{map[string]interface{}{"destination_selector": map[string]interface{}{"app": "nse"}}},
+			"fallthrough":     false,
+		}},
+	}
+	if !reflect.DeepEqual(obj.Object["spec"], want) {
+		t.Errorf("spec = %v, want %v", obj.Object["spec"], want)
+	}
+
+	service.Payload = "ETHERNET"
+	service.Matches = nil
+	want = map[string]interface{}{"name": "icmp", "payload": "ETHERNET"}
+	if spec := newNetworkService(nsm, service).Object["spec"]; !reflect.DeepEqual(spec, want) {
+		t.Errorf("spec = %v, want %v", spec, want)
+	}
+}
+
+func TestNetworkServiceReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(networkServiceEndpointGVK, meta.RESTScopeNamespace)
+	mapper.Add(networkServiceGVK, meta.RESTScopeNamespace)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	nse := func(name string, services ...interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{"network_service_names": services},
+		}}
+		obj.SetGroupVersionKind(networkServiceEndpointGVK)
+		obj.SetName(name)
+		obj.SetNamespace("nsm")
+		return obj
+	}
+	// Registered by hand, not declared in the CR
+	unowned := newNetworkService(&nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "nsm"}},
+		nsmv1alpha1.NetworkService{Name: "manual"})
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(
+		nse("nse-b", "icmp"), nse("nse-a", "icmp", "vl3"), unowned,
+	).Build()
+	r := NewNetworkServiceReconciler(c, logr.Discard(), scheme)
+
+	get := func(name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(networkServiceGVK)
+		err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: "nsm"}, obj)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+
+	nsm.Spec.NetworkServices = []nsmv1alpha1.NetworkService{{Name: "icmp"}, {Name: "vl3"}, {Name: "unused"}}
+	if err := r.Reconcile(context.TODO(), nsm); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	for _, name := range []string{"icmp", "vl3", "unused"} {
+		if obj := get(name); obj == nil || !metav1.IsControlledBy(obj, nsm) {
+			t.Errorf("network service %s = %v, want one controlled by the NSM CR", name, obj)
+		}
+	}
+	wantStatus := []nsmv1alpha1.NetworkServiceStatus{
+		{Name: "icmp", Endpoints: []string{"nse-a", "nse-b"}},
+		{Name: "vl3", Endpoints: []string{"nse-a"}},
+		{Name: "unused"},
+	}
+	if !reflect.DeepEqual(nsm.Status.NetworkServices, wantStatus) {
+		t.Errorf("status = %v, want %v", nsm.Status.NetworkServices, wantStatus)
+	}
+
+	// Changed payload, dropped service
+	nsm.Spec.NetworkServices = []nsmv1alpha1.NetworkService{{Name: "icmp", Payload: "ETHERNET"}, {Name: "vl3"}}
+	if err := r.Reconcile(context.TODO(), nsm); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if payload, _, _ := unstructured.NestedString(get("icmp").Object, "spec", "payload"); payload != "ETHERNET" {
+		t.Errorf("icmp payload = %s, want ETHERNET", payload)
+	}
+	if get("unused") != nil {
+		t.Errorf("network service dropped from the CR not deleted")
+	}
+	if get("manual") == nil {
+		t.Errorf("network service of another owner deleted")
+	}
+
+	// Without the registry-k8s CRDs the services are skipped
+	nsm.Status.NetworkServices = nil
+	r = NewNetworkServiceReconciler(fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(meta.NewDefaultRESTMapper(nil)).Build(), logr.Discard(), scheme)
+	if err := r.Reconcile(context.TODO(), nsm); err != nil {
+		t.Fatalf("Reconcile() without the CRDs error = %v", err)
+	}
+	if nsm.Status.NetworkServices != nil {
+		t.Errorf("status = %v without the CRDs, want none", nsm.Status.NetworkServices)
+	}
+}
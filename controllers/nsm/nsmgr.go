@@ -7,11 +7,9 @@ import (
 	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -30,34 +28,18 @@ func NewNsmgrReconciler(client client.Client, log logr.Logger, scheme *runtime.S
 	}
 }
 
+// The daemonset is rolled when anything rendered from the NSM instance
+// changes, such as the images, the privileges or the pod security settings
 func (r *NsmgrReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
-
-	ds := &appsv1.DaemonSet{}
-	err := r.Client.Get(ctx, types.NamespacedName{Name: "nsmgr", Namespace: nsm.ObjectMeta.Namespace}, ds)
-	if err != nil {
-		if apierrors.IsNotFound(err) {
-			ds = r.daemonSetForNSMGR(nsm)
-			err = r.Client.Create(context.TODO(), ds)
-			if err != nil {
-				r.Log.Error(err, "failed to create daemonset for nsmgr")
-				return err
-			}
-			r.Log.Info("nsm nsmgr daemonset created")
-			return nil
-		}
-		return err
-	}
-	r.Log.Info("nsm nsmgr daemonset already exists, skipping creation")
-	return nil
+	return reconcileComponentDaemonSet(ctx, r.Client, r.Log, r.daemonSetForNSMGR(nsm))
 }
 
 func (r *NsmgrReconciler) daemonSetForNSMGR(nsm *nsmv1alpha1.NSM) *appsv1.DaemonSet {
 
 	objectMeta := newObjectMeta("nsmgr", "nsm", map[string]string{"app": "nsm"})
 
+	hostPaths := getHostPaths(nsm)
 	volType := corev1.HostPathDirectoryOrCreate
-	volTypeSpire := corev1.HostPathDirectory
-	privmode := true
 
 	nsmgrLabel := map[string]string{"app": "nsmgr", "spiffe.io/spiffe-id": "true"}
 
@@ -97,10 +79,12 @@ func (r *NsmgrReconciler) daemonSetForNSMGR(nsm *nsmv1alpha1.NSM) *appsv1.Daemon
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: nsmgrLabel,
+					Labels:      nsmgrLabel,
+					Annotations: mergeAnnotations(getAppArmorAnnotations(nsm.Spec.Nsmgr.PodSecurity, "nsmgr", "exclude-prefixes"), getFederationAnnotations(nsm)),
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName: serviceAccountName,
+					ServiceAccountName: nsmgrServiceAccountName,
+					SecurityContext:    getPodSecurityContext(nsm.Spec.Nsmgr.PodSecurity, true),
 					Containers: []corev1.Container{
 
 						// nsmgr container
@@ -108,9 +92,7 @@ func (r *NsmgrReconciler) daemonSetForNSMGR(nsm *nsmv1alpha1.NSM) *appsv1.Daemon
 							Name:            "nsmgr",
 							Image:           nsm.Spec.Nsmgr.Image,
 							ImagePullPolicy: nsm.Spec.NsmPullPolicy,
-							SecurityContext: &corev1.SecurityContext{
-								Privileged: &privmode,
-							},
+							SecurityContext: getNsmgrSecurityContext(nsm),
 							Ports: []corev1.ContainerPort{{
 								ContainerPort: 5001,
 								HostPort:      5001}},
@@ -166,7 +148,7 @@ func (r *NsmgrReconciler) daemonSetForNSMGR(nsm *nsmv1alpha1.NSM) *appsv1.Daemon
 									MountPath: "/var/lib/networkservicemesh/config/",
 								},
 								{Name: "spire-agent-socket",
-									MountPath: "/run/spire/sockets",
+									MountPath: getSpireAgentSocketDir(nsm),
 									ReadOnly:  true,
 								},
 							},
@@ -186,10 +168,8 @@ func (r *NsmgrReconciler) daemonSetForNSMGR(nsm *nsmv1alpha1.NSM) *appsv1.Daemon
 							Name:            "exclude-prefixes",
 							Image:           nsm.Spec.ExclPref.Image,
 							ImagePullPolicy: nsm.Spec.NsmPullPolicy,
-							SecurityContext: &corev1.SecurityContext{
-								Privileged: &privmode,
-							},
-							Env: exclPrefEnvVars,
+							SecurityContext: getExclPrefSecurityContext(nsm),
+							Env:             exclPrefEnvVars,
 							VolumeMounts: []corev1.VolumeMount{
 								{Name: "exclude-prefixes-volume",
 									MountPath: "/var/lib/networkservicemesh/config",
@@ -207,16 +187,10 @@ func (r *NsmgrReconciler) daemonSetForNSMGR(nsm *nsmv1alpha1.NSM) *appsv1.Daemon
 							Name: "nsm-socket",
 							VolumeSource: corev1.VolumeSource{
 								HostPath: &corev1.HostPathVolumeSource{
-									Path: "/var/lib/networkservicemesh",
+									Path: hostPaths.NsmSocket,
 									Type: &volType,
 								}}},
-						{
-							Name: "spire-agent-socket",
-							VolumeSource: corev1.VolumeSource{
-								HostPath: &corev1.HostPathVolumeSource{
-									Path: "/run/spire/sockets",
-									Type: &volTypeSpire,
-								}}},
+						getSpireAgentSocketVolume(nsm),
 						{
 							Name: "exclude-prefixes-volume",
 							VolumeSource: corev1.VolumeSource{
@@ -227,6 +201,7 @@ func (r *NsmgrReconciler) daemonSetForNSMGR(nsm *nsmv1alpha1.NSM) *appsv1.Daemon
 			},
 		},
 	}
+	setConfigHash(&daemonset.Spec.Template)
 
 	// Set NSM instance as the owner and controller
 	controllerutil.SetControllerReference(nsm, daemonset, r.Scheme)
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNsmgrAndForwarderRolling(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	nsm.Spec.Forwarders = []nsmv1alpha1.Forwarder{{Type: nsmv1alpha1.ForwarderVpp}}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	reconcile := func() {
+		if err := NewNsmgrReconciler(c, logr.Discard(), scheme).Reconcile(context.TODO(), nsm); err != nil {
+			t.Fatalf("nsmgr Reconcile() error = %v", err)
+		}
+		if err := NewForwarderReconciler(c, logr.Discard(), scheme, nsmv1alpha1.ForwarderVpp).Reconcile(context.TODO(), nsm); err != nil {
+			t.Fatalf("forwarder Reconcile() error = %v", err)
+		}
+	}
+	privileged := func(name string) bool {
+		ds := &appsv1.DaemonSet{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: "nsm"}, ds); err != nil {
+			t.Fatalf("daemonset %s: %v", name, err)
+		}
+		sc := ds.Spec.Template.Spec.Containers[0].SecurityContext
+		return sc != nil && sc.Privileged != nil && *sc.Privileged
+	}
+
+	tests := []struct {
+		name       string
+		privileged bool
+	}{
+		{"created unprivileged", false},
+		{"rolled to privileged", true},
+		{"rolled back", false},
+	}
+	for _, tt := range tests {
+		nsm.Spec.Privileged = tt.privileged
+		reconcile()
+		for _, name := range []string{"nsmgr", "forwarder-vpp"} {
+			if got := privileged(name); got != tt.privileged {
+				t.Errorf("%s: %s privileged = %v, want %v", tt.name, name, got, tt.privileged)
+			}
+		}
+	}
+}
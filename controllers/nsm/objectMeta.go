@@ -11,3 +11,41 @@ func newObjectMeta(name string, namespace string, labels map[string]string) meta
 		Labels:    labels,
 	}
 }
+
+// Merge the annotations of a pod template, nil when there are none
+func mergeAnnotations(annotations ...map[string]string) map[string]string {
+	var merged map[string]string
+	for _, a := range annotations {
+		for key, value := range a {
+			if merged == nil {
+				merged = map[string]string{}
+			}
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// Check an existing object is controlled by the owner set on the
+// one rendered for it, the operator doesn't take over other objects
+func isControlledAs(existing metav1.Object, desired metav1.Object) bool {
+	owner := metav1.GetControllerOf(desired)
+	controller := metav1.GetControllerOf(existing)
+	return owner != nil && controller != nil && owner.UID == controller.UID
+}
+
+// notControlledError reports an object to render that already exists
+// and is controlled by something else
+type notControlledError struct {
+	kind string
+	name string
+}
+
+func (e *notControlledError) Error() string {
+	return e.kind + " " + e.name + " already exists and is not controlled by the operator"
+}
+
+func isNotControlled(err error) bool {
+	_, ok := err.(*notControlledError)
+	return ok
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// platformProfile holds the platform specific defaults for NSM components
+type platformProfile struct {
+	// host directories used when they are not defined in the CR
+	hostPaths nsmv1alpha1.HostPaths
+	// whether NSM pods need to be granted SecurityContextConstraints
+	securityContextConstraints bool
+	// SELinux options of the pods mounting host directories, nsmgr
+	// and the forwarders, when not defined in the CR
+	hostSELinuxOptions *corev1.SELinuxOptions
+	// seccomp profile of the forwarders when not defined in the CR
+	forwarderSeccompProfile *corev1.SeccompProfile
+}
+
+var platformProfiles = map[nsmv1alpha1.Platform]platformProfile{
+	nsmv1alpha1.PlatformKubernetes: {},
+	nsmv1alpha1.PlatformOpenShift: {
+		securityContextConstraints: true,
+		// The host directories aren't labeled for containers
+		hostSELinuxOptions: &corev1.SELinuxOptions{Type: "spc_t"},
+		// The forwarders need syscalls filtered out by RuntimeDefault,
+		// keep nodes defaulting the seccomp profile from applying it
+		forwarderSeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined},
+	},
+	// k3s and kind run the kubelet of their nodes from the usual directories
+	nsmv1alpha1.PlatformK3s: {
+		hostPaths: nsmv1alpha1.HostPaths{
+			Kubelet: kubeletHostPath,
+			Cgroup:  cgroupHostPath,
+		},
+	},
+	nsmv1alpha1.PlatformKind: {
+		hostPaths: nsmv1alpha1.HostPaths{
+			Kubelet: kubeletHostPath,
+			Cgroup:  cgroupHostPath,
+		},
+	},
+	nsmv1alpha1.PlatformMicroK8s: {
+		hostPaths: nsmv1alpha1.HostPaths{
+			Kubelet: "/var/snap/microk8s/common/var/lib/kubelet",
+		},
+	},
+}
+
+// DetectPlatform finds out the kubernetes distribution the operator runs on.
+// OpenShift is recognized by its API groups, the other distributions by the
+// information their kubelets report about the nodes.
+func DetectPlatform(ctx context.Context, cfg *rest.Config, reader client.Reader) (nsmv1alpha1.Platform, error) {
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nsmv1alpha1.PlatformKubernetes, err
+	}
+	groups, err := discoveryClient.ServerGroups()
+	if err != nil {
+		return nsmv1alpha1.PlatformKubernetes, err
+	}
+	for _, group := range groups.Groups {
+		if group.Name == "security.openshift.io" {
+			return nsmv1alpha1.PlatformOpenShift, nil
+		}
+	}
+
+	nodes := &corev1.NodeList{}
+	if err = reader.List(ctx, nodes); err != nil {
+		return nsmv1alpha1.PlatformKubernetes, err
+	}
+	for _, node := range nodes.Items {
+		switch {
+		case strings.HasPrefix(node.Spec.ProviderID, "kind://"):
+			return nsmv1alpha1.PlatformKind, nil
+		case strings.Contains(node.Status.NodeInfo.KubeletVersion, "+k3s"):
+			return nsmv1alpha1.PlatformK3s, nil
+		case node.Labels["microk8s.io/cluster"] == "true":
+			return nsmv1alpha1.PlatformMicroK8s, nil
+		}
+	}
+	return nsmv1alpha1.PlatformKubernetes, nil
+}
+
+// Get the platform for the NSM components, the one set in the CR
+// takes precedence over the detected one
+func getPlatform(nsm *nsmv1alpha1.NSM, detected nsmv1alpha1.Platform) nsmv1alpha1.Platform {
+	if nsm.Spec.Platform != "" {
+		return nsm.Spec.Platform
+	}
+	if detected != "" {
+		return detected
+	}
+	return nsmv1alpha1.PlatformKubernetes
+}
+
+// Fill in the platform defaults for the values not defined in the CR
+func applyPlatformProfile(nsm *nsmv1alpha1.NSM, platform nsmv1alpha1.Platform) {
+	profile := platformProfiles[platform]
+
+	hostPaths := &nsm.Spec.HostPaths
+	if hostPaths.Kubelet == "" {
+		hostPaths.Kubelet = profile.hostPaths.Kubelet
+	}
+	if hostPaths.NsmSocket == "" {
+		hostPaths.NsmSocket = profile.hostPaths.NsmSocket
+	}
+	if hostPaths.SpireAgentSocket == "" {
+		hostPaths.SpireAgentSocket = profile.hostPaths.SpireAgentSocket
+	}
+	if hostPaths.Cgroup == "" {
+		hostPaths.Cgroup = profile.hostPaths.Cgroup
+	}
+	if hostPaths.Vfio == "" {
+		hostPaths.Vfio = profile.hostPaths.Vfio
+	}
+
+	if nsm.Spec.Nsmgr.PodSecurity.SELinuxOptions == nil {
+		nsm.Spec.Nsmgr.PodSecurity.SELinuxOptions = profile.hostSELinuxOptions.DeepCopy()
+	}
+	for i := range nsm.Spec.Forwarders {
+		podSecurity := &nsm.Spec.Forwarders[i].PodSecurity
+		if podSecurity.SELinuxOptions == nil {
+			podSecurity.SELinuxOptions = profile.hostSELinuxOptions.DeepCopy()
+		}
+		if podSecurity.SeccompProfile == nil {
+			podSecurity.SeccompProfile = profile.forwarderSeccompProfile.DeepCopy()
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// Serve the discovery endpoints of an API server with the given API groups
+func newDiscoveryServer(t *testing.T, groups ...string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body interface{}
+		switch r.URL.Path {
+		case "/api":
+			body = &metav1.APIVersions{Versions: []string{"v1"}}
+		case "/apis":
+			list := &metav1.APIGroupList{}
+			for _, group := range groups {
+				version := metav1.GroupVersionForDiscovery{GroupVersion: group + "/v1", Version: "v1"}
+				list.Groups = append(list.Groups, metav1.APIGroup{
+					Name:             group,
+					Versions:         []metav1.GroupVersionForDiscovery{version},
+					PreferredVersion: version,
+				})
+			}
+			body = list
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDetectPlatform(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+
+	node := func(mutate func(node *corev1.Node)) client.Object {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+		mutate(node)
+		return node
+	}
+	tests := []struct {
+		name   string
+		groups []string
+		nodes  []client.Object
+		want   nsmv1alpha1.Platform
+	}{
+		{name: "no nodes", want: nsmv1alpha1.PlatformKubernetes},
+		{
+			name:  "kubernetes",
+			nodes: []client.Object{node(func(node *corev1.Node) { node.Status.NodeInfo.KubeletVersion = "v1.23.5" })},
+			want:  nsmv1alpha1.PlatformKubernetes,
+		},
+		{
+			name:   "openshift",
+			groups: []string{"apps", "security.openshift.io"},
+			nodes:  []client.Object{node(func(node *corev1.Node) { node.Status.NodeInfo.KubeletVersion = "v1.23.5+3afdacb" })},
+			want:   nsmv1alpha1.PlatformOpenShift,
+		},
+		{
+			name:  "kind",
+			nodes: []client.Object{node(func(node *corev1.Node) { node.Spec.ProviderID = "kind://docker/kind/kind-control-plane" })},
+			want:  nsmv1alpha1.PlatformKind,
+		},
+		{
+			name:  "k3s",
+			nodes: []client.Object{node(func(node *corev1.Node) { node.Status.NodeInfo.KubeletVersion = "v1.23.5+k3s1" })},
+			want:  nsmv1alpha1.PlatformK3s,
+		},
+		{
+			name:  "microk8s",
+			nodes: []client.Object{node(func(node *corev1.Node) { node.Labels = map[string]string{"microk8s.io/cluster": "true"} })},
+			want:  nsmv1alpha1.PlatformMicroK8s,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newDiscoveryServer(t, tt.groups...)
+			reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.nodes...).Build()
+			got, err := DetectPlatform(context.Background(), &rest.Config{Host: server.URL}, reader)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectPlatform() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectPlatformDiscoveryError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	reader := fake.NewClientBuilder().Build()
+	got, err := DetectPlatform(context.Background(), &rest.Config{Host: server.URL}, reader)
+	if err == nil {
+		t.Errorf("expected an error")
+	}
+	if got != nsmv1alpha1.PlatformKubernetes {
+		t.Errorf("DetectPlatform() = %v, want %v", got, nsmv1alpha1.PlatformKubernetes)
+	}
+}
+
+func TestGetPlatform(t *testing.T) {
+	tests := []struct {
+		name     string
+		platform nsmv1alpha1.Platform
+		detected nsmv1alpha1.Platform
+		want     nsmv1alpha1.Platform
+	}{
+		{name: "set in the CR", platform: nsmv1alpha1.PlatformK3s, detected: nsmv1alpha1.PlatformKind, want: nsmv1alpha1.PlatformK3s},
+		{name: "detected", detected: nsmv1alpha1.PlatformKind, want: nsmv1alpha1.PlatformKind},
+		{name: "default", want: nsmv1alpha1.PlatformKubernetes},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsm := &nsmv1alpha1.NSM{Spec: nsmv1alpha1.NSMSpec{Platform: tt.platform}}
+			if got := getPlatform(nsm, tt.detected); got != tt.want {
+				t.Errorf("getPlatform() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPlatformProfile(t *testing.T) {
+	newNSM := func() *nsmv1alpha1.NSM {
+		return &nsmv1alpha1.NSM{Spec: nsmv1alpha1.NSMSpec{
+			Forwarders: []nsmv1alpha1.Forwarder{{Type: nsmv1alpha1.ForwarderVpp}},
+		}}
+	}
+
+	nsm := newNSM()
+	applyPlatformProfile(nsm, nsmv1alpha1.PlatformMicroK8s)
+	if nsm.Spec.HostPaths.Kubelet != "/var/snap/microk8s/common/var/lib/kubelet" {
+		t.Errorf("microk8s kubelet host path = %s", nsm.Spec.HostPaths.Kubelet)
+	}
+	for _, platform := range []nsmv1alpha1.Platform{nsmv1alpha1.PlatformK3s, nsmv1alpha1.PlatformKind} {
+		nsm := newNSM()
+		applyPlatformProfile(nsm, platform)
+		if nsm.Spec.HostPaths.Kubelet != kubeletHostPath || nsm.Spec.HostPaths.Cgroup != cgroupHostPath {
+			t.Errorf("%s host paths = %+v", platform, nsm.Spec.HostPaths)
+		}
+	}
+
+	nsm = newNSM()
+	applyPlatformProfile(nsm, nsmv1alpha1.PlatformKubernetes)
+	if nsm.Spec.Nsmgr.PodSecurity.SELinuxOptions != nil || nsm.Spec.Forwarders[0].PodSecurity.SeccompProfile != nil {
+		t.Errorf("kubernetes pod security = %+v, %+v, want none", nsm.Spec.Nsmgr.PodSecurity, nsm.Spec.Forwarders[0].PodSecurity)
+	}
+
+	nsm = newNSM()
+	nsm.Spec.Forwarders = append(nsm.Spec.Forwarders, nsmv1alpha1.Forwarder{
+		Type:        nsmv1alpha1.ForwarderOvs,
+		PodSecurity: nsmv1alpha1.PodSecurity{SELinuxOptions: &corev1.SELinuxOptions{Level: "s0:c1,c2"}},
+	})
+	applyPlatformProfile(nsm, nsmv1alpha1.PlatformOpenShift)
+	if options := nsm.Spec.Nsmgr.PodSecurity.SELinuxOptions; options == nil || options.Type != "spc_t" {
+		t.Errorf("openshift nsmgr SELinux options = %v, want spc_t", options)
+	}
+	if options := nsm.Spec.Forwarders[0].PodSecurity.SELinuxOptions; options == nil || options.Type != "spc_t" {
+		t.Errorf("openshift forwarder SELinux options = %v, want spc_t", options)
+	}
+	if profile := nsm.Spec.Forwarders[0].PodSecurity.SeccompProfile; profile == nil || profile.Type != corev1.SeccompProfileTypeUnconfined {
+		t.Errorf("openshift forwarder seccomp profile = %v, want Unconfined", profile)
+	}
+	// The settings of the CR take precedence
+	if options := nsm.Spec.Forwarders[1].PodSecurity.SELinuxOptions; options.Type != "" || options.Level != "s0:c1,c2" {
+		t.Errorf("forwarder SELinux options = %v, want the CR ones", options)
+	}
+	nsm.Spec.Nsmgr.PodSecurity.SELinuxOptions.Type = "changed"
+	if platformProfiles[nsmv1alpha1.PlatformOpenShift].hostSELinuxOptions.Type != "spc_t" {
+		t.Errorf("profile shared with the NSM CR")
+	}
+}
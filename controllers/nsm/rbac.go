@@ -0,0 +1,406 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// Service accounts of the NSM components
+const (
+	nsmgrServiceAccountName            string = "nsmgr-sa"
+	forwarderServiceAccountName        string = "forwarder-sa"
+	registryServiceAccountName         string = "registry-sa"
+	webhookServiceAccountName          string = "admission-webhook-sa"
+	spireServerServiceAccountName      string = "spire-server"
+	spireAgentServiceAccountName       string = "spire-agent"
+	nseServiceAccountName              string = "nse-sa"
+	nsmgrProxyServiceAccountName       string = "nsmgr-proxy-sa"
+	registryProxyDNSServiceAccountName string = "registry-proxy-dns-sa"
+)
+
+// Service accounts of all the components, the ones of the disabled
+// components are removed together with their roles
+var componentServiceAccountNames = []string{
+	nsmgrServiceAccountName,
+	forwarderServiceAccountName,
+	registryServiceAccountName,
+	webhookServiceAccountName,
+	spireServerServiceAccountName,
+	spireAgentServiceAccountName,
+	nseServiceAccountName,
+	nsmgrProxyServiceAccountName,
+	registryProxyDNSServiceAccountName,
+}
+
+// componentRBAC holds the identity of an NSM component and
+// the permissions it needs on the kubernetes API
+type componentRBAC struct {
+	serviceAccount string
+	// rules granted in the NSM namespace
+	rules []rbacv1.PolicyRule
+	// rules granted cluster wide
+	clusterRules []rbacv1.PolicyRule
+}
+
+// RBACReconciler creates a dedicated service account for each NSM component
+// together with the roles it needs
+type RBACReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func NewRBACReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme) *RBACReconciler {
+	return &RBACReconciler{
+		Client: client,
+		Log:    log,
+		Scheme: scheme,
+	}
+}
+
+func (r *RBACReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	enabled := map[string]bool{}
+	for _, component := range getComponentRBAC(nsm) {
+		enabled[component.serviceAccount] = true
+		if err := r.reconcileServiceAccount(ctx, nsm, component); err != nil {
+			return err
+		}
+		if len(component.rules) > 0 {
+			if err := r.reconcileRole(ctx, nsm, component); err != nil {
+				return err
+			}
+		} else if err := r.deleteRole(ctx, nsm, component.serviceAccount); err != nil {
+			return err
+		}
+		if len(component.clusterRules) > 0 {
+			if err := r.reconcileClusterRole(ctx, nsm, component); err != nil {
+				return err
+			}
+		} else if err := r.deleteClusterRole(ctx, nsm, component.serviceAccount); err != nil {
+			return err
+		}
+	}
+
+	// Remove the identities of the disabled components
+	for _, serviceAccount := range componentServiceAccountNames {
+		if enabled[serviceAccount] {
+			continue
+		}
+		if err := r.deleteRole(ctx, nsm, serviceAccount); err != nil {
+			return err
+		}
+		if err := r.deleteClusterRole(ctx, nsm, serviceAccount); err != nil {
+			return err
+		}
+		if err := deleteComponentObjects(ctx, r.Client, r.Log, nsm,
+			&corev1.ServiceAccount{ObjectMeta: newObjectMeta(serviceAccount, nsm.ObjectMeta.Namespace, nil)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RBACReconciler) deleteRole(ctx context.Context, nsm *nsmv1alpha1.NSM, serviceAccount string) error {
+	return deleteComponentObjects(ctx, r.Client, r.Log, nsm,
+		&rbacv1.RoleBinding{ObjectMeta: newObjectMeta(serviceAccount, nsm.ObjectMeta.Namespace, nil)},
+		&rbacv1.Role{ObjectMeta: newObjectMeta(serviceAccount, nsm.ObjectMeta.Namespace, nil)})
+}
+
+// Delete the cluster role of a component, the ones
+// labeled with another owner are left untouched
+func (r *RBACReconciler) deleteClusterRole(ctx context.Context, nsm *nsmv1alpha1.NSM, serviceAccount string) error {
+
+	name := nsm.ObjectMeta.Namespace + "-" + serviceAccount
+	owner := getOwnerLabels(nsm)[ownerLabel]
+	for _, obj := range []client.Object{&rbacv1.ClusterRoleBinding{}, &rbacv1.ClusterRole{}} {
+		err := r.Client.Get(ctx, types.NamespacedName{Name: name}, obj)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if obj.GetLabels()[ownerLabel] != owner {
+			continue
+		}
+		if err := r.Client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			r.Log.Error(err, "failed to delete "+name)
+			return err
+		}
+		r.Log.Info(name + " deleted")
+	}
+	return nil
+}
+
+// Bind the role of a component to its service account
+func getComponentSubjects(nsm *nsmv1alpha1.NSM, component componentRBAC) []rbacv1.Subject {
+	return []rbacv1.Subject{{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      component.serviceAccount,
+		Namespace: nsm.ObjectMeta.Namespace,
+	}}
+}
+
+func (r *RBACReconciler) reconcileServiceAccount(ctx context.Context, nsm *nsmv1alpha1.NSM, component componentRBAC) error {
+
+	sa := &corev1.ServiceAccount{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: component.serviceAccount, Namespace: nsm.ObjectMeta.Namespace}, sa)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			sa = &corev1.ServiceAccount{
+				ObjectMeta: newObjectMeta(component.serviceAccount, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"}),
+			}
+			// Set NSM instance as the owner and controller
+			controllerutil.SetControllerReference(nsm, sa, r.Scheme)
+			err = r.Client.Create(ctx, sa)
+			if err != nil {
+				r.Log.Error(err, "failed to create service account "+component.serviceAccount)
+				return err
+			}
+			r.Log.Info("service account " + component.serviceAccount + " created")
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *RBACReconciler) reconcileRole(ctx context.Context, nsm *nsmv1alpha1.NSM, component componentRBAC) error {
+
+	objectMeta := newObjectMeta(component.serviceAccount, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"})
+
+	role := &rbacv1.Role{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: objectMeta.Name, Namespace: objectMeta.Namespace}, role)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		role = &rbacv1.Role{ObjectMeta: objectMeta, Rules: component.rules}
+		// Set NSM instance as the owner and controller
+		controllerutil.SetControllerReference(nsm, role, r.Scheme)
+		err = r.Client.Create(ctx, role)
+		if err != nil {
+			r.Log.Error(err, "failed to create role "+role.Name)
+			return err
+		}
+		r.Log.Info("role " + role.Name + " created")
+	} else if !equality.Semantic.DeepEqual(role.Rules, component.rules) {
+		role.Rules = component.rules
+		err = r.Client.Update(ctx, role)
+		if err != nil {
+			r.Log.Error(err, "failed to update role "+role.Name)
+			return err
+		}
+		r.Log.Info("role " + role.Name + " updated")
+	}
+
+	roleBinding := &rbacv1.RoleBinding{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: objectMeta.Name, Namespace: objectMeta.Namespace}, roleBinding)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		roleBinding = &rbacv1.RoleBinding{
+			ObjectMeta: objectMeta,
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "Role",
+				Name:     role.Name,
+			},
+			Subjects: getComponentSubjects(nsm, component),
+		}
+		// Set NSM instance as the owner and controller
+		controllerutil.SetControllerReference(nsm, roleBinding, r.Scheme)
+		err = r.Client.Create(ctx, roleBinding)
+		if err != nil {
+			r.Log.Error(err, "failed to create role binding "+roleBinding.Name)
+			return err
+		}
+		r.Log.Info("role binding " + roleBinding.Name + " created")
+	} else if !equality.Semantic.DeepEqual(roleBinding.Subjects, getComponentSubjects(nsm, component)) {
+		roleBinding.Subjects = getComponentSubjects(nsm, component)
+		err = r.Client.Update(ctx, roleBinding)
+		if err != nil {
+			r.Log.Error(err, "failed to update role binding "+roleBinding.Name)
+			return err
+		}
+		r.Log.Info("role binding " + roleBinding.Name + " updated")
+	}
+	return nil
+}
+
+// Cluster scoped roles can't be owned by the NSM CR, they are
+// labeled with it instead and removed by its finalizer
+func (r *RBACReconciler) reconcileClusterRole(ctx context.Context, nsm *nsmv1alpha1.NSM, component componentRBAC) error {
+
+	objectMeta := newObjectMeta(nsm.ObjectMeta.Namespace+"-"+component.serviceAccount, "", getOwnerLabels(nsm))
+
+	clusterRole := &rbacv1.ClusterRole{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: objectMeta.Name}, clusterRole)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		clusterRole = &rbacv1.ClusterRole{ObjectMeta: objectMeta, Rules: component.clusterRules}
+		err = r.Client.Create(ctx, clusterRole)
+		if err != nil {
+			r.Log.Error(err, "failed to create cluster role "+clusterRole.Name)
+			return err
+		}
+		r.Log.Info("cluster role " + clusterRole.Name + " created")
+	} else if !equality.Semantic.DeepEqual(clusterRole.Rules, component.clusterRules) {
+		clusterRole.Rules = component.clusterRules
+		err = r.Client.Update(ctx, clusterRole)
+		if err != nil {
+			r.Log.Error(err, "failed to update cluster role "+clusterRole.Name)
+			return err
+		}
+		r.Log.Info("cluster role " + clusterRole.Name + " updated")
+	}
+
+	clusterRoleBinding := &rbacv1.ClusterRoleBinding{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: objectMeta.Name}, clusterRoleBinding)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		clusterRoleBinding = &rbacv1.ClusterRoleBinding{
+			ObjectMeta: objectMeta,
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     clusterRole.Name,
+			},
+			Subjects: getComponentSubjects(nsm, component),
+		}
+		err = r.Client.Create(ctx, clusterRoleBinding)
+		if err != nil {
+			r.Log.Error(err, "failed to create cluster role binding "+clusterRoleBinding.Name)
+			return err
+		}
+		r.Log.Info("cluster role binding " + clusterRoleBinding.Name + " created")
+	} else if !equality.Semantic.DeepEqual(clusterRoleBinding.Subjects, getComponentSubjects(nsm, component)) {
+		clusterRoleBinding.Subjects = getComponentSubjects(nsm, component)
+		err = r.Client.Update(ctx, clusterRoleBinding)
+		if err != nil {
+			r.Log.Error(err, "failed to update cluster role binding "+clusterRoleBinding.Name)
+			return err
+		}
+		r.Log.Info("cluster role binding " + clusterRoleBinding.Name + " updated")
+	}
+	return nil
+}
+
+// Get the service accounts and permissions of the NSM components
+// the forwarders and the NSMEndpoints don't talk to the kubernetes API at all
+func getComponentRBAC(nsm *nsmv1alpha1.NSM) []componentRBAC {
+
+	components := []componentRBAC{
+		// exclude-prefixes-k8s runs next to nsmgr, it collects the cluster CIDRs from
+		// the kubeadm-config config map, the pods, the services and the nodes
+		{
+			serviceAccount: nsmgrServiceAccountName,
+			clusterRules: []rbacv1.PolicyRule{{
+				APIGroups: []string{""},
+				Resources: []string{"configmaps", "pods", "services", "nodes"},
+				Verbs:     []string{"get", "list", "watch"},
+			}},
+		},
+		{serviceAccount: forwarderServiceAccountName},
+		{serviceAccount: nseServiceAccountName},
+	}
+
+	// registry-k8s stores the registrations as custom resources
+	registry := componentRBAC{serviceAccount: registryServiceAccountName}
+	if nsm.Spec.Registry.Type == "k8s" {
+		registry.rules = []rbacv1.PolicyRule{{
+			APIGroups: []string{"networkservicemesh.io"},
+			Resources: []string{"networkservices", "networkserviceendpoints"},
+			Verbs:     []string{"get", "list", "watch", "create", "update", "patch", "delete"},
+		}}
+	}
+	components = append(components, registry)
+
+	// admission-webhook-k8s reads the namespaces of the pods it mutates,
+	// its MutatingWebhookConfiguration is registered by the operator
+	if nsm.Spec.Webhook.Image != "" {
+		components = append(components, componentRBAC{
+			serviceAccount: webhookServiceAccountName,
+			clusterRules: []rbacv1.PolicyRule{{
+				APIGroups: []string{""},
+				Resources: []string{"namespaces"},
+				Verbs:     []string{"get"},
+			}},
+		})
+	}
+
+	// map-ip-k8s reads the addresses of the nodes next to nsmgr-proxy
+	if nsm.Spec.Interdomain.Enabled {
+		components = append(components,
+			componentRBAC{
+				serviceAccount: nsmgrProxyServiceAccountName,
+				clusterRules: []rbacv1.PolicyRule{{
+					APIGroups: []string{""},
+					Resources: []string{"nodes"},
+					Verbs:     []string{"get", "list", "watch"},
+				}},
+			},
+			componentRBAC{serviceAccount: registryProxyDNSServiceAccountName})
+	}
+
+	// spire-server validates the agent tokens, publishes its bundle and
+	// registers the pods, spire-agent attests the workloads of its node
+	if nsm.Spec.Spire.Enabled {
+		components = append(components,
+			componentRBAC{
+				serviceAccount: spireServerServiceAccountName,
+				rules: []rbacv1.PolicyRule{{
+					APIGroups:     []string{""},
+					Resources:     []string{"configmaps"},
+					ResourceNames: []string{spireBundleName},
+					Verbs:         []string{"get", "patch"},
+				}},
+				clusterRules: []rbacv1.PolicyRule{
+					{
+						APIGroups: []string{"authentication.k8s.io"},
+						Resources: []string{"tokenreviews"},
+						Verbs:     []string{"create"},
+					},
+					{
+						APIGroups: []string{""},
+						Resources: []string{"pods", "nodes"},
+						Verbs:     []string{"get", "list", "watch"},
+					},
+				},
+			},
+			componentRBAC{
+				serviceAccount: spireAgentServiceAccountName,
+				clusterRules: []rbacv1.PolicyRule{{
+					APIGroups: []string{""},
+					Resources: []string{"pods", "nodes", "nodes/proxy"},
+					Verbs:     []string{"get"},
+				}},
+			})
+	}
+	return components
+}
+
+// Get the service accounts the NSM components run with
+func getComponentServiceAccounts(nsm *nsmv1alpha1.NSM) []string {
+	serviceAccounts := []string{}
+	for _, component := range getComponentRBAC(nsm) {
+		serviceAccounts = append(serviceAccounts, component.serviceAccount)
+	}
+	return serviceAccounts
+}
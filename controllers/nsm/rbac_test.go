@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetComponentRBAC(t *testing.T) {
+	nsm := &nsmv1alpha1.NSM{}
+	var nsmgr *componentRBAC
+	components := getComponentRBAC(nsm)
+	for i := range components {
+		if components[i].serviceAccount == nsmgrServiceAccountName {
+			nsmgr = &components[i]
+		}
+	}
+	if nsmgr == nil {
+		t.Fatalf("getComponentRBAC() has no %s", nsmgrServiceAccountName)
+	}
+	// exclude-prefixes-k8s watches the cluster CIDRs from the nsmgr pod
+	for _, resource := range []string{"configmaps", "pods", "services", "nodes"} {
+		if !containsString(nsmgr.clusterRules[0].Resources, resource) {
+			t.Errorf("%s can't watch %s", nsmgrServiceAccountName, resource)
+		}
+	}
+}
+
+func TestRBACReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	nsm.Spec.Registry.Type = "k8s"
+	nsm.Spec.Webhook.Image = "webhook"
+	nsm.Spec.Interdomain.Enabled = true
+
+	// Bindings edited by hand since they were created
+	staleSubjects := []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "default", Namespace: "nsm"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&rbacv1.ClusterRoleBinding{ObjectMeta: newObjectMeta("nsm-"+nsmgrServiceAccountName, "", getOwnerLabels(nsm)), Subjects: staleSubjects},
+		// Cluster role of another NSM CR
+		&rbacv1.ClusterRole{ObjectMeta: newObjectMeta("nsm-"+spireAgentServiceAccountName, "", map[string]string{ownerLabel: "nsm.other"})},
+	).Build()
+	r := NewRBACReconciler(c, logr.Discard(), scheme)
+
+	exists := func(obj client.Object, name, namespace string) bool {
+		err := c.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, obj)
+		if err != nil && !apierrors.IsNotFound(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+
+	if err := r.Reconcile(context.TODO(), nsm); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	binding := &rbacv1.ClusterRoleBinding{}
+	if !exists(binding, "nsm-"+nsmgrServiceAccountName, "") {
+		t.Fatalf("cluster role binding of %s missing", nsmgrServiceAccountName)
+	}
+	if binding.Subjects[0].Name != nsmgrServiceAccountName {
+		t.Errorf("cluster role binding subject = %s, want %s", binding.Subjects[0].Name, nsmgrServiceAccountName)
+	}
+	for _, sa := range []string{registryServiceAccountName, webhookServiceAccountName, nsmgrProxyServiceAccountName} {
+		if !exists(&corev1.ServiceAccount{}, sa, "nsm") {
+			t.Errorf("service account %s missing", sa)
+		}
+	}
+	if !exists(&rbacv1.Role{}, registryServiceAccountName, "nsm") {
+		t.Errorf("role of %s missing", registryServiceAccountName)
+	}
+
+	// Disable the components
+	nsm.Spec.Registry.Type = "memory"
+	nsm.Spec.Webhook.Image = ""
+	nsm.Spec.Interdomain.Enabled = false
+	if err := r.Reconcile(context.TODO(), nsm); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	for _, sa := range []string{webhookServiceAccountName, nsmgrProxyServiceAccountName, registryProxyDNSServiceAccountName} {
+		if exists(&corev1.ServiceAccount{}, sa, "nsm") {
+			t.Errorf("service account %s of a disabled component not deleted", sa)
+		}
+		if exists(&rbacv1.ClusterRole{}, "nsm-"+sa, "") || exists(&rbacv1.ClusterRoleBinding{}, "nsm-"+sa, "") {
+			t.Errorf("cluster role of %s not deleted", sa)
+		}
+	}
+	if !exists(&corev1.ServiceAccount{}, registryServiceAccountName, "nsm") {
+		t.Errorf("service account %s deleted", registryServiceAccountName)
+	}
+	if exists(&rbacv1.Role{}, registryServiceAccountName, "nsm") || exists(&rbacv1.RoleBinding{}, registryServiceAccountName, "nsm") {
+		t.Errorf("role of %s not deleted with the memory registry", registryServiceAccountName)
+	}
+	if !exists(&rbacv1.ClusterRole{}, "nsm-"+spireAgentServiceAccountName, "") {
+		t.Errorf("cluster role of another owner deleted")
+	}
+}
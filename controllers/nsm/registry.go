@@ -2,16 +2,15 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/go-logr/logr"
 	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -31,33 +30,8 @@ func NewRegistryReconciler(client client.Client, log logr.Logger, scheme *runtim
 }
 
 func (r *RegistryReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
-
-	deploy := &appsv1.Deployment{}
-	err := r.Client.Get(ctx, types.NamespacedName{Name: "nsm-registry", Namespace: nsm.ObjectMeta.Namespace}, deploy)
-	if err != nil {
-		if apierrors.IsNotFound(err) {
-			deploy = r.DeploymentForRegistry(nsm)
-			err = r.Client.Create(context.TODO(), deploy)
-			if err != nil {
-				r.Log.Error(err, "failed to create deployment for nsm-registry")
-				return err
-			}
-			r.Log.Info("nsm registry deployment created")
-			return nil
-		}
-		return err
-	} else if nsm.Spec.Registry.ReplicaCount != *deploy.Spec.Replicas {
-		deploy.Spec.Replicas = &nsm.Spec.Registry.ReplicaCount
-		err = r.Update(ctx, deploy)
-		if err != nil {
-			r.Log.Error(err, "failed to update the replica count of nsm registry deployment")
-			return err
-		}
-		r.Log.Info("nsm registry deployment updated")
-		return nil
-	}
-	r.Log.Info("nsm registry deployment already exists, skipping creation")
-	return nil
+	_, err := reconcileComponentDeployment(ctx, r.Client, r.Log, r.DeploymentForRegistry(nsm))
+	return err
 }
 
 func (r *RegistryReconciler) DeploymentForRegistry(nsm *nsmv1alpha1.NSM) *appsv1.Deployment {
@@ -65,7 +39,6 @@ func (r *RegistryReconciler) DeploymentForRegistry(nsm *nsmv1alpha1.NSM) *appsv1
 	objectMeta := newObjectMeta("nsm-registry", "nsm", map[string]string{"app": "nsm"})
 
 	registryLabel := map[string]string{"app": "nsm-registry", "spiffe.io/spiffe-id": "true"}
-	volTypeDirectory := corev1.HostPathDirectory
 
 	deploy := &appsv1.Deployment{
 		ObjectMeta: objectMeta,
@@ -76,10 +49,12 @@ func (r *RegistryReconciler) DeploymentForRegistry(nsm *nsmv1alpha1.NSM) *appsv1
 			Replicas: getReplicas(nsm),
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: registryLabel,
+					Labels:      registryLabel,
+					Annotations: mergeAnnotations(getAppArmorAnnotations(nsm.Spec.Registry.PodSecurity, "nsm-registry"), getFederationAnnotations(nsm)),
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName: serviceAccountName,
+					ServiceAccountName: registryServiceAccountName,
+					SecurityContext:    getPodSecurityContext(nsm.Spec.Registry.PodSecurity, true),
 					Containers: []corev1.Container{{
 						Name:            "nsm-registry",
 						Image:           nsm.Spec.Registry.Image,
@@ -90,7 +65,7 @@ func (r *RegistryReconciler) DeploymentForRegistry(nsm *nsmv1alpha1.NSM) *appsv1
 							HostPort:      5002}},
 						VolumeMounts: []corev1.VolumeMount{
 							{Name: "spire-agent-socket",
-								MountPath: "/run/spire/sockets",
+								MountPath: getSpireAgentSocketDir(nsm),
 							}},
 						Resources: corev1.ResourceRequirements{
 							Limits: corev1.ResourceList{
@@ -102,18 +77,15 @@ func (r *RegistryReconciler) DeploymentForRegistry(nsm *nsmv1alpha1.NSM) *appsv1
 							},
 						},
 					}},
-					Volumes: []corev1.Volume{{
-						Name: "spire-agent-socket",
-						VolumeSource: corev1.VolumeSource{
-							HostPath: &corev1.HostPathVolumeSource{
-								Path: "/run/spire/sockets",
-								Type: &volTypeDirectory,
-							}}},
+					Volumes: []corev1.Volume{
+						getSpireAgentSocketVolume(nsm),
 					},
 				},
 			},
 		},
 	}
+	setConfigHash(&deploy.Spec.Template)
+
 	// Set NSM instance as the owner and controller
 	controllerutil.SetControllerReference(nsm, deploy, r.Scheme)
 	return deploy
@@ -136,12 +108,12 @@ func getEnvVar(nsm *nsmv1alpha1.NSM) []corev1.EnvVar {
 			prefix = "REGISTRY_MEMORY_"
 		case "k8s":
 			// From version 1.7.0 the prefix of the environment variables changed to NSM, instead of REGISTRY_K8S
-			if nsm.Spec.Version < "v1.7.0" {
+			if compareVersions(nsm.Spec.Version, "v1.7.0") < 0 {
 				prefix = "REGISTRY_K8S_"
 			}
 		}
 		envVars = []corev1.EnvVar{{Name: prefix + "LISTEN_ON", Value: "tcp://:5002"},
-			{Name: prefix + "PROXY_REGISTRY_URL", Value: "nsmgr-proxy:5004"},
+			{Name: prefix + "PROXY_REGISTRY_URL", Value: fmt.Sprintf("%s:%d", nsmgrProxyName, nsmgrProxyPort)},
 			{Name: prefix + "LOG_LEVEL", Value: getNsmLogLevel(nsm)},
 			{Name: prefix + "NAMESPACE", ValueFrom: &corev1.EnvVarSource{
 				FieldRef: &corev1.ObjectFieldSelector{
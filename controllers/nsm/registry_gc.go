@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Time an expired registration is kept unless set in the CR
+const registryGCGracePeriod time.Duration = time.Minute
+
+// Event reason of a deleted registration
+const staleEndpointDeletedReason string = "StaleEndpointDeleted"
+
+var staleEndpointsDeleted = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nsm_operator_stale_endpoints_deleted_total",
+		Help: "Number of expired NetworkServiceEndpoint registrations deleted by the operator",
+	},
+	[]string{"namespace", "nsm"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(staleEndpointsDeleted)
+}
+
+// RegistryGCReconciler deletes the NetworkServiceEndpoints whose registration
+// expired, registry-k8s leaves the ones of crashed endpoints and forwarders behind
+type RegistryGCReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// UIDs of the registrations deleted, shared with the status reconciler
+	Deleted map[types.UID]bool
+}
+
+func NewRegistryGCReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme, recorder record.EventRecorder, deleted map[types.UID]bool) *RegistryGCReconciler {
+	return &RegistryGCReconciler{
+		Client:   client,
+		Log:      log,
+		Scheme:   scheme,
+		Recorder: recorder,
+		Deleted:  deleted,
+	}
+}
+
+func (r *RegistryGCReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	nses := &unstructured.UnstructuredList{}
+	nses.SetGroupVersionKind(networkServiceEndpointGVK)
+	if err := r.Client.List(ctx, nses, client.InNamespace(nsm.ObjectMeta.Namespace)); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil
+		}
+		return err
+	}
+
+	deadline := time.Now().Add(-getRegistryGCGracePeriod(nsm))
+	for i := range nses.Items {
+		nse := &nses.Items[i]
+		expiration, found := getRegistrationExpiration(nse)
+		if !found || !expiration.Before(deadline) {
+			continue
+		}
+		// The endpoint may refresh its registration meanwhile
+		uid := nse.GetUID()
+		resourceVersion := nse.GetResourceVersion()
+		err := r.Client.Delete(ctx, nse, client.Preconditions{UID: &uid, ResourceVersion: &resourceVersion})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				r.Deleted[uid] = true
+				continue
+			}
+			if apierrors.IsConflict(err) {
+				continue
+			}
+			r.Log.Error(err, "failed to delete expired network service endpoint "+nse.GetName())
+			return err
+		}
+		r.Deleted[uid] = true
+		r.Log.Info("expired network service endpoint "+nse.GetName()+" deleted", "expiration", expiration)
+		r.Recorder.Eventf(nsm, corev1.EventTypeNormal, staleEndpointDeletedReason,
+			"Deleted NetworkServiceEndpoint %s, its registration expired at %s", nse.GetName(), expiration.UTC().Format(time.RFC3339))
+		staleEndpointsDeleted.WithLabelValues(nsm.ObjectMeta.Namespace, nsm.ObjectMeta.Name).Inc()
+	}
+	return nil
+}
+
+func getRegistryGCGracePeriod(nsm *nsmv1alpha1.NSM) time.Duration {
+	if nsm.Spec.Registry.GarbageCollection.GracePeriod != nil {
+		return nsm.Spec.Registry.GarbageCollection.GracePeriod.Duration
+	}
+	return registryGCGracePeriod
+}
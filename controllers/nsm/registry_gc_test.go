@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRegistryGC(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(networkServiceEndpointGVK, meta.RESTScopeNamespace)
+	mapper.Add(networkServiceGVK, meta.RESTScopeNamespace)
+
+	now := time.Now()
+	gracePeriod := 5 * time.Minute
+	tests := []struct {
+		name       string
+		expiration interface{}
+		deleted    bool
+	}{
+		{name: "live", expiration: now.Add(time.Minute).Unix()},
+		{name: "expired within the grace period", expiration: now.Add(-time.Minute).Unix()},
+		{name: "expired past the grace period", expiration: now.Add(-gracePeriod - time.Minute).Unix(), deleted: true},
+		{name: "never expires"},
+	}
+
+	objects := []client.Object{}
+	for i, tt := range tests {
+		nse := newRegistration(tt.expiration)
+		nse.SetGroupVersionKind(networkServiceEndpointGVK)
+		nse.SetName(tt.name)
+		nse.SetNamespace("nsm")
+		nse.SetUID(types.UID(rune('a' + i)))
+		objects = append(objects, nse)
+	}
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+	nsm.Spec.Registry.GarbageCollection.GracePeriod = &metav1.Duration{Duration: gracePeriod}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(objects...).Build()
+
+	deleted := map[types.UID]bool{}
+	gc := NewRegistryGCReconciler(c, logr.Discard(), scheme, record.NewFakeRecorder(10), deleted)
+	if err := gc.Reconcile(context.TODO(), nsm); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	for _, tt := range tests {
+		nse := &unstructured.Unstructured{}
+		nse.SetGroupVersionKind(networkServiceEndpointGVK)
+		err := c.Get(context.TODO(), types.NamespacedName{Name: tt.name, Namespace: "nsm"}, nse)
+		if got := apierrors.IsNotFound(err); got != tt.deleted {
+			t.Errorf("%s: deleted = %v, want %v (error %v)", tt.name, got, tt.deleted, err)
+		}
+	}
+
+	// The status is computed from a cache still holding the deleted
+	// registration, it's left out of the report anyway
+	stale := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(objects...).Build()
+	status := NewRegistryStatusReconciler(stale, logr.Discard(), scheme, deleted)
+	if err := status.Reconcile(context.TODO(), nsm); err != nil {
+		t.Fatalf("status Reconcile() error = %v", err)
+	}
+	if nsm.Status.Registry.Endpoints != 3 || nsm.Status.Registry.ExpiredEndpoints != 1 {
+		t.Errorf("registry status = %d endpoints, %d expired, want 3 and 1",
+			nsm.Status.Registry.Endpoints, nsm.Status.Registry.ExpiredEndpoints)
+	}
+}
@@ -45,6 +45,20 @@ func (r *RegistryServiceReconciler) Reconcile(ctx context.Context, nsm *nsmv1alp
 		}
 		return err
 	}
+
+	// The registry is published in the domain of the NSM instance
+	// for the registry-proxy-dns of the other domains
+	hostname := getInterdomainHostname(nsm, "registry")
+	if svc.Annotations[externalDNSHostnameAnnotation] != hostname {
+		svc.Annotations = setExternalDNSHostname(svc.Annotations, hostname)
+		err = r.Client.Update(ctx, svc)
+		if err != nil {
+			r.Log.Error(err, "failed to update service for nsm-registry")
+			return err
+		}
+		r.Log.Info("nsm registry service updated")
+		return nil
+	}
 	r.Log.Info("nsm registry service already exists, skipping creation")
 	return nil
 }
@@ -55,6 +69,8 @@ func (r *RegistryServiceReconciler) serviceForNsmRegistry(nsm *nsmv1alpha1.NSM)
 
 	objectMeta := newObjectMeta("nsm-registry-svc", "nsm", map[string]string{"app": "nsm"})
 
+	objectMeta.Annotations = setExternalDNSHostname(nil, getInterdomainHostname(nsm, "registry"))
+
 	service := &corev1.Service{
 		ObjectMeta: objectMeta,
 		Spec: corev1.ServiceSpec{
@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// RegistryStatusReconciler summarises the registrations stored by
+// registry-k8s in the NSM status
+type RegistryStatusReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// UIDs of the registrations just deleted by the garbage collection,
+	// the cache may still hold them
+	Deleted map[types.UID]bool
+}
+
+func NewRegistryStatusReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme, deleted map[types.UID]bool) *RegistryStatusReconciler {
+	return &RegistryStatusReconciler{
+		Client:  client,
+		Log:     log,
+		Scheme:  scheme,
+		Deleted: deleted,
+	}
+}
+
+func (r *RegistryStatusReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	nses := &unstructured.UnstructuredList{}
+	nses.SetGroupVersionKind(networkServiceEndpointGVK)
+	if err := r.Client.List(ctx, nses, client.InNamespace(nsm.ObjectMeta.Namespace)); err != nil {
+		if meta.IsNoMatchError(err) {
+			r.Log.Info("NetworkServiceEndpoint CRD not installed, skipping the registry status")
+			return nil
+		}
+		return err
+	}
+	services := &unstructured.UnstructuredList{}
+	services.SetGroupVersionKind(networkServiceGVK)
+	if err := r.Client.List(ctx, services, client.InNamespace(nsm.ObjectMeta.Namespace)); err != nil {
+		if meta.IsNoMatchError(err) {
+			r.Log.Info("NetworkService CRD not installed, skipping the registry status")
+			return nil
+		}
+		return err
+	}
+
+	live := nses.Items[:0]
+	for _, nse := range nses.Items {
+		if !r.Deleted[nse.GetUID()] {
+			live = append(live, nse)
+		}
+	}
+	nses.Items = live
+
+	status := &nsmv1alpha1.RegistryStatus{
+		NetworkServices: int32(len(services.Items)),
+		Endpoints:       int32(len(nses.Items)),
+	}
+	counts := map[string]*nsmv1alpha1.RegisteredServiceStatus{}
+	for _, service := range services.Items {
+		counts[service.GetName()] = &nsmv1alpha1.RegisteredServiceStatus{Name: service.GetName()}
+	}
+	registered := map[string]bool{}
+	now := time.Now()
+	for _, nse := range nses.Items {
+		expired := isRegistrationExpired(&nse, now)
+		if expired {
+			status.ExpiredEndpoints++
+		} else {
+			registered[getRegisteredName(&nse)] = true
+		}
+		names, _, _ := unstructured.NestedStringSlice(nse.Object, "spec", "network_service_names")
+		for _, name := range names {
+			// Endpoints may register for a service before the service itself
+			if counts[name] == nil {
+				counts[name] = &nsmv1alpha1.RegisteredServiceStatus{Name: name}
+			}
+			counts[name].Endpoints++
+			if expired {
+				counts[name].ExpiredEndpoints++
+			}
+		}
+	}
+	for _, count := range counts {
+		status.Services = append(status.Services, *count)
+	}
+	sort.Slice(status.Services, func(i, j int) bool {
+		return status.Services[i].Name < status.Services[j].Name
+	})
+
+	// Forwarders register themselves under their pod name, a forwarder
+	// without a live registration can't be selected by nsmgr
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(nsm.ObjectMeta.Namespace),
+		client.MatchingLabels{"app": "forwarder"}); err != nil {
+		return err
+	}
+	for _, pod := range pods.Items {
+		if pod.ObjectMeta.DeletionTimestamp != nil || registered[pod.Name] {
+			continue
+		}
+		status.UnregisteredForwarders = append(status.UnregisteredForwarders, pod.Name)
+	}
+	sort.Strings(status.UnregisteredForwarders)
+
+	nsm.Status.Registry = status
+	meta.SetStatusCondition(&nsm.Status.Conditions, getForwardersRegisteredCondition(status.UnregisteredForwarders))
+	return nil
+}
+
+// Get the name an endpoint registered with, registry-k8s names the
+// objects after the endpoints unless the names are not valid object names
+func getRegisteredName(nse *unstructured.Unstructured) string {
+	name, _, _ := unstructured.NestedString(nse.Object, "spec", "name")
+	if name == "" {
+		return nse.GetName()
+	}
+	return name
+}
+
+// Registrations without an expiration time never expire
+func isRegistrationExpired(nse *unstructured.Unstructured, now time.Time) bool {
+	expiration, found := getRegistrationExpiration(nse)
+	return found && expiration.Before(now)
+}
+
+// The expiration time is the JSON encoding of a protobuf timestamp
+func getRegistrationExpiration(nse *unstructured.Unstructured) (time.Time, bool) {
+	seconds, found, _ := unstructured.NestedFieldNoCopy(nse.Object, "spec", "expiration_time", "seconds")
+	if !found {
+		return time.Time{}, false
+	}
+	switch seconds := seconds.(type) {
+	case int64:
+		return time.Unix(seconds, 0), true
+	case float64:
+		return time.Unix(int64(seconds), 0), true
+	}
+	return time.Time{}, false
+}
+
+// Get the ForwardersRegistered condition of the NSM status
+func getForwardersRegisteredCondition(unregistered []string) metav1.Condition {
+	if len(unregistered) > 0 {
+		return metav1.Condition{
+			Type:    nsmv1alpha1.NSMConditionForwardersRegistered,
+			Status:  metav1.ConditionFalse,
+			Reason:  nsmv1alpha1.NSMReasonForwardersUnregistered,
+			Message: "forwarders not registered: " + strings.Join(unregistered, ", "),
+		}
+	}
+	return metav1.Condition{
+		Type:    nsmv1alpha1.NSMConditionForwardersRegistered,
+		Status:  metav1.ConditionTrue,
+		Reason:  nsmv1alpha1.NSMReasonForwardersRegistered,
+		Message: "all the forwarders are registered",
+	}
+}
+
+// Start watching the registrations once their CRDs are served, the
+// operator may install them long after the controller has started.
+// Reconciles run one at a time so the watches are started only once
+func (r *NSMReconciler) watchRegistry() error {
+
+	// The NSM instances of fleet members are polled from the hub
+	if r.controller == nil {
+		return nil
+	}
+
+	for _, gvk := range []schema.GroupVersionKind{networkServiceGVK, networkServiceEndpointGVK} {
+		if r.registryWatches[gvk] {
+			continue
+		}
+		if _, err := r.Client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			if meta.IsNoMatchError(err) {
+				continue
+			}
+			return err
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		err := r.controller.Watch(&source.Kind{Type: obj},
+			handler.EnqueueRequestsFromMapFunc(r.nsmsForRegistration), registrationChanged())
+		if err != nil {
+			return err
+		}
+		if r.registryWatches == nil {
+			r.registryWatches = map[schema.GroupVersionKind]bool{}
+		}
+		r.registryWatches[gvk] = true
+	}
+	return nil
+}
+
+// Get the NSM instances with the k8s registry in the namespace of a registration
+func (r *NSMReconciler) nsmsForRegistration(obj client.Object) []reconcile.Request {
+	nsms := &nsmv1alpha1.NSMList{}
+	if err := r.Client.List(context.Background(), nsms, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, nsm := range nsms.Items {
+		if nsm.Spec.Registry.Type == "k8s" {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: nsm.Name, Namespace: nsm.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// Endpoints refresh their registrations well before they expire, a
+// refresh alone doesn't change the registry status unless it revives an
+// expired registration. Registrations expiring raise no event at all,
+// they are caught by the networkServiceCheckInterval requeue.
+func registrationChanged() predicate.Funcs {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldObj, okOld := e.ObjectOld.(*unstructured.Unstructured)
+			newObj, okNew := e.ObjectNew.(*unstructured.Unstructured)
+			if !okOld || !okNew {
+				return true
+			}
+			now := time.Now()
+			if isRegistrationExpired(oldObj, now) != isRegistrationExpired(newObj, now) {
+				return true
+			}
+			oldSpec, _, _ := unstructured.NestedMap(oldObj.Object, "spec")
+			newSpec, _, _ := unstructured.NestedMap(newObj.Object, "spec")
+			delete(oldSpec, "expiration_time")
+			delete(newSpec, "expiration_time")
+			return !equality.Semantic.DeepEqual(oldSpec, newSpec)
+		},
+	}
+}
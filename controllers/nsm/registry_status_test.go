@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// Build a registration the way registry-k8s stores it
+func newRegistration(expiration interface{}) *unstructured.Unstructured {
+	spec := map[string]interface{}{"name": "nse-1"}
+	if expiration != nil {
+		spec["expiration_time"] = map[string]interface{}{"seconds": expiration}
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+}
+
+func TestGetRegistrationExpiration(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiration interface{}
+		want       time.Time
+		found      bool
+	}{
+		{name: "int64 seconds", expiration: int64(1700000000), want: time.Unix(1700000000, 0), found: true},
+		{name: "float64 seconds", expiration: float64(1700000000), want: time.Unix(1700000000, 0), found: true},
+		{name: "no expiration", expiration: nil},
+		{name: "unexpected type", expiration: "1700000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := getRegistrationExpiration(newRegistration(tt.expiration))
+			if found != tt.found || !got.Equal(tt.want) {
+				t.Errorf("getRegistrationExpiration() = %v, %v, want %v, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestIsRegistrationExpired(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name       string
+		expiration interface{}
+		want       bool
+	}{
+		{name: "expired", expiration: now.Add(-time.Second).Unix(), want: true},
+		{name: "expiring now", expiration: now.Unix(), want: false},
+		{name: "live", expiration: now.Add(time.Minute).Unix(), want: false},
+		{name: "never expires", expiration: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRegistrationExpired(newRegistration(tt.expiration), now); got != tt.want {
+				t.Errorf("isRegistrationExpired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetForwardersRegisteredCondition(t *testing.T) {
+	tests := []struct {
+		name         string
+		unregistered []string
+		wantStatus   metav1.ConditionStatus
+		wantMessage  string
+	}{
+		{name: "all registered", wantStatus: metav1.ConditionTrue, wantMessage: "all the forwarders are registered"},
+		{
+			name:         "unregistered forwarders",
+			unregistered: []string{"forwarder-vpp-a", "forwarder-vpp-b"},
+			wantStatus:   metav1.ConditionFalse,
+			wantMessage:  "forwarders not registered: forwarder-vpp-a, forwarder-vpp-b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getForwardersRegisteredCondition(tt.unregistered)
+			if got.Status != tt.wantStatus || got.Message != tt.wantMessage {
+				t.Errorf("getForwardersRegisteredCondition() = %s %q, want %s %q", got.Status, got.Message, tt.wantStatus, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestRegistrationChanged(t *testing.T) {
+	now := time.Now()
+	withURL := func(nse *unstructured.Unstructured, url string) *unstructured.Unstructured {
+		_ = unstructured.SetNestedField(nse.Object, url, "spec", "url")
+		return nse
+	}
+	tests := []struct {
+		name   string
+		oldObj *unstructured.Unstructured
+		newObj *unstructured.Unstructured
+		want   bool
+	}{
+		{
+			name:   "refresh of a live registration",
+			oldObj: newRegistration(now.Add(time.Minute).Unix()),
+			newObj: newRegistration(now.Add(2 * time.Minute).Unix()),
+			want:   false,
+		},
+		{
+			name:   "refresh of an expired registration",
+			oldObj: newRegistration(now.Add(-time.Minute).Unix()),
+			newObj: newRegistration(now.Add(time.Minute).Unix()),
+			want:   true,
+		},
+		{
+			name:   "spec change",
+			oldObj: withURL(newRegistration(now.Add(time.Minute).Unix()), "tcp://10.0.0.1:5001"),
+			newObj: withURL(newRegistration(now.Add(time.Minute).Unix()), "tcp://10.0.0.2:5001"),
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := registrationChanged().Update(event.UpdateEvent{ObjectOld: tt.oldObj, ObjectNew: tt.newObj}); got != tt.want {
+				t.Errorf("registrationChanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRegistryRolling(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	nsm.Spec.Registry = nsmv1alpha1.Registry{Type: "k8s", Image: "cmd-registry-k8s:v1.6.1"}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	tests := []struct {
+		name     string
+		update   func(nsm *nsmv1alpha1.NSM)
+		image    string
+		hostPath string
+		replicas int32
+	}{
+		{"created", func(nsm *nsmv1alpha1.NSM) {}, "cmd-registry-k8s:v1.6.1", spireAgentSocketDir, 1},
+		{"host path", func(nsm *nsmv1alpha1.NSM) { nsm.Spec.HostPaths.SpireAgentSocket = "/run/spire/agent" }, "cmd-registry-k8s:v1.6.1", "/run/spire/agent", 1},
+		{"version", func(nsm *nsmv1alpha1.NSM) { nsm.Spec.Registry.Image = "cmd-registry-k8s:v1.7.0" }, "cmd-registry-k8s:v1.7.0", "/run/spire/agent", 1},
+		{"replicas", func(nsm *nsmv1alpha1.NSM) { nsm.Spec.Registry.ReplicaCount = 3 }, "cmd-registry-k8s:v1.7.0", "/run/spire/agent", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.update(nsm)
+			if err := NewRegistryReconciler(c, logr.Discard(), scheme).Reconcile(context.TODO(), nsm); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			deploy := &appsv1.Deployment{}
+			if err := c.Get(context.TODO(), types.NamespacedName{Name: "nsm-registry", Namespace: "nsm"}, deploy); err != nil {
+				t.Fatal(err)
+			}
+			if image := deploy.Spec.Template.Spec.Containers[0].Image; image != tt.image {
+				t.Errorf("image = %s, want %s", image, tt.image)
+			}
+			if path := deploy.Spec.Template.Spec.Volumes[0].HostPath.Path; path != tt.hostPath {
+				t.Errorf("SPIRE agent socket host path = %s, want %s", path, tt.hostPath)
+			}
+			if *deploy.Spec.Replicas != tt.replicas {
+				t.Errorf("replicas = %d, want %d", *deploy.Spec.Replicas, tt.replicas)
+			}
+		})
+	}
+}
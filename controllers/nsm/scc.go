@@ -0,0 +1,351 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	privilegedSCC      string = "privileged"
+	sccRoleName        string = "nsm-scc-privileged"
+	sccRoleBindingName string = "nsm-scc-privileged"
+	// How long the SCC access of a client service account is trusted
+	// before it's reviewed again, the grants rarely change
+	sccReviewTTL time.Duration = 10 * time.Minute
+	// Index of the workloads by the annotations of their pod template
+	templateAnnotationsIndex string = "spec.template.metadata.annotations"
+)
+
+// SCCReconciler grants the OpenShift privileged SecurityContextConstraints
+// to the NSM components and checks that NSM clients are allowed to use it.
+// On the other platforms it removes the grant left by a previous detection.
+type SCCReconciler struct {
+	client.Client
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	APIReader client.Reader
+	// Cluster wide cache indexing the workloads by templateAnnotationsIndex,
+	// nil for the members of a fleet which are read through APIReader
+	ClusterCache client.Reader
+	// Whether the platform has SecurityContextConstraints
+	Enabled bool
+	// Reviews of the client service accounts kept across reconciles
+	Reviews *sccReviewCache
+}
+
+func NewSCCReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme, apiReader client.Reader, clusterCache client.Reader, enabled bool, reviews *sccReviewCache) *SCCReconciler {
+	return &SCCReconciler{
+		Client:       client,
+		Log:          log,
+		Scheme:       scheme,
+		APIReader:    apiReader,
+		ClusterCache: clusterCache,
+		Enabled:      enabled,
+		Reviews:      reviews,
+	}
+}
+
+// sccReviewCache keeps the outcome of the SubjectAccessReviews of the
+// client service accounts across the reconciles of the NSM instances
+type sccReviewCache struct {
+	mu      sync.Mutex
+	reviews map[types.NamespacedName]sccReview
+}
+
+type sccReview struct {
+	allowed bool
+	expires time.Time
+}
+
+func (c *sccReviewCache) get(sa types.NamespacedName, now time.Time) (bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	review, ok := c.reviews[sa]
+	if !ok || now.After(review.expires) {
+		return false, false
+	}
+	return review.allowed, true
+}
+
+func (c *sccReviewCache) set(sa types.NamespacedName, allowed bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.reviews == nil {
+		c.reviews = map[types.NamespacedName]sccReview{}
+	}
+	// Drop the expired reviews of the service accounts gone since
+	for name, review := range c.reviews {
+		if now.After(review.expires) {
+			delete(c.reviews, name)
+		}
+	}
+	c.reviews[sa] = sccReview{allowed: allowed, expires: now.Add(sccReviewTTL)}
+}
+
+func (r *SCCReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	if !r.Enabled {
+		nsm.Status.ClientNamespacesWithoutSCC = nil
+		return deleteComponentObjects(ctx, r.Client, r.Log, nsm,
+			&rbacv1.RoleBinding{ObjectMeta: newObjectMeta(sccRoleBindingName, nsm.ObjectMeta.Namespace, nil)},
+			&rbacv1.Role{ObjectMeta: newObjectMeta(sccRoleName, nsm.ObjectMeta.Namespace, nil)})
+	}
+
+	role := &rbacv1.Role{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: sccRoleName, Namespace: nsm.ObjectMeta.Namespace}, role)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		role = r.roleForSCC(nsm)
+		err = r.Client.Create(ctx, role)
+		if err != nil {
+			r.Log.Error(err, "failed to create role for privileged scc")
+			return err
+		}
+		r.Log.Info("privileged scc role created")
+	}
+
+	roleBinding := &rbacv1.RoleBinding{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: sccRoleBindingName, Namespace: nsm.ObjectMeta.Namespace}, roleBinding)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		roleBinding = r.roleBindingForSCC(nsm)
+		err = r.Client.Create(ctx, roleBinding)
+		if err != nil {
+			r.Log.Error(err, "failed to create role binding for privileged scc")
+			return err
+		}
+		r.Log.Info("privileged scc role binding created")
+	} else if subjects := r.roleBindingForSCC(nsm).Subjects; !equality.Semantic.DeepEqual(subjects, roleBinding.Subjects) {
+		roleBinding.Subjects = subjects
+		err = r.Client.Update(ctx, roleBinding)
+		if err != nil {
+			r.Log.Error(err, "failed to update role binding for privileged scc")
+			return err
+		}
+		r.Log.Info("privileged scc role binding updated")
+	}
+
+	// The clients only get cmd-nsc from the webhook
+	if nsm.Spec.Webhook.Image == "" {
+		nsm.Status.ClientNamespacesWithoutSCC = nil
+		return nil
+	}
+	namespaces, err := r.clientNamespacesWithoutSCC(ctx, nsm)
+	if err != nil {
+		r.Log.Error(err, "failed to check the scc of nsm client namespaces")
+		return err
+	}
+	nsm.Status.ClientNamespacesWithoutSCC = namespaces
+	return nil
+}
+
+func (r *SCCReconciler) roleForSCC(nsm *nsmv1alpha1.NSM) *rbacv1.Role {
+
+	role := &rbacv1.Role{
+		ObjectMeta: newObjectMeta(sccRoleName, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"}),
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups:     []string{"security.openshift.io"},
+			Resources:     []string{"securitycontextconstraints"},
+			ResourceNames: []string{privilegedSCC},
+			Verbs:         []string{"use"},
+		}},
+	}
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, role, r.Scheme)
+	return role
+}
+
+func (r *SCCReconciler) roleBindingForSCC(nsm *nsmv1alpha1.NSM) *rbacv1.RoleBinding {
+
+	subjects := []rbacv1.Subject{}
+	for _, sa := range getComponentServiceAccounts(nsm) {
+		subjects = append(subjects, rbacv1.Subject{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      sa,
+			Namespace: nsm.ObjectMeta.Namespace,
+		})
+	}
+
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: newObjectMeta(sccRoleBindingName, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"}),
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     sccRoleName,
+		},
+		Subjects: subjects,
+	}
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, roleBinding, r.Scheme)
+	return roleBinding
+}
+
+// Find the namespaces with workloads requesting NSM client injection whose
+// service accounts are not allowed to use the privileged SCC needed by cmd-nsc.
+// The reviews are cached for sccReviewTTL.
+func (r *SCCReconciler) clientNamespacesWithoutSCC(ctx context.Context, nsm *nsmv1alpha1.NSM) ([]string, error) {
+
+	clients, err := r.listClientServiceAccounts(ctx, nsm)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := map[string]bool{}
+	now := time.Now()
+	for sa := range clients {
+		if allowed, ok := r.Reviews.get(sa, now); ok {
+			if !allowed {
+				missing[sa.Namespace] = true
+			}
+			continue
+		}
+		review := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   "system:serviceaccount:" + sa.Namespace + ":" + sa.Name,
+				Groups: []string{"system:serviceaccounts", "system:serviceaccounts:" + sa.Namespace, "system:authenticated"},
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: sa.Namespace,
+					Group:     "security.openshift.io",
+					Resource:  "securitycontextconstraints",
+					Name:      privilegedSCC,
+					Verb:      "use",
+				},
+			},
+		}
+		if err := r.Client.Create(ctx, review); err != nil {
+			return nil, err
+		}
+		r.Reviews.set(sa, review.Status.Allowed, now)
+		if !review.Status.Allowed {
+			missing[sa.Namespace] = true
+		}
+	}
+
+	var namespaces []string
+	for ns := range missing {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// List the service accounts of the workloads annotated for NSM client
+// injection, in the namespaces whose pods are sent to the webhook
+func (r *SCCReconciler) listClientServiceAccounts(ctx context.Context, nsm *nsmv1alpha1.NSM) (map[types.NamespacedName]bool, error) {
+
+	reader := r.getClientReader()
+	namespaces, err := r.listInjectedNamespaces(ctx, reader, nsm)
+	if err != nil {
+		return nil, err
+	}
+	annotation := getWebhookAnnotation(nsm)
+	excluded := map[string]bool{"kube-system": true, nsm.ObjectMeta.Namespace: true}
+
+	serviceAccounts := map[types.NamespacedName]bool{}
+	addTemplate := func(namespace string, template corev1.PodTemplateSpec) {
+		if _, ok := template.Annotations[annotation]; !ok || excluded[namespace] {
+			return
+		}
+		name := template.Spec.ServiceAccountName
+		if name == "" {
+			name = "default"
+		}
+		serviceAccounts[types.NamespacedName{Name: name, Namespace: namespace}] = true
+	}
+
+	for _, namespace := range namespaces {
+		opts := []client.ListOption{client.InNamespace(namespace)}
+		if r.ClusterCache != nil {
+			opts = append(opts, client.MatchingFields{templateAnnotationsIndex: annotation})
+		}
+		deployments := &appsv1.DeploymentList{}
+		if err := reader.List(ctx, deployments, opts...); err != nil {
+			return nil, err
+		}
+		for _, d := range deployments.Items {
+			addTemplate(d.Namespace, d.Spec.Template)
+		}
+		statefulSets := &appsv1.StatefulSetList{}
+		if err := reader.List(ctx, statefulSets, opts...); err != nil {
+			return nil, err
+		}
+		for _, s := range statefulSets.Items {
+			addTemplate(s.Namespace, s.Spec.Template)
+		}
+		daemonSets := &appsv1.DaemonSetList{}
+		if err := reader.List(ctx, daemonSets, opts...); err != nil {
+			return nil, err
+		}
+		for _, d := range daemonSets.Items {
+			addTemplate(d.Namespace, d.Spec.Template)
+		}
+	}
+	return serviceAccounts, nil
+}
+
+// Get the reader of the client workloads, the cluster wide cache when the
+// operator runs next to them or the API server for the members of a fleet
+func (r *SCCReconciler) getClientReader() client.Reader {
+	if r.ClusterCache != nil {
+		return r.ClusterCache
+	}
+	return r.APIReader
+}
+
+// Index the workloads by the annotations of their pod template
+func indexTemplateAnnotations(obj client.Object) []string {
+	var template corev1.PodTemplateSpec
+	switch workload := obj.(type) {
+	case *appsv1.Deployment:
+		template = workload.Spec.Template
+	case *appsv1.StatefulSet:
+		template = workload.Spec.Template
+	case *appsv1.DaemonSet:
+		template = workload.Spec.Template
+	default:
+		return nil
+	}
+	return getSortedKeys(template.Annotations)
+}
+
+// Get the namespaces selected by the namespaceSelector of the webhook,
+// all of them are listed at once when it doesn't restrict them
+func (r *SCCReconciler) listInjectedNamespaces(ctx context.Context, reader client.Reader, nsm *nsmv1alpha1.NSM) ([]string, error) {
+
+	if nsm.Spec.Webhook.NamespaceSelector == nil {
+		return []string{metav1.NamespaceAll}, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(nsm.Spec.Webhook.NamespaceSelector)
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.NamespaceList{}
+	if err := reader.List(ctx, list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+	namespaces := []string{}
+	for _, ns := range list.Items {
+		namespaces = append(namespaces, ns.Name)
+	}
+	return namespaces, nil
+}
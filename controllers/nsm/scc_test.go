@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSCCReviewCache(t *testing.T) {
+	sa := types.NamespacedName{Name: "default", Namespace: "apps"}
+	now := time.Now()
+	cache := &sccReviewCache{}
+	cache.set(sa, true, now)
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		allowed bool
+		cached  bool
+	}{
+		{"fresh", now.Add(time.Minute), true, true},
+		{"at the deadline", now.Add(sccReviewTTL), true, true},
+		{"expired", now.Add(sccReviewTTL + time.Second), false, false},
+	}
+	for _, tt := range tests {
+		allowed, cached := cache.get(sa, tt.at)
+		if allowed != tt.allowed || cached != tt.cached {
+			t.Errorf("%s: get() = %v, %v, want %v, %v", tt.name, allowed, cached, tt.allowed, tt.cached)
+		}
+	}
+}
+
+func TestListClientServiceAccounts(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	annotated := func(name, namespace, serviceAccount string) *appsv1.Deployment {
+		deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+		deploy.Spec.Template.Annotations = map[string]string{"networkservicemesh.io": "kernel://service/nsm-1"}
+		deploy.Spec.Template.Spec.ServiceAccountName = serviceAccount
+		return deploy
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps", Labels: map[string]string{"nsm": "enabled"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+		annotated("client", "apps", "client"),
+		annotated("client", "other", ""),
+		annotated("component", "nsm", "nsm"),
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "plain", Namespace: "apps"}},
+	).Build()
+
+	tests := []struct {
+		name     string
+		selector *metav1.LabelSelector
+		want     []types.NamespacedName
+	}{
+		{
+			name: "all namespaces but the NSM one",
+			want: []types.NamespacedName{{Name: "client", Namespace: "apps"}, {Name: "default", Namespace: "other"}},
+		},
+		{
+			name:     "namespaces selected by the webhook",
+			selector: &metav1.LabelSelector{MatchLabels: map[string]string{"nsm": "enabled"}},
+			want:     []types.NamespacedName{{Name: "client", Namespace: "apps"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+			nsm.Spec.Webhook.NamespaceSelector = tt.selector
+			// Read through the API server as for fleet members, or the cluster cache
+			for _, clusterCache := range []client.Reader{nil, c} {
+				r := NewSCCReconciler(c, logr.Discard(), scheme, c, clusterCache, true, &sccReviewCache{})
+
+				got, err := r.listClientServiceAccounts(context.TODO(), nsm)
+				if err != nil {
+					t.Fatalf("listClientServiceAccounts() error = %v", err)
+				}
+				if len(got) != len(tt.want) {
+					t.Fatalf("listClientServiceAccounts() = %v, want %v", got, tt.want)
+				}
+				for _, sa := range tt.want {
+					if !got[sa] {
+						t.Errorf("listClientServiceAccounts() = %v, missing %v", got, sa)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestIndexTemplateAnnotations(t *testing.T) {
+	template := corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		"networkservicemesh.io": "kernel://service/nsm-1",
+		"other":                 "value",
+	}}}
+	want := []string{"networkservicemesh.io", "other"}
+
+	tests := []struct {
+		name string
+		obj  client.Object
+		want []string
+	}{
+		{name: "deployment", obj: &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Template: template}}, want: want},
+		{name: "statefulset", obj: &appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{Template: template}}, want: want},
+		{name: "daemonset", obj: &appsv1.DaemonSet{Spec: appsv1.DaemonSetSpec{Template: template}}, want: want},
+		{name: "no annotations", obj: &appsv1.Deployment{}},
+		{name: "not a workload", obj: &corev1.Pod{ObjectMeta: template.ObjectMeta}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := indexTemplateAnnotations(tt.obj); !reflect.DeepEqual(got, tt.want) && len(got)+len(tt.want) > 0 {
+				t.Errorf("indexTemplateAnnotations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSCCWithoutWebhook(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	nsm.Status.ClientNamespacesWithoutSCC = []string{"apps"}
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	// No workload is read without the webhook
+	r := NewSCCReconciler(c, logr.Discard(), scheme, nil, nil, true, &sccReviewCache{})
+
+	if err := r.Reconcile(context.TODO(), nsm); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: sccRoleBindingName, Namespace: "nsm"}, &rbacv1.RoleBinding{}); err != nil {
+		t.Errorf("privileged scc not granted to the components: %v", err)
+	}
+	if nsm.Status.ClientNamespacesWithoutSCC != nil {
+		t.Errorf("clientNamespacesWithoutSCC = %v, want none", nsm.Status.ClientNamespacesWithoutSCC)
+	}
+}
+
+func TestSCCRemovedOffOpenShift(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	nsm.Status.ClientNamespacesWithoutSCC = []string{"apps"}
+	r := NewSCCReconciler(nil, logr.Discard(), scheme, nil, nil, false, &sccReviewCache{})
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(r.roleForSCC(nsm), r.roleBindingForSCC(nsm)).Build()
+	r.Client = c
+
+	if err := r.Reconcile(context.TODO(), nsm); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	for _, obj := range []client.Object{&rbacv1.Role{}, &rbacv1.RoleBinding{}} {
+		err := c.Get(context.TODO(), types.NamespacedName{Name: sccRoleName, Namespace: "nsm"}, obj)
+		if !apierrors.IsNotFound(err) {
+			t.Errorf("%T %s still exists: %v", obj, sccRoleName, err)
+		}
+	}
+	if nsm.Status.ClientNamespacesWithoutSCC != nil {
+		t.Errorf("clientNamespacesWithoutSCC = %v, want none", nsm.Status.ClientNamespacesWithoutSCC)
+	}
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Capabilities granted to the NSM containers when they don't run privileged
+var (
+	// nsmgr hands over the network namespaces of its clients
+	// reading them from /proc and the host directories
+	nsmgrCapabilities = []corev1.Capability{"DAC_OVERRIDE", "SYS_PTRACE"}
+
+	// forwarders create interfaces in the client and endpoint network
+	// namespaces, VPP and SR-IOV also lock hugepages and VFIO memory
+	forwarderCapabilities = map[nsmv1alpha1.ForwarderType][]corev1.Capability{
+		nsmv1alpha1.ForwarderVpp:   {"NET_ADMIN", "NET_RAW", "SYS_ADMIN", "SYS_PTRACE", "SYS_NICE", "SYS_RESOURCE", "IPC_LOCK"},
+		nsmv1alpha1.ForwarderOvs:   {"NET_ADMIN", "NET_RAW", "SYS_ADMIN", "SYS_PTRACE"},
+		nsmv1alpha1.ForwarderSriov: {"NET_ADMIN", "SYS_ADMIN", "SYS_PTRACE", "SYS_RESOURCE", "IPC_LOCK"},
+	}
+)
+
+// Get the security context of the nsmgr container
+func getNsmgrSecurityContext(nsm *nsmv1alpha1.NSM) *corev1.SecurityContext {
+	if nsm.Spec.Nsmgr.SecurityContext != nil {
+		return nsm.Spec.Nsmgr.SecurityContext
+	}
+	if nsm.Spec.Privileged {
+		return getPrivilegedSecurityContext()
+	}
+	return getCapabilitiesSecurityContext(nsmgrCapabilities)
+}
+
+// Get the security context of the exclude-prefixes container, it only
+// writes its config file to an emptyDir so it doesn't need any privilege
+func getExclPrefSecurityContext(nsm *nsmv1alpha1.NSM) *corev1.SecurityContext {
+	if nsm.Spec.ExclPref.SecurityContext != nil {
+		return nsm.Spec.ExclPref.SecurityContext
+	}
+	return getUnprivilegedSecurityContext()
+}
+
+// Get a security context without any privilege and with a read-only root filesystem
+func getUnprivilegedSecurityContext() *corev1.SecurityContext {
+	privmode := false
+	readOnly := true
+	return &corev1.SecurityContext{
+		Privileged:               &privmode,
+		AllowPrivilegeEscalation: &privmode,
+		ReadOnlyRootFilesystem:   &readOnly,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
+// Get the security context of the forwarder container
+func getForwarderSecurityContext(nsm *nsmv1alpha1.NSM, ForwarderType nsmv1alpha1.ForwarderType) *corev1.SecurityContext {
+	for _, pf := range nsm.Spec.Forwarders {
+		if pf.Type == ForwarderType && pf.SecurityContext != nil {
+			return pf.SecurityContext
+		}
+	}
+	if nsm.Spec.Privileged {
+		return getPrivilegedSecurityContext()
+	}
+	return getCapabilitiesSecurityContext(forwarderCapabilities[ForwarderType])
+}
+
+func getPrivilegedSecurityContext() *corev1.SecurityContext {
+	privmode := true
+	return &corev1.SecurityContext{
+		Privileged: &privmode,
+	}
+}
+
+func getCapabilitiesSecurityContext(capabilities []corev1.Capability) *corev1.SecurityContext {
+	privmode := false
+	return &corev1.SecurityContext{
+		Privileged: &privmode,
+		Capabilities: &corev1.Capabilities{
+			Add:  capabilities,
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
+// Get the pod security context of an NSM component, with the RuntimeDefault
+// seccomp profile unless the component needs syscalls it filters out
+func getPodSecurityContext(podSecurity nsmv1alpha1.PodSecurity, runtimeDefaultSeccomp bool) *corev1.PodSecurityContext {
+	seccompProfile := podSecurity.SeccompProfile
+	if seccompProfile == nil && runtimeDefaultSeccomp {
+		seccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	}
+	if seccompProfile == nil && podSecurity.SELinuxOptions == nil {
+		return nil
+	}
+	return &corev1.PodSecurityContext{
+		SeccompProfile: seccompProfile,
+		SELinuxOptions: podSecurity.SELinuxOptions,
+	}
+}
+
+// Get the pod annotations setting the AppArmor profile of the given containers
+func getAppArmorAnnotations(podSecurity nsmv1alpha1.PodSecurity, containers ...string) map[string]string {
+	if podSecurity.AppArmorProfile == "" {
+		return nil
+	}
+	annotations := map[string]string{}
+	for _, container := range containers {
+		annotations[corev1.AppArmorBetaContainerAnnotationKeyPrefix+container] = podSecurity.AppArmorProfile
+	}
+	return annotations
+}
+
+// Get the Seccomp, AppArmor and SELinux settings of the forwarder
+func getForwarderPodSecurity(nsm *nsmv1alpha1.NSM, ForwarderType nsmv1alpha1.ForwarderType) nsmv1alpha1.PodSecurity {
+	for _, pf := range nsm.Spec.Forwarders {
+		if pf.Type == ForwarderType {
+			return pf.PodSecurity
+		}
+	}
+	return nsmv1alpha1.PodSecurity{}
+}
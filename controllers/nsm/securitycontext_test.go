@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestGetPodSecurityContext(t *testing.T) {
+	runtimeDefault := &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	localhost := "nsm.json"
+	custom := &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: &localhost}
+	seLinux := &corev1.SELinuxOptions{Level: "s0:c123,c456"}
+
+	tests := []struct {
+		name                  string
+		podSecurity           nsmv1alpha1.PodSecurity
+		runtimeDefaultSeccomp bool
+		want                  *corev1.PodSecurityContext
+	}{
+		{name: "runtime default", runtimeDefaultSeccomp: true, want: &corev1.PodSecurityContext{SeccompProfile: runtimeDefault}},
+		{name: "no default for the dataplane"},
+		{
+			name:                  "profile of the CR",
+			podSecurity:           nsmv1alpha1.PodSecurity{SeccompProfile: custom},
+			runtimeDefaultSeccomp: true,
+			want:                  &corev1.PodSecurityContext{SeccompProfile: custom},
+		},
+		{
+			name:        "SELinux options only",
+			podSecurity: nsmv1alpha1.PodSecurity{SELinuxOptions: seLinux},
+			want:        &corev1.PodSecurityContext{SELinuxOptions: seLinux},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getPodSecurityContext(tt.podSecurity, tt.runtimeDefaultSeccomp); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getPodSecurityContext() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAppArmorAnnotations(t *testing.T) {
+	if got := getAppArmorAnnotations(nsmv1alpha1.PodSecurity{}, "nsmgr"); got != nil {
+		t.Errorf("getAppArmorAnnotations() = %v, want none without a profile", got)
+	}
+	got := getAppArmorAnnotations(nsmv1alpha1.PodSecurity{AppArmorProfile: "runtime/default"}, "nsmgr", "exclude-prefixes")
+	want := map[string]string{
+		corev1.AppArmorBetaContainerAnnotationKeyPrefix + "nsmgr":            "runtime/default",
+		corev1.AppArmorBetaContainerAnnotationKeyPrefix + "exclude-prefixes": "runtime/default",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("getAppArmorAnnotations() = %v, want %v", got, want)
+	}
+}
+
+func TestComponentPodSecurity(t *testing.T) {
+	scheme := runtime.NewScheme()
+	seLinux := &corev1.SELinuxOptions{Level: "s0:c123,c456"}
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+	nsm.Spec.Nsmgr.PodSecurity = nsmv1alpha1.PodSecurity{AppArmorProfile: "runtime/default"}
+	nsm.Spec.Registry.PodSecurity = nsmv1alpha1.PodSecurity{SELinuxOptions: seLinux}
+	nsm.Spec.Webhook.Image = "webhook"
+	nsm.Spec.Forwarders = []nsmv1alpha1.Forwarder{{Type: nsmv1alpha1.ForwarderVpp}}
+
+	nsmgr := (&NsmgrReconciler{Scheme: scheme}).daemonSetForNSMGR(nsm).Spec.Template
+	forwarder := (&ForwarderReconciler{Scheme: scheme}).daemonSetForForwarder(nsm,
+		newObjectMeta("forwarder-vpp", "nsm", nil), nsmv1alpha1.ForwarderVpp, nil).Spec.Template
+	registry := (&RegistryReconciler{Scheme: scheme}).DeploymentForRegistry(nsm).Spec.Template
+	webhook := (&WebhookReconciler{Scheme: scheme}).DeploymentForWebhook(nsm, nil).Spec.Template
+
+	for name, template := range map[string]corev1.PodTemplateSpec{"nsmgr": nsmgr, "registry": registry, "webhook": webhook} {
+		sc := template.Spec.SecurityContext
+		if sc == nil || sc.SeccompProfile == nil || sc.SeccompProfile.Type != corev1.SeccompProfileTypeRuntimeDefault {
+			t.Errorf("%s pod security context = %v, want the RuntimeDefault seccomp profile", name, sc)
+		}
+	}
+	if sc := forwarder.Spec.SecurityContext; sc != nil {
+		t.Errorf("forwarder pod security context = %v, want none", sc)
+	}
+	for _, container := range []string{"nsmgr", "exclude-prefixes"} {
+		if profile := nsmgr.Annotations[corev1.AppArmorBetaContainerAnnotationKeyPrefix+container]; profile != "runtime/default" {
+			t.Errorf("AppArmor profile of %s = %q, want runtime/default", container, profile)
+		}
+	}
+	if options := registry.Spec.SecurityContext.SELinuxOptions; !reflect.DeepEqual(options, seLinux) {
+		t.Errorf("registry SELinux options = %v, want %v", options, seLinux)
+	}
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Bundle endpoint profiles of federated trust domains
+const (
+	bundleEndpointProfileSpiffe string = "https_spiffe"
+	bundleEndpointProfileWeb    string = "https_web"
+	// Port of the bundle endpoint of the SPIRE server deployed by the operator
+	spireFederationPort int32 = 8443
+	// Default CA TTL of the SPIRE server, SVIDs can't outlive their CA
+	spireCATTLHours int = 24
+	// Pod annotation listing the trust domains the k8s-workload-registrar
+	// federates the registration entry of the pod with
+	federatesWithAnnotation string = "spiffe.io/federatesWith"
+)
+
+// Check the SPIFFE settings of the CR that the CRD schema can't validate
+func validateSpiffe(nsm *nsmv1alpha1.NSM) error {
+
+	spiffe := nsm.Spec.Spiffe
+	if spiffe.SVIDTTL != nil {
+		if spiffe.SVIDTTL.Duration <= 0 {
+			return fmt.Errorf("svidTTL must be positive, got %s", spiffe.SVIDTTL.Duration)
+		}
+		if nsm.Spec.Spire.Enabled && spiffe.SVIDTTL.Hours() >= float64(spireCATTLHours) {
+			return fmt.Errorf("svidTTL must be shorter than the %dh CA TTL of the SPIRE server, got %s",
+				spireCATTLHours, spiffe.SVIDTTL.Duration)
+		}
+	}
+
+	trustDomains := map[string]bool{getTrustDomain(nsm): true}
+	for _, federated := range spiffe.Federation {
+		if trustDomains[federated.TrustDomain] {
+			return fmt.Errorf("trust domain %s is declared more than once", federated.TrustDomain)
+		}
+		trustDomains[federated.TrustDomain] = true
+
+		endpoint, err := url.Parse(federated.BundleEndpointURL)
+		if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+			return fmt.Errorf("invalid bundle endpoint URL %q for trust domain %s", federated.BundleEndpointURL, federated.TrustDomain)
+		}
+		if getBundleEndpointProfile(federated) == bundleEndpointProfileSpiffe &&
+			!strings.HasPrefix(federated.EndpointSPIFFEID, "spiffe://"+federated.TrustDomain+"/") {
+			return fmt.Errorf("the https_spiffe bundle endpoint of trust domain %s needs an endpointSPIFFEID in that trust domain",
+				federated.TrustDomain)
+		}
+	}
+	return nil
+}
+
+// Get the trust domain of the NSM workloads, the one of the SPIRE server
+// deployed by the operator or the one set in the CR
+func getTrustDomain(nsm *nsmv1alpha1.NSM) string {
+	if nsm.Spec.Spire.Enabled {
+		return getSpireTrustDomain(nsm)
+	}
+	return nsm.Spec.Spiffe.TrustDomain
+}
+
+// Get the authentication profile of a bundle endpoint (default: "https_spiffe")
+func getBundleEndpointProfile(federated nsmv1alpha1.FederatedDomain) string {
+	if federated.BundleEndpointProfile != "" {
+		return federated.BundleEndpointProfile
+	}
+	return bundleEndpointProfileSpiffe
+}
+
+// Get the trust domains the NSM workloads federate with
+func getFederatesWith(nsm *nsmv1alpha1.NSM) []string {
+	trustDomains := []string{}
+	for _, federated := range nsm.Spec.Spiffe.Federation {
+		trustDomains = append(trustDomains, federated.TrustDomain)
+	}
+	return trustDomains
+}
+
+// Get the annotations federating the NSM pods registered by the SPIRE
+// deployed by the operator, k8s-workload-registrar has no global setting
+// for it. spire-controller-manager gets them from the ClusterSPIFFEIDs.
+func getFederationAnnotations(nsm *nsmv1alpha1.NSM) map[string]string {
+	if !nsm.Spec.Spire.Enabled || len(nsm.Spec.Spiffe.Federation) == 0 {
+		return nil
+	}
+	return map[string]string{federatesWithAnnotation: strings.Join(getFederatesWith(nsm), ",")}
+}
+
+// Get the SpiffeValid condition of the NSM status
+func getSpiffeCondition(err error) metav1.Condition {
+	if err != nil {
+		return metav1.Condition{
+			Type:    nsmv1alpha1.NSMConditionSpiffeValid,
+			Status:  metav1.ConditionFalse,
+			Reason:  nsmv1alpha1.NSMReasonSpiffeInvalid,
+			Message: err.Error(),
+		}
+	}
+	return metav1.Condition{
+		Type:    nsmv1alpha1.NSMConditionSpiffeValid,
+		Status:  metav1.ConditionTrue,
+		Reason:  nsmv1alpha1.NSMReasonSpiffeValid,
+		Message: "SPIFFE settings are valid",
+	}
+}
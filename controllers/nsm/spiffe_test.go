@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestValidateSpiffe(t *testing.T) {
+	domain2 := nsmv1alpha1.FederatedDomain{
+		TrustDomain:       "domain2.example.org",
+		BundleEndpointURL: "https://spire-server.domain2.example.org:8443",
+		EndpointSPIFFEID:  "spiffe://domain2.example.org/spire/server",
+	}
+	tests := []struct {
+		name    string
+		spire   bool
+		spiffe  nsmv1alpha1.Spiffe
+		wantErr bool
+	}{
+		{name: "defaults"},
+		{
+			name:   "federation",
+			spiffe: nsmv1alpha1.Spiffe{TrustDomain: "domain1.example.org", Federation: []nsmv1alpha1.FederatedDomain{domain2}},
+		},
+		{
+			name: "https_web bundle endpoint without endpointSPIFFEID",
+			spiffe: nsmv1alpha1.Spiffe{Federation: []nsmv1alpha1.FederatedDomain{{
+				TrustDomain:           "domain2.example.org",
+				BundleEndpointURL:     "https://bundle.domain2.example.org",
+				BundleEndpointProfile: bundleEndpointProfileWeb,
+			}}},
+		},
+		{
+			name: "https_spiffe bundle endpoint without endpointSPIFFEID",
+			spiffe: nsmv1alpha1.Spiffe{Federation: []nsmv1alpha1.FederatedDomain{{
+				TrustDomain:       "domain2.example.org",
+				BundleEndpointURL: "https://spire-server.domain2.example.org:8443",
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "endpointSPIFFEID of another trust domain",
+			spiffe: nsmv1alpha1.Spiffe{Federation: []nsmv1alpha1.FederatedDomain{{
+				TrustDomain:       "domain2.example.org",
+				BundleEndpointURL: "https://spire-server.domain2.example.org:8443",
+				EndpointSPIFFEID:  "spiffe://domain3.example.org/spire/server",
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "plain http bundle endpoint",
+			spiffe: nsmv1alpha1.Spiffe{Federation: []nsmv1alpha1.FederatedDomain{{
+				TrustDomain:       "domain2.example.org",
+				BundleEndpointURL: "http://spire-server.domain2.example.org:8443",
+				EndpointSPIFFEID:  "spiffe://domain2.example.org/spire/server",
+			}}},
+			wantErr: true,
+		},
+		{
+			name:    "trust domain declared twice",
+			spiffe:  nsmv1alpha1.Spiffe{Federation: []nsmv1alpha1.FederatedDomain{domain2, domain2}},
+			wantErr: true,
+		},
+		{
+			name:    "federation with its own trust domain",
+			spiffe:  nsmv1alpha1.Spiffe{TrustDomain: "domain2.example.org", Federation: []nsmv1alpha1.FederatedDomain{domain2}},
+			wantErr: true,
+		},
+		{
+			name:    "negative SVID TTL",
+			spiffe:  nsmv1alpha1.Spiffe{SVIDTTL: &metav1.Duration{Duration: -time.Hour}},
+			wantErr: true,
+		},
+		{
+			name:    "SVID TTL outliving the CA of the operator SPIRE",
+			spire:   true,
+			spiffe:  nsmv1alpha1.Spiffe{SVIDTTL: &metav1.Duration{Duration: 48 * time.Hour}},
+			wantErr: true,
+		},
+		{
+			name:   "long SVID TTL with an external SPIRE",
+			spiffe: nsmv1alpha1.Spiffe{SVIDTTL: &metav1.Duration{Duration: 48 * time.Hour}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsm := &nsmv1alpha1.NSM{}
+			nsm.Spec.Spire.Enabled = tt.spire
+			nsm.Spec.Spiffe = tt.spiffe
+
+			if err := validateSpiffe(nsm); (err != nil) != tt.wantErr {
+				t.Errorf("validateSpiffe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpireAgentSocketVolume(t *testing.T) {
+	scheme := runtime.NewScheme()
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+	nsm.Spec.Webhook.Image = "webhook"
+	nsm.Spec.Forwarders = []nsmv1alpha1.Forwarder{{Type: nsmv1alpha1.ForwarderVpp}}
+
+	templates := func() map[string]corev1.PodTemplateSpec {
+		return map[string]corev1.PodTemplateSpec{
+			"nsmgr": (&NsmgrReconciler{Scheme: scheme}).daemonSetForNSMGR(nsm).Spec.Template,
+			"forwarder": (&ForwarderReconciler{Scheme: scheme}).daemonSetForForwarder(nsm,
+				newObjectMeta("forwarder-vpp", "nsm", nil), nsmv1alpha1.ForwarderVpp, nil).Spec.Template,
+			"registry": (&RegistryReconciler{Scheme: scheme}).DeploymentForRegistry(nsm).Spec.Template,
+			"webhook":  (&WebhookReconciler{Scheme: scheme}).DeploymentForWebhook(nsm, nil).Spec.Template,
+		}
+	}
+	socketVolume := func(name string, template corev1.PodTemplateSpec) corev1.Volume {
+		for _, volume := range template.Spec.Volumes {
+			if volume.Name == "spire-agent-socket" {
+				return volume
+			}
+		}
+		t.Fatalf("%s has no spire-agent-socket volume", name)
+		return corev1.Volume{}
+	}
+	csiDriverEnv := func(template corev1.PodTemplateSpec) string {
+		for _, env := range template.Spec.Containers[0].Env {
+			if env.Name == "NSM_SPIFFE_CSI_DRIVER" {
+				return env.Value
+			}
+		}
+		return ""
+	}
+
+	for name, template := range templates() {
+		if volume := socketVolume(name, template); volume.HostPath == nil || volume.HostPath.Path != spireAgentSocketDir {
+			t.Errorf("%s spire-agent-socket volume = %v, want the %s host directory", name, volume.VolumeSource, spireAgentSocketDir)
+		}
+	}
+	if driver := csiDriverEnv(templates()["webhook"]); driver != "" {
+		t.Errorf("NSM_SPIFFE_CSI_DRIVER = %q without the CSI driver", driver)
+	}
+
+	nsm.Spec.Spiffe.CSIDriver = true
+	for name, template := range templates() {
+		volume := socketVolume(name, template)
+		if volume.CSI == nil || volume.CSI.Driver != spiffeCSIDriver || volume.CSI.ReadOnly == nil || !*volume.CSI.ReadOnly {
+			t.Errorf("%s spire-agent-socket volume = %v, want a read-only %s volume", name, volume.VolumeSource, spiffeCSIDriver)
+		}
+	}
+	// Injected clients mount the Workload API the same way
+	if driver := csiDriverEnv(templates()["webhook"]); driver != spiffeCSIDriver {
+		t.Errorf("NSM_SPIFFE_CSI_DRIVER = %q, want %s", driver, spiffeCSIDriver)
+	}
+}
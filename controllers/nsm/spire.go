@@ -0,0 +1,597 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	spireServerName    string = "spire-server"
+	spireAgentName     string = "spire-agent"
+	spireRegistrarName string = "k8s-workload-registrar"
+	spireBundleName    string = "spire-bundle"
+	spireServerPort    int32  = 8081
+	spireTrustDomain   string = "example.org"
+	spireClusterName   string = "nsm-cluster"
+	// Socket of the SPIRE server API, shared with the workload registrar
+	spireServerSocketDir string = "/tmp/spire-server/private"
+	// Name of the volume claim template keeping the SPIRE server data
+	spireDataVolumeName string = "spire-data"
+)
+
+// SpireReconciler deploys the SPIRE server and agents used by the NSM
+// components when the NSM CR doesn't rely on an existing SPIRE installation
+type SpireReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+func NewSpireReconciler(client client.Client, log logr.Logger, scheme *runtime.Scheme) *SpireReconciler {
+	return &SpireReconciler{
+		Client: client,
+		Log:    log,
+		Scheme: scheme,
+	}
+}
+
+func (r *SpireReconciler) Reconcile(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	if err := r.reconcileConfigMap(ctx, nsm, spireServerName, map[string]string{"server.conf": getSpireServerConfig(nsm)}); err != nil {
+		return err
+	}
+	if err := r.reconcileConfigMap(ctx, nsm, spireRegistrarName, map[string]string{"k8s-workload-registrar.conf": getSpireRegistrarConfig(nsm)}); err != nil {
+		return err
+	}
+	if err := r.reconcileConfigMap(ctx, nsm, spireAgentName, map[string]string{"agent.conf": getSpireAgentConfig(nsm)}); err != nil {
+		return err
+	}
+	if err := r.reconcileBundle(ctx, nsm); err != nil {
+		return err
+	}
+	if err := r.reconcileService(ctx, nsm); err != nil {
+		return err
+	}
+	if err := r.reconcileStatefulSet(ctx, nsm); err != nil {
+		return err
+	}
+	return reconcileComponentDaemonSet(ctx, r.Client, r.Log, r.daemonSetForSpireAgent(nsm))
+}
+
+func (r *SpireReconciler) reconcileConfigMap(ctx context.Context, nsm *nsmv1alpha1.NSM, name string, data map[string]string) error {
+
+	cm := &corev1.ConfigMap{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: nsm.ObjectMeta.Namespace}, cm)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		cm = &corev1.ConfigMap{
+			ObjectMeta: newObjectMeta(name, nsm.ObjectMeta.Namespace, map[string]string{"app": "spire"}),
+			Data:       data,
+		}
+		// Set NSM instance as the owner and controller
+		controllerutil.SetControllerReference(nsm, cm, r.Scheme)
+		err = r.Client.Create(ctx, cm)
+		if err != nil {
+			r.Log.Error(err, "failed to create config map "+name)
+			return err
+		}
+		r.Log.Info("config map " + name + " created")
+		return nil
+	}
+	if !equality.Semantic.DeepEqual(cm.Data, data) {
+		cm.Data = data
+		err = r.Client.Update(ctx, cm)
+		if err != nil {
+			r.Log.Error(err, "failed to update config map "+name)
+			return err
+		}
+		r.Log.Info("config map " + name + " updated")
+	}
+	return nil
+}
+
+// The bundle is published by the k8sbundle notifier of spire-server,
+// the operator only creates the empty config map
+func (r *SpireReconciler) reconcileBundle(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	cm := &corev1.ConfigMap{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: spireBundleName, Namespace: nsm.ObjectMeta.Namespace}, cm)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: newObjectMeta(spireBundleName, nsm.ObjectMeta.Namespace, map[string]string{"app": "spire"}),
+			}
+			// Set NSM instance as the owner and controller
+			controllerutil.SetControllerReference(nsm, cm, r.Scheme)
+			err = r.Client.Create(ctx, cm)
+			if err != nil {
+				r.Log.Error(err, "failed to create config map "+spireBundleName)
+				return err
+			}
+			r.Log.Info("config map " + spireBundleName + " created")
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *SpireReconciler) reconcileService(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	svc := &corev1.Service{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: spireServerName, Namespace: nsm.ObjectMeta.Namespace}, svc)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			svc = &corev1.Service{
+				ObjectMeta: newObjectMeta(spireServerName, nsm.ObjectMeta.Namespace, map[string]string{"app": "spire"}),
+				Spec: corev1.ServiceSpec{
+					Ports: []corev1.ServicePort{
+						{Name: spireServerName,
+							Protocol:   corev1.ProtocolTCP,
+							Port:       spireServerPort,
+							TargetPort: intstr.FromInt(int(spireServerPort))},
+						{Name: "spire-federation",
+							Protocol:   corev1.ProtocolTCP,
+							Port:       spireFederationPort,
+							TargetPort: intstr.FromInt(int(spireFederationPort))},
+					},
+					Selector: map[string]string{"app": spireServerName},
+					Type:     corev1.ServiceTypeClusterIP,
+				},
+			}
+			// Set NSM instance as the owner and controller
+			controllerutil.SetControllerReference(nsm, svc, r.Scheme)
+			err = r.Client.Create(ctx, svc)
+			if err != nil {
+				r.Log.Error(err, "failed to create service for spire-server")
+				return err
+			}
+			r.Log.Info("spire-server service created")
+			return nil
+		}
+		return err
+	}
+	r.Log.Info("spire-server service already exists, skipping creation")
+	return nil
+}
+
+// The pod template is replaced when its rendered spec or the configuration
+// change, the rest of the statefulset is left as it was created
+func (r *SpireReconciler) reconcileStatefulSet(ctx context.Context, nsm *nsmv1alpha1.NSM) error {
+
+	desired := r.statefulSetForSpireServer(nsm)
+	sts := &appsv1.StatefulSet{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: spireServerName, Namespace: nsm.ObjectMeta.Namespace}, sts)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = r.Client.Create(ctx, desired)
+			if err != nil {
+				r.Log.Error(err, "failed to create statefulset for spire-server")
+				return err
+			}
+			r.Log.Info("spire-server statefulset created")
+			return nil
+		}
+		return err
+	}
+	// The volume claim templates can't be updated, a statefulset keeping
+	// the data on an emptyDir is replaced. The data is lost either way.
+	if len(sts.Spec.VolumeClaimTemplates) == 0 {
+		err = r.Client.Delete(ctx, sts, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !apierrors.IsNotFound(err) {
+			r.Log.Error(err, "failed to delete spire-server statefulset")
+			return err
+		}
+		err = r.Client.Create(ctx, desired)
+		if err != nil {
+			r.Log.Error(err, "failed to create statefulset for spire-server")
+			return err
+		}
+		r.Log.Info("spire-server statefulset recreated with a persistent volume")
+		return nil
+	}
+	if templateChanged(sts.Spec.Template, desired.Spec.Template) {
+		sts.Spec.Template = desired.Spec.Template
+		err = r.Client.Update(ctx, sts)
+		if err != nil {
+			r.Log.Error(err, "failed to update spire-server statefulset")
+			return err
+		}
+		r.Log.Info("spire-server statefulset updated")
+	}
+	return nil
+}
+
+func (r *SpireReconciler) statefulSetForSpireServer(nsm *nsmv1alpha1.NSM) *appsv1.StatefulSet {
+
+	objectMeta := newObjectMeta(spireServerName, nsm.ObjectMeta.Namespace, map[string]string{"app": "spire"})
+	serverLabel := map[string]string{"app": spireServerName}
+	replicas := int32(1)
+	shareProcessNamespace := true
+
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: objectMeta,
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: spireServerName,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: serverLabel,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: serverLabel,
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName:    spireServerServiceAccountName,
+					ShareProcessNamespace: &shareProcessNamespace,
+					Containers: []corev1.Container{
+						{
+							Name:            spireServerName,
+							Image:           nsm.Spec.Spire.ServerImage,
+							ImagePullPolicy: nsm.Spec.NsmPullPolicy,
+							Args:            []string{"-config", "/run/spire/config/server.conf"},
+							Ports: []corev1.ContainerPort{
+								{ContainerPort: spireServerPort},
+								{ContainerPort: spireFederationPort},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "spire-config",
+									MountPath: "/run/spire/config",
+									ReadOnly:  true},
+								{Name: spireDataVolumeName,
+									MountPath: "/run/spire/data"},
+								{Name: "spire-server-socket",
+									MountPath: spireServerSocketDir},
+							},
+							LivenessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									Exec: &corev1.ExecAction{
+										Command: []string{"/opt/spire/bin/spire-server", "healthcheck"},
+									}},
+								FailureThreshold:    2,
+								InitialDelaySeconds: 15,
+								PeriodSeconds:       60,
+								TimeoutSeconds:      3,
+							},
+							ReadinessProbe: &corev1.Probe{
+								ProbeHandler: corev1.ProbeHandler{
+									Exec: &corev1.ExecAction{
+										Command: []string{"/opt/spire/bin/spire-server", "healthcheck", "--shallow"},
+									}},
+							},
+						},
+						{
+							Name:            spireRegistrarName,
+							Image:           nsm.Spec.Spire.RegistrarImage,
+							ImagePullPolicy: nsm.Spec.NsmPullPolicy,
+							Args:            []string{"-config", "/run/spire/registrar/k8s-workload-registrar.conf"},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "registrar-config",
+									MountPath: "/run/spire/registrar",
+									ReadOnly:  true},
+								{Name: "spire-server-socket",
+									MountPath: spireServerSocketDir},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{Name: "spire-config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: spireServerName},
+								}}},
+						{Name: "registrar-config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: spireRegistrarName},
+								}}},
+						{Name: "spire-server-socket",
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{},
+							}},
+					},
+				},
+			},
+			// The datastore and the keys survive the restarts of spire-server,
+			// the agents and the registered workloads keep their identities
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{{
+				ObjectMeta: metav1.ObjectMeta{Name: spireDataVolumeName},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					StorageClassName: nsm.Spec.Spire.StorageClassName,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: getSpireStorageSize(nsm)},
+					},
+				},
+			}},
+		},
+	}
+	// The pods read their configuration from config maps
+	setConfigHash(&sts.Spec.Template, getSpireServerConfig(nsm), getSpireRegistrarConfig(nsm))
+
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, sts, r.Scheme)
+	return sts
+}
+
+// spire-agent creates its socket in the host directory mounted
+// into the NSM components as their spireAgentSocket
+func (r *SpireReconciler) daemonSetForSpireAgent(nsm *nsmv1alpha1.NSM) *appsv1.DaemonSet {
+
+	objectMeta := newObjectMeta(spireAgentName, nsm.ObjectMeta.Namespace, map[string]string{"app": "spire"})
+	agentLabel := map[string]string{"app": spireAgentName}
+	volTypeDirectoryOrCreate := corev1.HostPathDirectoryOrCreate
+	tokenExpiration := int64(7200)
+	socketPath := strings.TrimPrefix(getSpireAgentSocket(nsm), "unix://")
+
+	ds := &appsv1.DaemonSet{
+		ObjectMeta: objectMeta,
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: agentLabel,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: agentLabel,
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: spireAgentServiceAccountName,
+					HostPID:            true,
+					HostNetwork:        true,
+					DNSPolicy:          corev1.DNSClusterFirstWithHostNet,
+					Containers: []corev1.Container{{
+						Name:            spireAgentName,
+						Image:           nsm.Spec.Spire.AgentImage,
+						ImagePullPolicy: nsm.Spec.NsmPullPolicy,
+						Args:            []string{"-config", "/run/spire/config/agent.conf"},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "spire-config",
+								MountPath: "/run/spire/config",
+								ReadOnly:  true},
+							{Name: "spire-bundle",
+								MountPath: "/run/spire/bundle"},
+							{Name: "spire-agent-socket",
+								MountPath: getSpireAgentSocketDir(nsm)},
+							{Name: "spire-token",
+								MountPath: "/var/run/secrets/tokens"},
+						},
+						LivenessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								Exec: &corev1.ExecAction{
+									Command: []string{"/opt/spire/bin/spire-agent", "healthcheck", "-socketPath", socketPath},
+								}},
+							FailureThreshold:    2,
+							InitialDelaySeconds: 15,
+							PeriodSeconds:       60,
+							TimeoutSeconds:      3,
+						},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								Exec: &corev1.ExecAction{
+									Command: []string{"/opt/spire/bin/spire-agent", "healthcheck", "-socketPath", socketPath, "--shallow"},
+								}},
+							InitialDelaySeconds: 5,
+							PeriodSeconds:       5,
+						},
+					}},
+					Volumes: []corev1.Volume{
+						{Name: "spire-config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: spireAgentName},
+								}}},
+						{Name: "spire-bundle",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: spireBundleName},
+								}}},
+						{Name: "spire-agent-socket",
+							VolumeSource: corev1.VolumeSource{
+								HostPath: &corev1.HostPathVolumeSource{
+									Path: getHostPaths(nsm).SpireAgentSocket,
+									Type: &volTypeDirectoryOrCreate,
+								}}},
+						{Name: "spire-token",
+							VolumeSource: corev1.VolumeSource{
+								Projected: &corev1.ProjectedVolumeSource{
+									Sources: []corev1.VolumeProjection{{
+										ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+											Path:              spireAgentName,
+											ExpirationSeconds: &tokenExpiration,
+											Audience:          spireServerName,
+										}}},
+								}}},
+					},
+				},
+			},
+		},
+	}
+	// The pods read their configuration from a config map
+	setConfigHash(&ds.Spec.Template, getSpireAgentConfig(nsm))
+
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, ds, r.Scheme)
+	return ds
+}
+
+func getSpireTrustDomain(nsm *nsmv1alpha1.NSM) string {
+	if nsm.Spec.Spiffe.TrustDomain != "" {
+		return nsm.Spec.Spiffe.TrustDomain
+	}
+	return spireTrustDomain
+}
+
+func getSpireClusterName(nsm *nsmv1alpha1.NSM) string {
+	if nsm.Spec.Spire.ClusterName != "" {
+		return nsm.Spec.Spire.ClusterName
+	}
+	return spireClusterName
+}
+
+// Get the size of the SPIRE server data volume (default: "1Gi")
+func getSpireStorageSize(nsm *nsmv1alpha1.NSM) resource.Quantity {
+	if nsm.Spec.Spire.StorageSize != nil {
+		return *nsm.Spec.Spire.StorageSize
+	}
+	return resource.MustParse("1Gi")
+}
+
+// Get value for the log_level of the SPIRE components (default: "INFO")
+func getSpireLogLevel(nsm *nsmv1alpha1.NSM) string {
+	if nsm.Spec.Spire.LogLevel != "" {
+		return nsm.Spec.Spire.LogLevel
+	}
+	return "INFO"
+}
+
+func getSpireServerConfig(nsm *nsmv1alpha1.NSM) string {
+	return fmt.Sprintf(`server {
+  bind_address = "0.0.0.0"
+  bind_port = "%d"
+  socket_path = "%s/api.sock"
+  trust_domain = "%s"
+  data_dir = "/run/spire/data"
+  log_level = "%s"
+  ca_key_type = "rsa-2048"
+  default_svid_ttl = "%s"
+  ca_ttl = "%dh"
+  ca_subject = {
+    country = ["US"],
+    organization = ["SPIFFE"],
+    common_name = "",
+  }
+%s}
+plugins {
+  DataStore "sql" {
+    plugin_data {
+      database_type = "sqlite3"
+      connection_string = "/run/spire/data/datastore.sqlite3"
+    }
+  }
+  NodeAttestor "k8s_psat" {
+    plugin_data {
+      clusters = {
+        "%s" = {
+          use_token_review_api_validation = true
+          service_account_allow_list = ["%s:%s"]
+        }
+      }
+    }
+  }
+  KeyManager "disk" {
+    plugin_data {
+      keys_path = "/run/spire/data/keys.json"
+    }
+  }
+  Notifier "k8sbundle" {
+    plugin_data {
+      namespace = "%s"
+      config_map = "%s"
+    }
+  }
+}
+`, spireServerPort, spireServerSocketDir, getSpireTrustDomain(nsm), getSpireLogLevel(nsm),
+		getSpireSVIDTTL(nsm), spireCATTLHours, getSpireFederationConfig(nsm),
+		getSpireClusterName(nsm), nsm.ObjectMeta.Namespace, spireAgentServiceAccountName,
+		nsm.ObjectMeta.Namespace, spireBundleName)
+}
+
+// Get the federation block of the SPIRE server config, it serves the bundle of
+// the trust domain and fetches the bundles of the federated trust domains
+func getSpireFederationConfig(nsm *nsmv1alpha1.NSM) string {
+	if len(nsm.Spec.Spiffe.Federation) == 0 {
+		return ""
+	}
+	config := fmt.Sprintf(`  federation {
+    bundle_endpoint {
+      address = "0.0.0.0"
+      port = %d
+    }
+`, spireFederationPort)
+	for _, federated := range nsm.Spec.Spiffe.Federation {
+		profile := fmt.Sprintf(`bundle_endpoint_profile "%s" {}`, bundleEndpointProfileWeb)
+		if getBundleEndpointProfile(federated) == bundleEndpointProfileSpiffe {
+			profile = fmt.Sprintf(`bundle_endpoint_profile "%s" {
+        endpoint_spiffe_id = "%s"
+      }`, bundleEndpointProfileSpiffe, federated.EndpointSPIFFEID)
+		}
+		config += fmt.Sprintf(`    federates_with "%s" {
+      bundle_endpoint_url = "%s"
+      %s
+    }
+`, federated.TrustDomain, federated.BundleEndpointURL, profile)
+	}
+	return config + "  }\n"
+}
+
+// Get value for the default_svid_ttl of the SPIRE server (default: "1h")
+func getSpireSVIDTTL(nsm *nsmv1alpha1.NSM) string {
+	if nsm.Spec.Spiffe.SVIDTTL != nil {
+		return nsm.Spec.Spiffe.SVIDTTL.Duration.String()
+	}
+	return "1h"
+}
+
+// The registrar runs in reconcile mode, it registers every pod
+// with the spiffe://<trust domain>/ns/<namespace>/sa/<service account> ID.
+// It federates the entries of the pods with the trust domains of their
+// spiffe.io/federatesWith annotation, set on the NSM components.
+func getSpireRegistrarConfig(nsm *nsmv1alpha1.NSM) string {
+	return fmt.Sprintf(`log_level = "%s"
+trust_domain = "%s"
+server_socket_path = "%s/api.sock"
+cluster = "%s"
+mode = "reconcile"
+`, getSpireLogLevel(nsm), getSpireTrustDomain(nsm), spireServerSocketDir, getSpireClusterName(nsm))
+}
+
+func getSpireAgentConfig(nsm *nsmv1alpha1.NSM) string {
+	return fmt.Sprintf(`agent {
+  data_dir = "/run/spire"
+  log_level = "%s"
+  server_address = "%s.%s.svc"
+  server_port = "%d"
+  socket_path = "%s"
+  trust_bundle_path = "/run/spire/bundle/bundle.crt"
+  trust_domain = "%s"
+}
+plugins {
+  NodeAttestor "k8s_psat" {
+    plugin_data {
+      cluster = "%s"
+    }
+  }
+  KeyManager "memory" {
+    plugin_data {
+    }
+  }
+  WorkloadAttestor "k8s" {
+    plugin_data {
+      skip_kubelet_verification = true
+    }
+  }
+  WorkloadAttestor "unix" {
+    plugin_data {
+    }
+  }
+}
+`, getSpireLogLevel(nsm), spireServerName, nsm.ObjectMeta.Namespace, spireServerPort,
+		strings.TrimPrefix(getSpireAgentSocket(nsm), "unix://"), getSpireTrustDomain(nsm), getSpireClusterName(nsm))
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"testing"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestSpireServerDataVolume(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = nsmv1alpha1.AddToScheme(scheme)
+
+	storageClass := "fast"
+	size := resource.MustParse("5Gi")
+	tests := []struct {
+		name             string
+		spire            nsmv1alpha1.Spire
+		wantStorageClass *string
+		wantSize         string
+	}{
+		{name: "defaults", spire: nsmv1alpha1.Spire{Enabled: true}, wantSize: "1Gi"},
+		{
+			name:             "storage class and size",
+			spire:            nsmv1alpha1.Spire{Enabled: true, StorageClassName: &storageClass, StorageSize: &size},
+			wantStorageClass: &storageClass,
+			wantSize:         "5Gi",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm"}}
+			nsm.Spec.Spire = tt.spire
+
+			sts := (&SpireReconciler{Scheme: scheme}).statefulSetForSpireServer(nsm)
+			if len(sts.Spec.VolumeClaimTemplates) != 1 || sts.Spec.VolumeClaimTemplates[0].Name != spireDataVolumeName {
+				t.Fatalf("volume claim templates = %v", sts.Spec.VolumeClaimTemplates)
+			}
+			claim := sts.Spec.VolumeClaimTemplates[0].Spec
+			if got := claim.Resources.Requests[corev1.ResourceStorage]; got.String() != tt.wantSize {
+				t.Errorf("storage size = %s, want %s", got.String(), tt.wantSize)
+			}
+			if (claim.StorageClassName == nil) != (tt.wantStorageClass == nil) ||
+				claim.StorageClassName != nil && *claim.StorageClassName != *tt.wantStorageClass {
+				t.Errorf("storage class = %v, want %v", claim.StorageClassName, tt.wantStorageClass)
+			}
+			// The data volume comes from the claim, not from an emptyDir
+			for _, volume := range sts.Spec.Template.Spec.Volumes {
+				if volume.Name == spireDataVolumeName {
+					t.Errorf("pod volume %s shadows the volume claim template", volume.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestGetFederationAnnotations(t *testing.T) {
+	federation := []nsmv1alpha1.FederatedDomain{
+		{TrustDomain: "domain2.example.org"},
+		{TrustDomain: "domain3.example.org"},
+	}
+	tests := []struct {
+		name       string
+		spire      bool
+		federation []nsmv1alpha1.FederatedDomain
+		want       string
+	}{
+		{name: "operator SPIRE with federation", spire: true, federation: federation, want: "domain2.example.org,domain3.example.org"},
+		{name: "operator SPIRE without federation", spire: true},
+		{name: "external SPIRE", spire: false, federation: federation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nsm := &nsmv1alpha1.NSM{}
+			nsm.Spec.Spire.Enabled = tt.spire
+			nsm.Spec.Spiffe.Federation = tt.federation
+
+			if got := getFederationAnnotations(nsm)[federatesWithAnnotation]; got != tt.want {
+				t.Errorf("getFederationAnnotations() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
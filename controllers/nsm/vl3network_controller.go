@@ -0,0 +1,429 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	nseVl3Image  string = "ghcr.io/networkservicemesh/cmd-nse-vl3-vpp"
+	ipamVl3Image string = "ghcr.io/networkservicemesh/cmd-ipam-vl3"
+	// Port the IPAM server hands out the endpoint prefixes on
+	vl3IPAMPort int32 = 5006
+	// Default length of the prefix of each endpoint
+	vl3EndpointPrefixLength int32 = 24
+)
+
+// NetworkServices are only read by registry-k8s, their CRD may not be installed
+var networkServiceGVK = schema.GroupVersionKind{Group: "networkservicemesh.io", Version: "v1", Kind: "NetworkService"}
+
+// VL3NetworkReconciler reconciles a VL3Network object
+type VL3NetworkReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=vl3networks,verbs=get;list;watch;create;update;patch;delete,namespace=nsm
+// +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=vl3networks/status,verbs=get;update;patch,namespace=nsm
+// +kubebuilder:rbac:groups=nsm.networkservicemesh.io,resources=vl3networks/finalizers,verbs=update,namespace=nsm
+
+// Reconcile for VL3Networks, the IPAM server, the vL3 endpoints and the
+// NetworkService of the network are deployed with the NSM instance
+func (r *VL3NetworkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+
+	Log := log.FromContext(ctx).WithValues("vl3network", req.NamespacedName)
+
+	// Fetch the VL3Network instance
+	network := &nsmv1alpha1.VL3Network{}
+	err := r.Client.Get(ctx, req.NamespacedName, network)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// The network components are owned by it and garbage collected with it
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	status := network.Status.DeepCopy()
+
+	prefixes, err := splitPrefix(network.Spec.Prefix, int(getVl3EndpointPrefixLength(network)), int(getVl3EndpointCount(network)))
+	if err != nil {
+		meta.SetStatusCondition(&network.Status.Conditions, metav1.Condition{
+			Type:    nsmv1alpha1.VL3NetworkConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  nsmv1alpha1.VL3NetworkReasonInvalidPrefix,
+			Message: err.Error(),
+		})
+		return ctrl.Result{}, r.updateStatus(ctx, network, status)
+	}
+
+	nsm, message, err := getNamespaceNSM(ctx, r.Client, network.Namespace, network.Spec.NSM)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if nsm == nil {
+		// The network is reconciled again when the NSM instance shows up
+		meta.SetStatusCondition(&network.Status.Conditions, metav1.Condition{
+			Type:    nsmv1alpha1.VL3NetworkConditionReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  nsmv1alpha1.VL3NetworkReasonNSMNotFound,
+			Message: message,
+		})
+		return ctrl.Result{}, r.updateStatus(ctx, network, status)
+	}
+
+	registered, err := r.reconcileNetworkService(ctx, Log, network)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.reconcileIPAMService(ctx, Log, network); err != nil {
+		return ctrl.Result{}, err
+	}
+	ipam, err := reconcileComponentDeployment(ctx, r.Client, Log, r.deploymentForIPAM(network, nsm))
+	if err != nil {
+		return r.deploymentFailed(ctx, network, status, err)
+	}
+	nse, err := reconcileComponentDeployment(ctx, r.Client, Log, r.deploymentForNSE(network, nsm, registered))
+	if err != nil {
+		return r.deploymentFailed(ctx, network, status, err)
+	}
+
+	network.Status.PlannedPrefixes = prefixes
+	network.Status.ClientAnnotation = "kernel://" + network.Name + "/nsm-1"
+	network.Status.ReadyEndpoints = nse.Status.ReadyReplicas
+	meta.SetStatusCondition(&network.Status.Conditions, getVl3NetworkCondition(ipam, nse))
+	return ctrl.Result{}, r.updateStatus(ctx, network, status)
+}
+
+// Report the deployments of other workloads named like the ones of the
+// network, they are left as they are
+func (r *VL3NetworkReconciler) deploymentFailed(ctx context.Context, network *nsmv1alpha1.VL3Network, status *nsmv1alpha1.VL3NetworkStatus, err error) (ctrl.Result, error) {
+	if !isNotControlled(err) {
+		return ctrl.Result{}, err
+	}
+	meta.SetStatusCondition(&network.Status.Conditions, metav1.Condition{
+		Type:    nsmv1alpha1.VL3NetworkConditionReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  nsmv1alpha1.VL3NetworkReasonDeploymentConflict,
+		Message: err.Error(),
+	})
+	return ctrl.Result{}, r.updateStatus(ctx, network, status)
+}
+
+func (r *VL3NetworkReconciler) updateStatus(ctx context.Context, network *nsmv1alpha1.VL3Network, status *nsmv1alpha1.VL3NetworkStatus) error {
+	if equality.Semantic.DeepEqual(status, &network.Status) {
+		return nil
+	}
+	return r.Client.Status().Update(ctx, network)
+}
+
+// Create the NetworkService of the network when registry-k8s can read it,
+// otherwise the endpoints register it themselves. Returns whether it exists.
+func (r *VL3NetworkReconciler) reconcileNetworkService(ctx context.Context, Log logr.Logger, network *nsmv1alpha1.VL3Network) (bool, error) {
+
+	_, err := r.Client.RESTMapper().RESTMapping(networkServiceGVK.GroupKind(), networkServiceGVK.Version)
+	if err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	ns := &unstructured.Unstructured{}
+	ns.SetGroupVersionKind(networkServiceGVK)
+	err = r.Client.Get(ctx, types.NamespacedName{Name: network.Name, Namespace: network.Namespace}, ns)
+	if err == nil {
+		Log.Info("network service " + network.Name + " already exists, skipping creation")
+		return true, nil
+	}
+	if !errors.IsNotFound(err) {
+		return false, err
+	}
+	ns = &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"payload": "IP"},
+	}}
+	ns.SetGroupVersionKind(networkServiceGVK)
+	ns.SetName(network.Name)
+	ns.SetNamespace(network.Namespace)
+	ns.SetLabels(map[string]string{"app": "nsm"})
+	// Set VL3Network instance as the owner and controller
+	controllerutil.SetControllerReference(network, ns, r.Scheme)
+	err = r.Client.Create(ctx, ns)
+	if err != nil {
+		Log.Error(err, "failed to create network service "+network.Name)
+		return false, err
+	}
+	Log.Info("network service " + network.Name + " created")
+	return true, nil
+}
+
+func (r *VL3NetworkReconciler) reconcileIPAMService(ctx context.Context, Log logr.Logger, network *nsmv1alpha1.VL3Network) error {
+
+	name := network.Name + "-ipam"
+	svc := &corev1.Service{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: network.Namespace}, svc)
+	if err == nil {
+		Log.Info("service " + name + " already exists, skipping creation")
+		return nil
+	}
+	if !errors.IsNotFound(err) {
+		return err
+	}
+	svc = &corev1.Service{
+		ObjectMeta: newObjectMeta(name, network.Namespace, map[string]string{"app": "nsm"}),
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": name},
+			Ports: []corev1.ServicePort{{
+				Name:       "ipam",
+				Protocol:   corev1.ProtocolTCP,
+				Port:       vl3IPAMPort,
+				TargetPort: intstr.FromInt(int(vl3IPAMPort)),
+			}},
+		},
+	}
+	// Set VL3Network instance as the owner and controller
+	controllerutil.SetControllerReference(network, svc, r.Scheme)
+	err = r.Client.Create(ctx, svc)
+	if err != nil {
+		Log.Error(err, "failed to create service "+name)
+		return err
+	}
+	Log.Info("service " + name + " created")
+	return nil
+}
+
+func (r *VL3NetworkReconciler) deploymentForIPAM(network *nsmv1alpha1.VL3Network, nsm *nsmv1alpha1.NSM) *appsv1.Deployment {
+
+	name := network.Name + "-ipam"
+	replicas := int32(1)
+	envVars := []corev1.EnvVar{
+		{Name: "NSM_LISTEN_ON", Value: fmt.Sprintf("tcp://:%d", vl3IPAMPort)},
+		{Name: "NSM_PREFIX", Value: network.Spec.Prefix},
+		{Name: "NSM_CLIENT_PREFIX_LEN", Value: fmt.Sprint(getVl3EndpointPrefixLength(network))},
+		{Name: "NSM_LOG_LEVEL", Value: getNsmLogLevel(nsm)},
+	}
+	container := corev1.Container{
+		Name:            "ipam",
+		Image:           getVl3Image(network.Spec.IPAMImage, ipamVl3Image, nsm),
+		ImagePullPolicy: nsm.Spec.NsmPullPolicy,
+		SecurityContext: getUnprivilegedSecurityContext(),
+		Env:             insertSpireAgentSocketEnv(envVars, getSpireAgentSocket(nsm)),
+		Ports:           []corev1.ContainerPort{{ContainerPort: vl3IPAMPort}},
+		VolumeMounts: []corev1.VolumeMount{{
+			Name:      "spire-agent-socket",
+			MountPath: getSpireAgentSocketDir(nsm),
+			ReadOnly:  true,
+		}},
+	}
+	return r.deploymentForNetwork(network, name, replicas, container, []corev1.Volume{getSpireAgentSocketVolume(nsm)})
+}
+
+func (r *VL3NetworkReconciler) deploymentForNSE(network *nsmv1alpha1.VL3Network, nsm *nsmv1alpha1.NSM, registered bool) *appsv1.Deployment {
+
+	name := network.Name + "-nse"
+	volType := corev1.HostPathDirectoryOrCreate
+	envVars := []corev1.EnvVar{
+		{Name: "NSM_NAME", ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{
+				FieldPath: "metadata.name",
+			}}},
+		{Name: "NSM_CONNECT_TO", Value: "unix:///var/lib/networkservicemesh/nsm.io.sock"},
+		{Name: "NSM_SERVICE_NAMES", Value: network.Name},
+		{Name: "NSM_REGISTER_SERVICE", Value: fmt.Sprint(!registered)},
+		{Name: "NSM_PREFIX_SERVER_URL", Value: fmt.Sprintf("%s-ipam:%d", network.Name, vl3IPAMPort)},
+		{Name: "NSM_DNS_TEMPLATES", Value: `{{ index .Labels "podName" }}.` + getVl3DNSDomain(network) + "."},
+		{Name: "NSM_LOG_LEVEL", Value: getNsmLogLevel(nsm)},
+	}
+	container := corev1.Container{
+		Name:            "nse",
+		Image:           getVl3Image(network.Spec.NSEImage, nseVl3Image, nsm),
+		ImagePullPolicy: nsm.Spec.NsmPullPolicy,
+		SecurityContext: getVl3EndpointSecurityContext(nsm),
+		Env:             insertSpireAgentSocketEnv(envVars, getSpireAgentSocket(nsm)),
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "spire-agent-socket",
+				MountPath: getSpireAgentSocketDir(nsm),
+				ReadOnly:  true,
+			},
+			{Name: "nsm-socket",
+				MountPath: "/var/lib/networkservicemesh",
+				ReadOnly:  true,
+			},
+		},
+	}
+	volumes := []corev1.Volume{
+		getSpireAgentSocketVolume(nsm),
+		{
+			Name: "nsm-socket",
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: getHostPaths(nsm).NsmSocket,
+					Type: &volType,
+				}}},
+	}
+	return r.deploymentForNetwork(network, name, getVl3EndpointCount(network), container, volumes)
+}
+
+func (r *VL3NetworkReconciler) deploymentForNetwork(network *nsmv1alpha1.VL3Network, name string, replicas int32,
+	container corev1.Container, volumes []corev1.Volume) *appsv1.Deployment {
+
+	label := map[string]string{"app": name, "spiffe.io/spiffe-id": "true"}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: newObjectMeta(name, network.Namespace, map[string]string{"app": "nsm"}),
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: label,
+			},
+			Replicas: &replicas,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: label,
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: nseServiceAccountName,
+					Containers:         []corev1.Container{container},
+					Volumes:            volumes,
+				},
+			},
+		},
+	}
+
+	// The pods are rolled when anything rendered from the
+	// network or the NSM instance changes
+	setConfigHash(&deploy.Spec.Template)
+
+	// Set VL3Network instance as the owner and controller
+	controllerutil.SetControllerReference(network, deploy, r.Scheme)
+	return deploy
+}
+
+func getVl3EndpointCount(network *nsmv1alpha1.VL3Network) int32 {
+	if network.Spec.EndpointCount > 0 {
+		return network.Spec.EndpointCount
+	}
+	return 1
+}
+
+func getVl3EndpointPrefixLength(network *nsmv1alpha1.VL3Network) int32 {
+	if network.Spec.EndpointPrefixLength > 0 {
+		return network.Spec.EndpointPrefixLength
+	}
+	return vl3EndpointPrefixLength
+}
+
+// Get the DNS domain of the clients (default: the name of the network)
+func getVl3DNSDomain(network *nsmv1alpha1.VL3Network) string {
+	if network.Spec.DNSDomain != "" {
+		return network.Spec.DNSDomain
+	}
+	return network.Name
+}
+
+// Get the image set in the CR or the one of the NSM version
+func getVl3Image(image string, defaultImage string, nsm *nsmv1alpha1.NSM) string {
+	if image != "" {
+		return image
+	}
+	return defaultImage + ":" + nsm.Spec.Version
+}
+
+// vL3 endpoints run VPP like the vpp forwarder
+func getVl3EndpointSecurityContext(nsm *nsmv1alpha1.NSM) *corev1.SecurityContext {
+	if nsm.Spec.Privileged {
+		return getPrivilegedSecurityContext()
+	}
+	return getCapabilitiesSecurityContext(forwarderCapabilities[nsmv1alpha1.ForwarderVpp])
+}
+
+// Split the network prefix into the prefixes of the endpoints, in the
+// order the IPAM server hands them out
+func splitPrefix(prefix string, length int, count int) ([]string, error) {
+
+	_, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prefix %q: %v", prefix, err)
+	}
+	ones, bits := ipNet.Mask.Size()
+	if length < ones || length > bits {
+		return nil, fmt.Errorf("endpoint prefix length %d doesn't fit in prefix %s", length, prefix)
+	}
+	if length-ones < 31 && count > 1<<(length-ones) {
+		return nil, fmt.Errorf("prefix %s has room for %d endpoints of length %d, %d requested", prefix, 1<<(length-ones), length, count)
+	}
+
+	base := new(big.Int).SetBytes(ipNet.IP)
+	step := new(big.Int).Lsh(big.NewInt(1), uint(bits-length))
+	prefixes := []string{}
+	for i := 0; i < count; i++ {
+		ip := new(big.Int).Add(base, new(big.Int).Mul(step, big.NewInt(int64(i)))).FillBytes(make([]byte, len(ipNet.IP)))
+		prefixes = append(prefixes, (&net.IPNet{IP: ip, Mask: net.CIDRMask(length, bits)}).String())
+	}
+	return prefixes, nil
+}
+
+// Get the Ready condition of the network status
+func getVl3NetworkCondition(ipam, nse *appsv1.Deployment) metav1.Condition {
+	if ipam.Status.ReadyReplicas >= *ipam.Spec.Replicas && nse.Status.ReadyReplicas >= *nse.Spec.Replicas {
+		return metav1.Condition{
+			Type:    nsmv1alpha1.VL3NetworkConditionReady,
+			Status:  metav1.ConditionTrue,
+			Reason:  nsmv1alpha1.VL3NetworkReasonReady,
+			Message: "the IPAM server and all the endpoints are ready",
+		}
+	}
+	return metav1.Condition{
+		Type:    nsmv1alpha1.VL3NetworkConditionReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  nsmv1alpha1.VL3NetworkReasonNotReady,
+		Message: "waiting for the IPAM server and the endpoints to be ready",
+	}
+}
+
+// Get the networks deployed with an NSM instance, they are rendered again when it changes
+func (r *VL3NetworkReconciler) networksForNSM(obj client.Object) []reconcile.Request {
+	networks := &nsmv1alpha1.VL3NetworkList{}
+	if err := r.Client.List(context.Background(), networks, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, network := range networks.Items {
+		if network.Spec.NSM == "" || network.Spec.NSM == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: network.Name, Namespace: network.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager registers the controller with the manager and adds the owned resource types
+func (r *VL3NetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&nsmv1alpha1.VL3Network{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Watches(&source.Kind{Type: &nsmv1alpha1.NSM{}}, handler.EnqueueRequestsFromMapFunc(r.networksForNSM)).
+		Complete(r)
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitPrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		prefix  string
+		length  int
+		count   int
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "ipv4",
+			prefix: "172.16.0.0/16",
+			length: 24,
+			count:  3,
+			want:   []string{"172.16.0.0/24", "172.16.1.0/24", "172.16.2.0/24"},
+		},
+		{
+			name:   "host bits of the prefix are ignored",
+			prefix: "172.16.5.1/16",
+			length: 17,
+			count:  2,
+			want:   []string{"172.16.0.0/17", "172.16.128.0/17"},
+		},
+		{
+			name:   "whole prefix",
+			prefix: "10.0.0.0/24",
+			length: 24,
+			count:  1,
+			want:   []string{"10.0.0.0/24"},
+		},
+		{
+			name:   "ipv6",
+			prefix: "fd00::/64",
+			length: 112,
+			count:  2,
+			want:   []string{"fd00::/112", "fd00::1:0/112"},
+		},
+		{
+			name:   "ipv6 beyond 64 bits of endpoints",
+			prefix: "fd00::/32",
+			length: 128,
+			count:  1,
+			want:   []string{"fd00::/128"},
+		},
+		{
+			name:    "length smaller than the prefix length",
+			prefix:  "172.16.0.0/16",
+			length:  8,
+			count:   1,
+			wantErr: true,
+		},
+		{
+			name:    "length longer than the address",
+			prefix:  "172.16.0.0/16",
+			length:  33,
+			count:   1,
+			wantErr: true,
+		},
+		{
+			name:    "more endpoints than room in the prefix",
+			prefix:  "172.16.0.0/16",
+			length:  18,
+			count:   5,
+			wantErr: true,
+		},
+		{
+			name:    "count overflowing the ipv4 room",
+			prefix:  "10.0.0.0/8",
+			length:  32,
+			count:   1<<24 + 1,
+			wantErr: true,
+		},
+		{
+			name:    "invalid prefix",
+			prefix:  "172.16.0.0",
+			length:  24,
+			count:   1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitPrefix(tt.prefix, tt.length, tt.count)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitPrefix() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitPrefix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// Secret holding the serving certificate mounted into admission-webhook-k8s
+	webhookCertsSecretName string = "admission-webhook-certs"
+	// Secret holding the CA that signs the serving certificate
+	webhookCASecretName string = "admission-webhook-ca"
+	// Key of the CA private key in the CA secret
+	caPrivateKeyKey string = "ca.key"
+)
+
+// cert-manager may not be installed, its resources are handled as unstructured objects
+var (
+	certManagerIssuerGVK      = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Issuer"}
+	certManagerCertificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+)
+
+// Get the CA bundle of the webhook, provisioning its serving certificate
+// through cert-manager when it's installed or with the operator CA otherwise
+func (r *MutatingWebhookReconciler) reconcileCertificates(ctx context.Context, nsm *nsmv1alpha1.NSM) ([]byte, error) {
+
+	_, err := r.Client.RESTMapper().RESTMapping(certManagerCertificateGVK.GroupKind(), certManagerCertificateGVK.Version)
+	if err == nil {
+		return r.reconcileCertManagerCertificates(ctx, nsm)
+	}
+	if !meta.IsNoMatchError(err) {
+		return nil, err
+	}
+	return r.reconcileOperatorCertificates(ctx, nsm)
+}
+
+// cert-manager issues and renews the CA and the serving certificate,
+// the CA bundle is read back from their secrets. Until they are issued
+// the webhook isn't registered, the other components are deployed anyway.
+func (r *MutatingWebhookReconciler) reconcileCertManagerCertificates(ctx context.Context, nsm *nsmv1alpha1.NSM) ([]byte, error) {
+
+	privateKey := map[string]interface{}{"algorithm": "ECDSA", "size": int64(256)}
+	for _, desired := range []*unstructured.Unstructured{
+		newCertManagerObject(certManagerIssuerGVK, "admission-webhook-selfsigned", nsm, map[string]interface{}{
+			"selfSigned": map[string]interface{}{},
+		}),
+		newCertManagerObject(certManagerCertificateGVK, webhookCASecretName, nsm, map[string]interface{}{
+			"isCA":        true,
+			"commonName":  webhookCASecretName,
+			"secretName":  webhookCASecretName,
+			"duration":    webhookCAValidity.String(),
+			"renewBefore": webhookCARenewBefore.String(),
+			"privateKey":  privateKey,
+			"issuerRef":   map[string]interface{}{"name": "admission-webhook-selfsigned", "kind": "Issuer"},
+		}),
+		newCertManagerObject(certManagerIssuerGVK, webhookCASecretName, nsm, map[string]interface{}{
+			"ca": map[string]interface{}{"secretName": webhookCASecretName},
+		}),
+		newCertManagerObject(certManagerCertificateGVK, "admission-webhook-k8s", nsm, map[string]interface{}{
+			"commonName":  webhookServiceName,
+			"dnsNames":    toInterfaceSlice(getWebhookDNSNames(nsm)),
+			"secretName":  webhookCertsSecretName,
+			"duration":    webhookCertValidity.String(),
+			"renewBefore": webhookCertRenewBefore.String(),
+			"usages":      []interface{}{"server auth"},
+			"privateKey":  privateKey,
+			"issuerRef":   map[string]interface{}{"name": webhookCASecretName, "kind": "Issuer"},
+		}),
+		newCertManagerObject(certManagerCertificateGVK, policyWebhookServiceName, nsm, map[string]interface{}{
+			"commonName":  policyWebhookServiceName,
+			"dnsNames":    toInterfaceSlice(getServiceDNSNames(policyWebhookServiceName, nsm.ObjectMeta.Namespace)),
+			"secretName":  policyWebhookCertsSecretName,
+			"duration":    webhookCertValidity.String(),
+			"renewBefore": webhookCertRenewBefore.String(),
+			"usages":      []interface{}{"server auth"},
+			"privateKey":  privateKey,
+			"issuerRef":   map[string]interface{}{"name": webhookCASecretName, "kind": "Issuer"},
+		}),
+	} {
+		if err := r.reconcileCertManagerObject(ctx, nsm, desired); err != nil {
+			return nil, err
+		}
+	}
+
+	caSecret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: webhookCASecretName, Namespace: nsm.ObjectMeta.Namespace}, caSecret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, &dependencyNotReadyError{message: "waiting for cert-manager to issue the admission webhook CA"}
+		}
+		return nil, err
+	}
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: webhookCertsSecretName, Namespace: nsm.ObjectMeta.Namespace}, secret); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, &dependencyNotReadyError{message: "waiting for cert-manager to issue the admission webhook certificate"}
+		}
+		return nil, err
+	}
+	// Trust both the CA that signed the current serving certificate and
+	// the renewed one, the serving certificate follows the CA renewal later
+	return appendCertificates(secret.Data[corev1.ServiceAccountRootCAKey], caSecret.Data[corev1.TLSCertKey]), nil
+}
+
+func (r *MutatingWebhookReconciler) reconcileCertManagerObject(ctx context.Context, nsm *nsmv1alpha1.NSM, desired *unstructured.Unstructured) error {
+
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(desired.GroupVersionKind())
+	err := r.Client.Get(ctx, types.NamespacedName{Name: desired.GetName(), Namespace: desired.GetNamespace()}, current)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		// Set NSM instance as the owner and controller
+		controllerutil.SetControllerReference(nsm, desired, r.Scheme)
+		err = r.Client.Create(ctx, desired)
+		if err != nil {
+			r.Log.Error(err, "failed to create "+desired.GetKind()+" "+desired.GetName())
+			return err
+		}
+		r.Log.Info(desired.GetKind() + " " + desired.GetName() + " created")
+		return nil
+	}
+	if !specContains(current, desired) {
+		current.Object["spec"] = desired.Object["spec"]
+		err = r.Client.Update(ctx, current)
+		if err != nil {
+			r.Log.Error(err, "failed to update "+desired.GetKind()+" "+desired.GetName())
+			return err
+		}
+		r.Log.Info(desired.GetKind() + " " + desired.GetName() + " updated")
+	}
+	return nil
+}
+
+func newCertManagerObject(gvk schema.GroupVersionKind, name string, nsm *nsmv1alpha1.NSM, spec map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(name)
+	obj.SetNamespace(nsm.ObjectMeta.Namespace)
+	obj.SetLabels(map[string]string{"app": "nsm"})
+	return obj
+}
+
+// The operator keeps its own CA in a secret and signs the serving certificate
+// with it, both of them are rotated before they expire. The CA bundle keeps
+// the previous CA next to a rotated one, so the current certificate stays
+// trusted until the serving certificate is rotated as well.
+func (r *MutatingWebhookReconciler) reconcileOperatorCertificates(ctx context.Context, nsm *nsmv1alpha1.NSM) ([]byte, error) {
+
+	caSecret := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: webhookCASecretName, Namespace: nsm.ObjectMeta.Namespace}, caSecret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	caExists := err == nil
+	if !caExists || certificateNeedsRenewal(caSecret.Data[corev1.TLSCertKey], nil, webhookCARenewBefore) {
+		caCertPEM, caKeyPEM, err := newCA(webhookCASecretName)
+		if err != nil {
+			r.Log.Error(err, "failed to generate the admission webhook CA")
+			return nil, err
+		}
+		previous := caSecret.Data[corev1.TLSCertKey]
+		if certificateNeedsRenewal(previous, nil, 0) {
+			previous = nil
+		}
+		caSecret.Data = map[string][]byte{
+			corev1.ServiceAccountRootCAKey: appendCertificates(caCertPEM, previous),
+			corev1.TLSCertKey:              caCertPEM,
+			caPrivateKeyKey:                caKeyPEM,
+		}
+		if err = r.saveSecret(ctx, nsm, caSecret, webhookCASecretName, caExists); err != nil {
+			return nil, err
+		}
+	}
+	caBundle := caSecret.Data[corev1.ServiceAccountRootCAKey]
+
+	if err := r.reconcileServingCertificate(ctx, nsm, caSecret, webhookCertsSecretName, webhookServiceName); err != nil {
+		return nil, err
+	}
+	// The operator serves the injection policy webhook with the same CA
+	if err := r.reconcileServingCertificate(ctx, nsm, caSecret, policyWebhookCertsSecretName, policyWebhookServiceName); err != nil {
+		return nil, err
+	}
+	return caBundle, nil
+}
+
+// Issue the serving certificate of a service with the operator CA, again
+// when it's about to expire or the CA bundle changed
+func (r *MutatingWebhookReconciler) reconcileServingCertificate(ctx context.Context, nsm *nsmv1alpha1.NSM, caSecret *corev1.Secret, name, serviceName string) error {
+
+	caBundle := caSecret.Data[corev1.ServiceAccountRootCAKey]
+	caCertPEM := caSecret.Data[corev1.TLSCertKey]
+
+	secret := &corev1.Secret{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: nsm.ObjectMeta.Namespace}, secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	secretExists := err == nil
+	if !secretExists || certificateNeedsRenewal(secret.Data[corev1.TLSCertKey], caCertPEM, webhookCertRenewBefore) ||
+		!bytes.Equal(secret.Data[corev1.ServiceAccountRootCAKey], caBundle) {
+		certPEM, keyPEM, err := newServingCertificate(caCertPEM, caSecret.Data[caPrivateKeyKey], serviceName, getServiceDNSNames(serviceName, nsm.ObjectMeta.Namespace))
+		if err != nil {
+			r.Log.Error(err, "failed to generate the certificate of "+serviceName)
+			return err
+		}
+		secret.Type = corev1.SecretTypeTLS
+		secret.Data = map[string][]byte{
+			corev1.ServiceAccountRootCAKey: caBundle,
+			corev1.TLSCertKey:              certPEM,
+			corev1.TLSPrivateKeyKey:        keyPEM,
+		}
+		if err = r.saveSecret(ctx, nsm, secret, name, secretExists); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MutatingWebhookReconciler) saveSecret(ctx context.Context, nsm *nsmv1alpha1.NSM, secret *corev1.Secret, name string, exists bool) error {
+
+	if exists {
+		err := r.Client.Update(ctx, secret)
+		if err != nil {
+			r.Log.Error(err, "failed to update secret "+name)
+			return err
+		}
+		r.Log.Info("secret " + name + " rotated")
+		return nil
+	}
+	secret.ObjectMeta = newObjectMeta(name, nsm.ObjectMeta.Namespace, map[string]string{"app": "nsm"})
+	// Set NSM instance as the owner and controller
+	controllerutil.SetControllerReference(nsm, secret, r.Scheme)
+	err := r.Client.Create(ctx, secret)
+	if err != nil {
+		r.Log.Error(err, "failed to create secret "+name)
+		return err
+	}
+	r.Log.Info("secret " + name + " created")
+	return nil
+}
+
+// Concatenate PEM encoded certificates, skipping the empty and duplicate ones
+func appendCertificates(certs ...[]byte) []byte {
+	bundle := []byte{}
+	for _, cert := range certs {
+		if len(cert) == 0 || bytes.Contains(bundle, cert) {
+			continue
+		}
+		bundle = append(bundle, cert...)
+	}
+	return bundle
+}
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/logr"
+	nsmv1alpha1 "github.com/networkservicemesh/nsm-operator/apis/nsm/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileCertManagerCertificates(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsmv1alpha1.AddToScheme(scheme)
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(certManagerIssuerGVK, meta.RESTScopeNamespace)
+	mapper.Add(certManagerCertificateGVK, meta.RESTScopeNamespace)
+
+	nsm := &nsmv1alpha1.NSM{ObjectMeta: metav1.ObjectMeta{Name: "nsm", Namespace: "nsm", UID: "nsm-uid"}}
+	secret := func(name string) client.Object {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "nsm"},
+			Data:       map[string][]byte{corev1.TLSCertKey: []byte(name)},
+		}
+	}
+	tests := []struct {
+		name     string
+		secrets  []client.Object
+		notReady bool
+	}{
+		{name: "nothing issued yet", notReady: true},
+		{name: "only the CA issued", secrets: []client.Object{secret(webhookCASecretName)}, notReady: true},
+		{name: "issued", secrets: []client.Object{secret(webhookCASecretName), secret(webhookCertsSecretName)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).WithObjects(tt.secrets...).Build()
+			r := NewMutatingWebhookReconciler(c, logr.Discard(), scheme, c, false)
+
+			caBundle, err := r.reconcileCertManagerCertificates(context.TODO(), nsm)
+			if got := isDependencyNotReady(err); got != tt.notReady {
+				t.Fatalf("reconcileCertManagerCertificates() error = %v, not ready %v, want %v", err, got, tt.notReady)
+			}
+			if !tt.notReady && (err != nil || len(caBundle) == 0) {
+				t.Errorf("reconcileCertManagerCertificates() = %q, %v", caBundle, err)
+			}
+		})
+	}
+}
@@ -16,9 +16,11 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -38,6 +40,7 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 
 	utilruntime.Must(nsmv1alpha1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
@@ -67,11 +70,56 @@ func main() {
 		os.Exit(1)
 	}
 
+	platform, err := nsmcontroller.DetectPlatform(context.Background(), mgr.GetConfig(), mgr.GetAPIReader())
+	if err != nil {
+		setupLog.Error(err, "unable to detect platform, falling back to defaults")
+	}
+	setupLog.Info("using platform profile", "platform", platform)
+
 	if err = (&nsmcontroller.NSMReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Platform:  platform,
+		APIReader: mgr.GetAPIReader(),
+		Recorder:  mgr.GetEventRecorderFor("nsm-operator"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NSM")
+		os.Exit(1)
+	}
+
+	// Apply the injection policies of the namespaces to the injected clients
+	if err = mgr.Add(&nsmcontroller.InjectionPolicyWebhook{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Namespace: "nsm",
+		Log:       ctrl.Log.WithName("injection-policy-webhook"),
+	}); err != nil {
+		setupLog.Error(err, "unable to add webhook", "webhook", "InjectionPolicy")
+		os.Exit(1)
+	}
+
+	if err = (&nsmcontroller.NSMEndpointReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "NSM")
+		setupLog.Error(err, "unable to create controller", "controller", "NSMEndpoint")
+		os.Exit(1)
+	}
+
+	if err = (&nsmcontroller.VL3NetworkReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "VL3Network")
+		os.Exit(1)
+	}
+
+	if err = (&nsmcontroller.NSMFleetReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NSMFleet")
 		os.Exit(1)
 	}
 